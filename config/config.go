@@ -68,6 +68,16 @@ type ServerConfig struct {
 	HostNetwork           string `yaml:"hostnetwork"`
 	MQPort                string `yaml:"mqport"`
 	Server                string `yaml:"server"`
+	MigrationDryRun       string `yaml:"migrationdryrun"`
+	DBEncryptionKey       string `yaml:"dbencryptionkey"`
+	DBEncryptionKeyFile   string `yaml:"dbencryptionkeyfile"`
+	Caching               string `yaml:"caching"`
+	DeletedNodeRetention  int64  `yaml:"deletednoderetention"`
+	LoginMaxAttempts      int64  `yaml:"loginmaxattempts"`
+	LoginMaxIPAttempts    int64  `yaml:"loginmaxipattempts"`
+	LoginBackoff          int64  `yaml:"loginbackoff"`
+	LoginLockout          int64  `yaml:"loginlockout"`
+	TrustedProxies        string `yaml:"trustedproxies"`
 }
 
 // SQLConfig - Generic SQL Config
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+// errTokenManagedByToken - API tokens cannot create or revoke tokens, so a leaked token cannot outlive its revocation
+var errTokenManagedByToken = errors.New("API tokens cannot be used to manage API tokens, log in instead")
+
+// getAPITokens - lists the API tokens of a user, their secrets are never returned
+func getAPITokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		returnErrorResponse(w, r, formatError(errors.New("user "+username+" does not exist"), "notfound"))
+		return
+	}
+	tokens, err := logic.GetAPITokens(username)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// createAPIToken - creates an API token, the response holds the only copy of its secret
+func createAPIToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if usingAPIToken(r) {
+		returnErrorResponse(w, r, formatError(errTokenManagedByToken, "forbidden"))
+		return
+	}
+	username := mux.Vars(r)["username"]
+	if _, err := logic.GetUser(username); err != nil {
+		returnErrorResponse(w, r, formatError(errors.New("user "+username+" does not exist"), "notfound"))
+		return
+	}
+	var token models.APIToken
+	if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	created, err := logic.CreateAPIToken(username, token)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	audit(r, models.AUDIT_CREATE, "apitoken", created.ID, "", nil, &created.APIToken)
+	logger.Log(1, r.Header.Get("user"), "created API token", created.ID, "for", username, "with scope", created.Scope)
+	json.NewEncoder(w).Encode(created)
+}
+
+// deleteAPIToken - revokes an API token
+func deleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if usingAPIToken(r) {
+		returnErrorResponse(w, r, formatError(errTokenManagedByToken, "forbidden"))
+		return
+	}
+	var params = mux.Vars(r)
+	username, id := params["username"], params["tokenid"]
+	if err := logic.DeleteAPIToken(username, id); err != nil {
+		returnErrorResponse(w, r, formatError(err, "notfound"))
+		return
+	}
+	audit(r, models.AUDIT_DELETE, "apitoken", id, "", nil, nil)
+	logger.Log(1, r.Header.Get("user"), "revoked API token", id, "of", username)
+	returnSuccessResponse(w, r, "revoked API token "+id)
+}
+
+func usingAPIToken(r *http.Request) bool {
+	tokenSplit := strings.Split(r.Header.Get("Authorization"), " ")
+	return len(tokenSplit) > 1 && logic.IsAPIToken(tokenSplit[1])
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+func auditHandlers(r *mux.Router) {
+	r.HandleFunc("/api/audit", securityCheck(true, http.HandlerFunc(getAuditLog))).Methods("GET")
+}
+
+// getAuditLog - lists the audit log, newest first, filtered by time range, actor, action and target
+func getAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	options, err := parseListOptions(r)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	values := r.URL.Query()
+	query := logic.AuditQuery{
+		ListOptions: options,
+		Actor:       values.Get("actor"),
+		ActorType:   values.Get("actortype"),
+		Action:      values.Get("action"),
+		TargetType:  values.Get("targettype"),
+		Target:      values.Get("target"),
+	}
+	if query.From, err = parseTimeParam(values.Get("from")); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	if query.To, err = parseTimeParam(values.Get("to")); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	entries, page, err := logic.GetAuditEntries(query)
+	if err != nil {
+		errType := "internal"
+		if errors.Is(err, logic.ErrInvalidQuery) {
+			errType = "badrequest"
+		}
+		returnErrorResponse(w, r, formatError(err, errType))
+		return
+	}
+	logger.Log(2, r.Header.Get("user"), "fetched the audit log")
+	setPageHeaders(w, page)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// auditActor - identifies who made a request, from the user set by the auth middleware or the bearer token
+func auditActor(r *http.Request) (actorType string, actor string) {
+	var token string
+	if tokenSplit := strings.Split(r.Header.Get("Authorization"), " "); len(tokenSplit) > 1 {
+		token = tokenSplit[1]
+	}
+	if authenticateMaster(token) {
+		return models.AUDIT_ACTOR_MASTERKEY, models.AUDIT_ACTOR_MASTERKEY
+	}
+	if user := r.Header.Get("user"); user != "" && user != "(user not found)" {
+		return models.AUDIT_ACTOR_USER, user
+	}
+	if user, _, _, err := logic.VerifyUserToken(token); err == nil {
+		return models.AUDIT_ACTOR_USER, user
+	}
+	if nodeID, _, _, err := logic.VerifyToken(token); err == nil && nodeID != "" {
+		return models.AUDIT_ACTOR_NODE, nodeID
+	}
+	return models.AUDIT_ACTOR_ANONYMOUS, ""
+}
+
+// audit - records a change made by a request in the audit log
+func audit(r *http.Request, action, targetType, target, network string, before, after interface{}) {
+	actorType, actor := auditActor(r)
+	logic.Audit(models.AuditEntry{
+		ActorType:  actorType,
+		Actor:      actor,
+		Source:     "api",
+		Action:     action,
+		TargetType: targetType,
+		Target:     target,
+		Network:    network,
+	}, before, after)
+}
+
+// parseTimeParam - parses an RFC3339 time or unix timestamp, an empty value is the zero time
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid time " + value + ", expected RFC3339 or a unix timestamp")
+	}
+	return parsed, nil
+}
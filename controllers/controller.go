@@ -20,10 +20,16 @@ var HttpHandlers = []interface{}{
 	nodeHandlers,
 	userHandlers,
 	networkHandlers,
+	ipamHandlers,
 	dnsHandlers,
 	fileHandlers,
 	serverHandlers,
 	extClientHandlers,
+	auditHandlers,
+	eventHandlers,
+	declarationHandlers,
+	metricsHandlers,
+	healthHandlers,
 }
 
 // HandleRESTRequests - handles the rest requests
@@ -34,7 +40,7 @@ func HandleRESTRequests(wg *sync.WaitGroup) {
 
 	// Currently allowed dev origin is all. Should change in prod
 	// should consider analyzing the allowed methods further
-	headersOk := handlers.AllowedHeaders([]string{"Access-Control-Allow-Origin", "X-Requested-With", "Content-Type", "authorization"})
+	headersOk := handlers.AllowedHeaders([]string{"Access-Control-Allow-Origin", "X-Requested-With", "Content-Type", "authorization", "Last-Event-ID"})
 	originsOk := handlers.AllowedOrigins([]string{servercfg.GetAllowedOrigin()})
 	methodsOk := handlers.AllowedMethods([]string{"GET", "PUT", "POST", "DELETE"})
 
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+func declarationHandlers(r *mux.Router) {
+	r.HandleFunc("/api/apply", securityCheck(true, http.HandlerFunc(applyDeclaration))).Methods("POST")
+}
+
+// applyDeclaration - applies a YAML or JSON declaration of networks, ?dryrun=true only returns the changes it needs
+// and ?prune=true removes what it does not declare
+func applyDeclaration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dryRun, prune, err := declarationOptions(r)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	declaration, err := logic.ParseDeclaration(data)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	actorType, actor := auditActor(r)
+	result, err := logic.ApplyDeclaration(declaration, dryRun, prune, models.AuditEntry{ActorType: actorType, Actor: actor, Source: "api"})
+	if err != nil && result.Error == "" {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	if result.Applied > 0 {
+		go publishDeclarationChanges(result)
+	}
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"), "applied", strconv.Itoa(result.Applied), "of", strconv.Itoa(len(result.Changes)), "declared changes:", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		logger.Log(1, r.Header.Get("user"), "applied", strconv.Itoa(result.Applied), "declared changes, dry run:", strconv.FormatBool(dryRun))
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func declarationOptions(r *http.Request) (dryRun bool, prune bool, err error) {
+	values := r.URL.Query()
+	if value := values.Get("dryrun"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return
+		}
+	}
+	if value := values.Get("prune"); value != "" {
+		prune, err = strconv.ParseBool(value)
+	}
+	return
+}
+
+// publishDeclarationChanges - joins the server to created networks and sends the nodes of every changed network
+// their config and peers, as the endpoints making each change would
+func publishDeclarationChanges(result models.DeclarationResult) {
+	var changed = make(map[string]bool)
+	var created = make(map[string]bool)
+	for _, change := range result.Changes[:result.Applied] {
+		changed[change.Network] = true
+		if change.Kind == models.DECLARED_NETWORK && change.Action == models.AUDIT_CREATE {
+			created[change.Network] = true
+		}
+	}
+	for netid := range changed {
+		network, err := logic.GetNetwork(netid)
+		if err != nil {
+			continue // removed by the declaration
+		}
+		if created[netid] && servercfg.IsClientMode() != "off" {
+			if _, err = logic.ServerJoin(&network); err != nil {
+				logger.Log(0, "failed to add server to declared network", netid, err.Error())
+			}
+		}
+		nodes, err := logic.GetNetworkNodes(netid)
+		if err != nil || len(nodes) == 0 {
+			continue
+		}
+		for i := range nodes {
+			if err = mq.NodeUpdate(&nodes[i]); err != nil {
+				logger.Log(1, "failed to send update to node after applying declaration", nodes[i].Name, nodes[i].ID, err.Error())
+			}
+			if nodes[i].IsIngressGateway == "yes" {
+				if err = mq.PublishExtPeerUpdate(&nodes[i]); err != nil {
+					logger.Log(1, "error setting ext peers on", nodes[i].ID, ":", err.Error())
+				}
+			}
+		}
+		if err = mq.PublishPeerUpdate(&nodes[0]); err != nil {
+			logger.Log(1, "failed to publish peer update after applying declaration on", netid)
+		}
+	}
+}
@@ -44,12 +44,23 @@ func getNodeDNS(w http.ResponseWriter, r *http.Request) {
 //Gets all nodes associated with network, including pending nodes
 func getAllDNS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	query, err := parseDNSQuery(r)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
 	dns, err := logic.GetAllDNS()
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	dns, page, err := logic.QueryDNS(dns, query)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
 	//Returns all the nodes in JSON format
+	setPageHeaders(w, page)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(dns)
 }
@@ -111,6 +122,7 @@ func createDNS(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_CREATE, "dns", entry.Name+"."+entry.Network, entry.Network, nil, &entry)
 	err = logic.SetDNS()
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
@@ -127,6 +139,14 @@ func deleteDNS(w http.ResponseWriter, r *http.Request) {
 	// get params
 	var params = mux.Vars(r)
 
+	var before = &models.DNSEntry{Name: params["domain"], Network: params["network"]}
+	if entries, err := logic.GetCustomDNS(params["network"]); err == nil {
+		for i := range entries {
+			if entries[i].Name == params["domain"] {
+				before = &entries[i]
+			}
+		}
+	}
 	err := logic.DeleteDNS(params["domain"], params["network"])
 
 	if err != nil {
@@ -134,6 +154,7 @@ func deleteDNS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	entrytext := params["domain"] + "." + params["network"]
+	audit(r, models.AUDIT_DELETE, "dns", entrytext, params["network"], before, nil)
 	logger.Log(1, "deleted dns entry: ", entrytext)
 	err = logic.SetDNS()
 	if err != nil {
@@ -145,18 +166,7 @@ func deleteDNS(w http.ResponseWriter, r *http.Request) {
 
 // CreateDNS - creates a DNS entry
 func CreateDNS(entry models.DNSEntry) (models.DNSEntry, error) {
-
-	data, err := json.Marshal(&entry)
-	if err != nil {
-		return models.DNSEntry{}, err
-	}
-	key, err := logic.GetRecordKey(entry.Name, entry.Network)
-	if err != nil {
-		return models.DNSEntry{}, err
-	}
-	err = database.Insert(key, string(data), database.DNS_TABLE_NAME)
-
-	return entry, err
+	return logic.CreateDNS(entry)
 }
 
 // GetDNSEntry - gets a DNS entry
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic/events"
+	"github.com/gravitl/netmaker/models"
+)
+
+// EVENT_STREAM_KEEPALIVE - how often an idle event stream is sent a comment to keep proxies from closing it
+const EVENT_STREAM_KEEPALIVE = 30 * time.Second
+
+func eventHandlers(r *mux.Router) {
+	r.HandleFunc("/api/events", securityCheck(false, http.HandlerFunc(streamEvents))).Methods("GET")
+}
+
+// streamEvents - streams server-sent events of the networks the caller has access to,
+// resuming after the Last-Event-ID header or lasteventid query parameter if given
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		returnErrorResponse(w, r, formatError(errors.New("streaming is not supported"), "internal"))
+		return
+	}
+	var networks []string
+	if err := json.Unmarshal([]byte(r.Header.Get("networks")), &networks); err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	all := len(networks) > 0 && networks[0] == ALL_NETWORK_ACCESS
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lasteventid")
+	}
+	subscription, replay, resumed := events.Subscribe(all, networks, lastEventID)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	logger.Log(2, r.Header.Get("user"), "opened event stream for", subscription.String())
+	if !resumed {
+		writeEvent(w, models.Event{Type: events.STREAM_RESET, Timestamp: time.Now().Unix()})
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(EVENT_STREAM_KEEPALIVE)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			writeEvent(w, event)
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent - writes an event in the server-sent events format
+func writeEvent(w http.ResponseWriter, event models.Event) {
+	data, err := json.Marshal(&event)
+	if err != nil {
+		logger.Log(1, "failed to encode event", event.ID, err.Error())
+		return
+	}
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
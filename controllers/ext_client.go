@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
@@ -48,6 +49,7 @@ func getNetworkExtClients(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	extclients = ownedExtClients(r, params["network"], extclients)
 
 	//Returns all the extclients in JSON format
 	w.WriteHeader(http.StatusOK)
@@ -60,6 +62,11 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	query, err := parseExtClientQuery(r)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
 	headerNetworks := r.Header.Get("networks")
 	networksSlice := []string{}
 	marshalErr := json.Unmarshal([]byte(headerNetworks), &networksSlice)
@@ -68,7 +75,7 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	clients := []models.ExtClient{}
-	err := errors.New("Networks Error")
+	err = errors.New("Networks Error")
 	if networksSlice[0] == ALL_NETWORK_ACCESS {
 		clients, err = functions.GetAllExtClients()
 		if err != nil && !database.IsEmptyRecord(err) {
@@ -79,12 +86,18 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 		for _, network := range networksSlice {
 			extclients, err := logic.GetNetworkExtClients(network)
 			if err == nil {
-				clients = append(clients, extclients...)
+				clients = append(clients, ownedExtClients(r, network, extclients)...)
 			}
 		}
 	}
+	clients, page, err := logic.QueryExtClients(clients, query)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
 
 	//Return all the extclients in JSON format
+	setPageHeaders(w, page)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(clients)
 }
@@ -152,13 +165,7 @@ func getExtClientConf(w http.ResponseWriter, r *http.Request) {
 		keepalive = "PersistentKeepalive = " + strconv.Itoa(int(network.DefaultKeepalive))
 	}
 	gwendpoint := gwnode.Endpoint + ":" + strconv.Itoa(int(gwnode.ListenPort))
-	newAllowedIPs := network.AddressRange
-	if newAllowedIPs != "" {
-		newAllowedIPs += ","
-	}
-	if network.AddressRange6 != "" {
-		newAllowedIPs += network.AddressRange6
-	}
+	newAllowedIPs := strings.Join(append(network.AddressRanges(), network.AddressRanges6()...), ",")
 	if egressGatewayRanges, err := logic.GetEgressRangesOnNetwork(&client); err == nil {
 		for _, egressGatewayRange := range egressGatewayRanges {
 			newAllowedIPs += "," + egressGatewayRange
@@ -246,6 +253,7 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 	var extclient models.ExtClient
 	extclient.Network = networkName
 	extclient.IngressGatewayID = nodeid
+	extclient.OwnerID = r.Header.Get("user")
 	node, err := logic.GetNodeByID(nodeid)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
@@ -263,6 +271,7 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_CREATE, "extclient", extclient.ClientID, networkName, nil, &extclient)
 	logger.Log(0, r.Header.Get("user"), "created new ext client on network", networkName)
 	w.WriteHeader(http.StatusOK)
 	err = mq.PublishExtPeerUpdate(&node)
@@ -294,12 +303,18 @@ func updateExtClient(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	if !canManageExtClients(r, params["network"]) {
+		// owners may rename their clients, enabling them is left to those managing the network
+		newExtClient.Enabled = oldExtClient.Enabled
+	}
 	var changedEnabled = newExtClient.Enabled != oldExtClient.Enabled // indicates there was a change in enablement
+	var before = oldExtClient
 	newclient, err := logic.UpdateExtClient(newExtClient.ClientID, params["network"], newExtClient.Enabled, &oldExtClient)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "extclient", before.ClientID, params["network"], &before, newclient)
 	logger.Log(0, r.Header.Get("user"), "updated ext client", newExtClient.ClientID)
 	if changedEnabled { // need to send a peer update to the ingress node as enablement of one of it's clients has changed
 		if ingressNode, err := logic.GetNodeByID(newclient.IngressGatewayID); err == nil {
@@ -340,6 +355,7 @@ func deleteExtClient(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_DELETE, "extclient", extclient.ClientID, params["network"], &extclient, nil)
 
 	err = mq.PublishExtPeerUpdate(&ingressnode)
 	if err != nil {
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/functions"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/gravitl/netmaker/tls"
+)
+
+// statuses reported by the health probes
+const (
+	HEALTH_OK       = "ok"
+	HEALTH_FAILED   = "failed"
+	HEALTH_DISABLED = "disabled"
+)
+
+// healthCheck - a dependency of the server, checked by the readiness probe
+type healthCheck struct {
+	name    string
+	enabled func() bool
+	check   func() error
+}
+
+var healthChecks = []healthCheck{
+	{name: "database", check: database.Ping},
+	{name: "rootca", check: checkRootCA},
+	{name: "mq", enabled: servercfg.IsMessageQueueBackend, check: mq.CheckConnection},
+	{name: "dns", enabled: servercfg.IsDNSMode, check: logic.CheckDNSConfigWritable},
+}
+
+func healthHandlers(r *mux.Router) {
+	r.HandleFunc("/api/health/live", http.HandlerFunc(getLiveness)).Methods("GET")
+	r.HandleFunc("/api/health/ready", http.HandlerFunc(getReadiness)).Methods("GET")
+}
+
+// getLiveness - passes whenever the server answers, dependencies are left to readiness
+// as restarting the server does not bring back a database or broker that is down
+func getLiveness(w http.ResponseWriter, r *http.Request) {
+	returnHealth(w, models.HealthStatus{Status: HEALTH_OK, Components: make(map[string]models.ComponentHealth)})
+}
+
+// getReadiness - fails when any enabled dependency is unavailable
+func getReadiness(w http.ResponseWriter, r *http.Request) {
+	returnHealth(w, runHealthChecks())
+}
+
+// runHealthChecks - checks every enabled dependency, logging why any failed
+func runHealthChecks() models.HealthStatus {
+	health := models.HealthStatus{Status: HEALTH_OK, Components: make(map[string]models.ComponentHealth)}
+	for _, healthCheck := range healthChecks {
+		if healthCheck.enabled != nil && !healthCheck.enabled() {
+			health.Components[healthCheck.name] = models.ComponentHealth{Status: HEALTH_DISABLED}
+			continue
+		}
+		if err := healthCheck.check(); err != nil {
+			logger.Log(1, "health check of", healthCheck.name, "failed:", err.Error())
+			health.Components[healthCheck.name] = models.ComponentHealth{Status: HEALTH_FAILED}
+			health.Status = HEALTH_FAILED
+			continue
+		}
+		health.Components[healthCheck.name] = models.ComponentHealth{Status: HEALTH_OK}
+	}
+	return health
+}
+
+func returnHealth(w http.ResponseWriter, health models.HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if health.Status != HEALTH_OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// checkRootCA - checks the root CA nodes are issued certificates from is present and unexpired
+func checkRootCA() error {
+	ca, err := tls.ReadCert(functions.GetNetmakerPath() + ncutils.GetSeparator() + "root.pem")
+	if err != nil {
+		return err
+	}
+	if time.Now().After(ca.NotAfter) {
+		return errors.New("root CA expired on " + ca.NotAfter.String())
+	}
+	return nil
+}
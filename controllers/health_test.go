@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthProbes(t *testing.T) {
+	database.InitializeDatabase()
+	os.Setenv("DNS_MODE", "off")
+	os.Setenv("MESSAGEQUEUE_BACKEND", "on")
+	defer os.Unsetenv("DNS_MODE")
+	defer os.Unsetenv("MESSAGEQUEUE_BACKEND")
+
+	t.Run("Liveness", func(t *testing.T) {
+		database.CloseDB()
+		defer database.InitializeDatabase()
+		w := httptest.NewRecorder()
+		getLiveness(w, httptest.NewRequest(http.MethodGet, "/api/health/live", nil))
+		assert.Equal(t, http.StatusOK, w.Code, "dependencies are left to readiness")
+		assert.Equal(t, HEALTH_FAILED, runHealthChecks().Components["database"].Status)
+	})
+	t.Run("Readiness", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		getReadiness(w, httptest.NewRequest(http.MethodGet, "/api/health/ready", nil))
+		health := runHealthChecks()
+		assert.Equal(t, HEALTH_OK, health.Components["database"].Status)
+		assert.Equal(t, HEALTH_FAILED, health.Components["mq"].Status, "no subscriber has connected")
+		assert.Equal(t, HEALTH_DISABLED, health.Components["dns"].Status)
+		assert.Equal(t, HEALTH_FAILED, health.Status)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+func ipamHandlers(r *mux.Router) {
+	r.HandleFunc("/api/networks/{networkname}/ipam", securityCheck(false, http.HandlerFunc(getIPAMReport))).Methods("GET")
+	r.HandleFunc("/api/networks/{networkname}/reservations", securityCheck(false, http.HandlerFunc(getReservations))).Methods("GET")
+	r.HandleFunc("/api/networks/{networkname}/reservations", securityCheck(false, http.HandlerFunc(createReservation))).Methods("POST")
+	r.HandleFunc("/api/networks/{networkname}/reservations/{address}", securityCheck(false, http.HandlerFunc(deleteReservation))).Methods("DELETE")
+}
+
+// getIPAMReport - returns the allocated, reserved and free addresses of a network and what holds each of them
+func getIPAMReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	report, err := logic.GetIPAMReport(mux.Vars(r)["networkname"])
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getReservations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	reservations, err := logic.GetReservations(mux.Vars(r)["networkname"])
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reservations)
+}
+
+// createReservation - reserves an address for a hostname, MAC address or node ID, or keeps an address or range
+// of them out of automatic allocation
+func createReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	netname := mux.Vars(r)["networkname"]
+	var reservation models.IPReservation
+	if err := json.NewDecoder(r.Body).Decode(&reservation); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	reservation.Network = netname
+	reservation, err := logic.CreateReservation(reservation)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	audit(r, models.AUDIT_CREATE, "reservation", reservation.Address, netname, nil, &reservation)
+	logger.Log(1, r.Header.Get("user"), "reserved address", reservation.Address, "on network", netname)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+func deleteReservation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	netname, address := params["networkname"], params["address"]
+	if err := logic.DeleteReservation(netname, address); err != nil {
+		returnErrorResponse(w, r, formatError(err, "notfound"))
+		return
+	}
+	audit(r, models.AUDIT_DELETE, "reservation", address, netname, nil, nil)
+	logger.Log(1, r.Header.Get("user"), "removed the reservation of", address, "on network", netname)
+	returnSuccessResponse(w, r, "removed the reservation of "+address)
+}
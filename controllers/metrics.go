@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/metrics"
+)
+
+var requestDuration = metrics.NewHistogramVec(
+	"netmaker_http_request_duration_seconds",
+	"Latency of REST requests by route, method and status code.",
+	metrics.DefaultBuckets,
+	"route", "method", "code",
+)
+
+func metricsHandlers(r *mux.Router) {
+	r.Use(instrumentRequests)
+	r.Handle("/metrics", securityCheck(true, metrics.Handler())).Methods("GET")
+}
+
+// instrumentRequests - records the latency of requests under the template of the matched route,
+// so node and network names do not each become a series
+func instrumentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		requestDuration.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder - remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// statusRecorder.Flush - keeps streamed responses such as server-sent events working
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -30,12 +30,15 @@ func networkHandlers(r *mux.Router) {
 	r.HandleFunc("/api/networks/{networkname}", securityCheck(false, http.HandlerFunc(updateNetwork))).Methods("PUT")
 	r.HandleFunc("/api/networks/{networkname}/nodelimit", securityCheck(true, http.HandlerFunc(updateNetworkNodeLimit))).Methods("PUT")
 	r.HandleFunc("/api/networks/{networkname}", securityCheck(true, http.HandlerFunc(deleteNetwork))).Methods("DELETE")
+	r.HandleFunc("/api/networks/{networkname}/export", securityCheck(false, http.HandlerFunc(exportNetwork))).Methods("GET")
+	r.HandleFunc("/api/networks/{networkname}/import", securityCheck(true, http.HandlerFunc(importNetwork))).Methods("POST")
 	r.HandleFunc("/api/networks/{networkname}/keyupdate", securityCheck(true, http.HandlerFunc(keyUpdate))).Methods("POST")
 	r.HandleFunc("/api/networks/{networkname}/keys", securityCheck(false, http.HandlerFunc(createAccessKey))).Methods("POST")
 	r.HandleFunc("/api/networks/{networkname}/keys", securityCheck(false, http.HandlerFunc(getAccessKeys))).Methods("GET")
 	r.HandleFunc("/api/networks/{networkname}/keys/{name}", securityCheck(false, http.HandlerFunc(deleteAccessKey))).Methods("DELETE")
 	// ACLs
 	r.HandleFunc("/api/networks/{networkname}/acls", securityCheck(true, http.HandlerFunc(updateNetworkACL))).Methods("PUT")
+	r.HandleFunc("/api/networks/{networkname}/acls/rules", securityCheck(true, http.HandlerFunc(updateNetworkACLRules))).Methods("PUT")
 	r.HandleFunc("/api/networks/{networkname}/acls", securityCheck(true, http.HandlerFunc(getNetworkACL))).Methods("GET")
 }
 
@@ -92,6 +95,7 @@ func getNetwork(w http.ResponseWriter, r *http.Request) {
 		network.AccessKeys = logic.RemoveKeySensitiveInfo(network.AccessKeys)
 	}
 	logger.Log(2, r.Header.Get("user"), "fetched network", netname)
+	setETag(w, network.Revision)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(network)
 }
@@ -100,11 +104,13 @@ func keyUpdate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
 	netname := params["networkname"]
+	before, _ := logic.GetParentNetwork(netname)
 	network, err := logic.KeyUpdate(netname)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "network", netname, netname, &before, &network)
 	logger.Log(2, r.Header.Get("user"), "updated key on network", netname)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(network)
@@ -135,6 +141,10 @@ func updateNetwork(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	if err = checkIfMatch(r, network.Revision); err != nil {
+		returnErrorResponse(w, r, formatError(err, "preconditionfailed"))
+		return
+	}
 	var newNetwork models.Network
 	err = json.NewDecoder(r.Body).Decode(&newNetwork)
 	if err != nil {
@@ -149,9 +159,10 @@ func updateNetwork(w http.ResponseWriter, r *http.Request) {
 
 	rangeupdate, localrangeupdate, holepunchupdate, err := logic.UpdateNetwork(&network, &newNetwork)
 	if err != nil {
-		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		returnErrorResponse(w, r, formatError(err, updateErrorType(err, "badrequest")))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "network", netname, netname, &network, &newNetwork)
 
 	if rangeupdate {
 		err = logic.UpdateNetworkNodeAddresses(network.NetID)
@@ -188,6 +199,7 @@ func updateNetwork(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Log(1, r.Header.Get("user"), "updated network", netname)
+	setETag(w, newNetwork.Revision)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(newNetwork)
 }
@@ -208,13 +220,16 @@ func updateNetworkNodeLimit(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewDecoder(r.Body).Decode(&networkChange)
 
 	if networkChange.NodeLimit != 0 {
+		before := network
 		network.NodeLimit = networkChange.NodeLimit
+		network.Revision++
 		data, err := json.Marshal(&network)
 		if err != nil {
 			returnErrorResponse(w, r, formatError(err, "badrequest"))
 			return
 		}
 		database.Insert(network.NetID, string(data), database.NETWORKS_TABLE_NAME)
+		audit(r, models.AUDIT_UPDATE, "network", netname, netname, &before, &network)
 		logger.Log(1, r.Header.Get("user"), "updated network node limit on", netname)
 	}
 	w.WriteHeader(http.StatusOK)
@@ -231,33 +246,64 @@ func updateNetworkACL(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	var before acls.ACLContainer
+	before, _ = before.Get(acls.ContainerID(netname))
 	_ = json.NewDecoder(r.Body).Decode(&networkACLChange)
 	newNetACL, err := networkACLChange.Save(acls.ContainerID(netname))
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "acls", netname, netname, before, newNetACL)
 	logger.Log(1, r.Header.Get("user"), "updated ACLs for network", netname)
+	publishACLUpdate(netname)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newNetACL)
+}
 
-	// send peer updates
-	if servercfg.IsMessageQueueBackend() {
-		serverNode, err := logic.GetNetworkServerLocal(netname)
-		if err != nil {
-			logger.Log(1, "failed to find server node after ACL update on", netname)
-		} else {
-			if err = logic.ServerUpdate(&serverNode, false); err != nil {
-				logger.Log(1, "failed to update server node after ACL update on", netname)
-			}
-			if err = mq.PublishPeerUpdate(&serverNode); err != nil {
-				logger.Log(0, "failed to publish peer update after ACL update on", netname)
-			}
-		}
+// updateNetworkACLRules - allows or denies nodes reaching each other, each side of a rule
+// given as node ids or label selectors
+func updateNetworkACLRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	netname := params["networkname"]
+	var rules []models.DeclaredACL
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
 	}
-
+	var before acls.ACLContainer
+	before, _ = before.Get(acls.ContainerID(netname))
+	newNetACL, err := logic.SetACLRules(netname, rules)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	audit(r, models.AUDIT_UPDATE, "acls", netname, netname, before, newNetACL)
+	logger.Log(1, r.Header.Get("user"), "updated ACL rules for network", netname)
+	publishACLUpdate(netname)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(newNetACL)
 }
 
+// publishACLUpdate - sends peer updates after the ACLs of a network change
+func publishACLUpdate(netname string) {
+	if !servercfg.IsMessageQueueBackend() {
+		return
+	}
+	serverNode, err := logic.GetNetworkServerLocal(netname)
+	if err != nil {
+		logger.Log(1, "failed to find server node after ACL update on", netname)
+		return
+	}
+	if err = logic.ServerUpdate(&serverNode, false); err != nil {
+		logger.Log(1, "failed to update server node after ACL update on", netname)
+	}
+	if err = mq.PublishPeerUpdate(&serverNode); err != nil {
+		logger.Log(0, "failed to publish peer update after ACL update on", netname)
+	}
+}
+
 func getNetworkACL(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
@@ -281,6 +327,7 @@ func deleteNetwork(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	network := params["networkname"]
+	before, _ := logic.GetParentNetwork(network)
 	err := logic.DeleteNetwork(network)
 	if err != nil {
 		errtype := "badrequest"
@@ -290,6 +337,7 @@ func deleteNetwork(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, errtype))
 		return
 	}
+	audit(r, models.AUDIT_DELETE, "network", network, network, &before, nil)
 	logger.Log(1, r.Header.Get("user"), "deleted network", network)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode("success")
@@ -331,11 +379,56 @@ func createNetwork(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	audit(r, models.AUDIT_CREATE, "network", network.NetID, network.NetID, nil, &network)
 	logger.Log(1, r.Header.Get("user"), "created network", network.NetID)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(network)
 }
 
+// exportNetwork - returns a network as a declaration, to be imported as a new network or applied elsewhere
+func exportNetwork(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	netname := mux.Vars(r)["networkname"]
+	export, err := logic.ExportNetwork(netname)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	logger.Log(2, r.Header.Get("user"), "exported network", netname)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(export)
+}
+
+// importNetwork - creates the network named in the path from the export of another network
+func importNetwork(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	netname := mux.Vars(r)["networkname"]
+	var request models.NetworkImport
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	actorType, actor := auditActor(r)
+	result, err := logic.ImportNetwork(netname, request, models.AuditEntry{ActorType: actorType, Actor: actor, Source: "api"})
+	if err != nil {
+		if len(result.Changes) > 0 {
+			logic.DeleteNetwork(netname)
+		}
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	if servercfg.IsClientMode() != "off" {
+		if _, err = logic.ServerJoin(&result.Network); err != nil {
+			logic.DeleteNetwork(netname)
+			returnErrorResponse(w, r, formatError(err, "internal"))
+			return
+		}
+	}
+	logger.Log(1, r.Header.Get("user"), "imported network", netname, "from", request.Export.Network.NetID)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
 // BEGIN KEY MANAGEMENT SECTION
 func createAccessKey(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -358,6 +451,7 @@ func createAccessKey(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_CREATE, "accesskey", key.Name, netname, nil, &key)
 	logger.Log(1, r.Header.Get("user"), "created access key", accesskey.Name, "on", netname)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(key)
@@ -387,11 +481,20 @@ func deleteAccessKey(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	keyname := params["name"]
 	netname := params["networkname"]
+	var before *models.AccessKey
+	if network, err := logic.GetParentNetwork(netname); err == nil {
+		for i := range network.AccessKeys {
+			if network.AccessKeys[i].Name == keyname {
+				before = &network.AccessKeys[i]
+			}
+		}
+	}
 	err := logic.DeleteKey(keyname, netname)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_DELETE, "accesskey", keyname, netname, before, nil)
 	logger.Log(1, r.Header.Get("user"), "deleted access key", keyname, "on network,", netname)
 	w.WriteHeader(http.StatusOK)
 }
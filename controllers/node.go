@@ -34,6 +34,8 @@ func nodeHandlers(r *mux.Router) {
 	r.HandleFunc("/api/nodes/{network}", nodeauth(http.HandlerFunc(createNode))).Methods("POST")
 	r.HandleFunc("/api/nodes/adm/{network}/lastmodified", authorize(false, true, "network", http.HandlerFunc(getLastModified))).Methods("GET")
 	r.HandleFunc("/api/nodes/adm/{network}/authenticate", authenticate).Methods("POST")
+	r.HandleFunc("/api/deletednodes", securityCheck(true, http.HandlerFunc(getDeletedNodes))).Methods("GET")
+	r.HandleFunc("/api/deletednodes/{nodeid}/restore", securityCheck(true, http.HandlerFunc(restoreDeletedNode))).Methods("POST")
 }
 
 func authenticate(response http.ResponseWriter, request *http.Request) {
@@ -67,6 +69,18 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 			returnErrorResponse(response, request, errorResponse)
 			return
 		} else {
+			ip := clientIP(request)
+			subject := authRequest.ID
+			if subject == "" {
+				subject = authRequest.MacAddress
+			}
+			attempt, wait, err := logic.CheckLogin(logic.LOGIN_KIND_NODE, subject, ip)
+			if err != nil {
+				returnLoginLocked(response, request, wait, err)
+				return
+			}
+			// a login that does not succeed counts as failed
+			defer attempt.Failed()
 
 			collection, err := database.FetchRecords(database.NODES_TABLE_NAME)
 			if err != nil {
@@ -98,6 +112,7 @@ func authenticate(response http.ResponseWriter, request *http.Request) {
 				returnErrorResponse(response, request, errorResponse)
 				return
 			} else {
+				attempt.Succeeded()
 				tokenString, _ := logic.CreateJWT(authRequest.ID, authRequest.MacAddress, result.Network)
 
 				if tokenString == "" {
@@ -225,11 +240,17 @@ func authorize(nodesAllowed, networkCheck bool, authNetwork string, next http.Ha
 			if nodesAllowed {
 				// TODO --- should ensure that node is only operating on itself
 				if _, _, _, err := logic.VerifyToken(authToken); err == nil {
+					// the user header is only trusted when set by the auth middleware
+					r.Header.Del("user")
 					next.ServeHTTP(w, r)
 					return
 				}
 			}
 
+			if err := checkAPITokenScope(r, bearerToken); err != nil {
+				returnErrorResponse(w, r, formatError(err, "forbidden"))
+				return
+			}
 			var isAuthorized = false
 			var nodeID = ""
 			username, networks, isadmin, errN := logic.VerifyUserToken(authToken)
@@ -240,17 +261,20 @@ func authorize(nodesAllowed, networkCheck bool, authNetwork string, next http.Ha
 				returnErrorResponse(w, r, errorResponse)
 				return
 			}
-			isnetadmin := isadmin
+			// the role of the user on the network of the route decides if it may be called
+			allowed, err := checkRoutePermission(r, username, isadmin, networks)
+			if err != nil {
+				returnErrorResponse(w, r, formatError(err, "forbidden"))
+				return
+			}
+			allowedJson, _ := json.Marshal(allowed)
+			r.Header.Set("networks", string(allowedJson))
+			isnetadmin := true
 			if errN == nil && isadmin {
 				nodeID = "mastermac"
 				isAuthorized = true
 				r.Header.Set("ismasterkey", "yes")
 			}
-			if !isadmin && params["network"] != "" {
-				if logic.StringSliceContains(networks, params["network"]) {
-					isnetadmin = true
-				}
-			}
 			//The mastermac (login with masterkey from config) can do everything!! May be dangerous.
 			if nodeID == "mastermac" {
 				isAuthorized = true
@@ -316,14 +340,25 @@ func getNetworkNodes(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	networkName := params["network"]
 
-	nodes, err := logic.GetNetworkNodes(networkName)
+	query, err := parseNodeQuery(r)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	nodes, err = logic.GetNetworkNodes(networkName)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	nodes, page, err := logic.QueryNodes(nodes, query)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
 
 	//Returns all the nodes in JSON format
 	logger.Log(2, r.Header.Get("user"), "fetched nodes on network", networkName)
+	setPageHeaders(w, page)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(nodes)
 }
@@ -332,35 +367,44 @@ func getNetworkNodes(w http.ResponseWriter, r *http.Request) {
 //Not quite sure if this is necessary. Probably necessary based on front end but may want to review after iteration 1 if it's being used or not
 func getAllNodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	user, err := logic.GetUser(r.Header.Get("user"))
-	if err != nil && r.Header.Get("ismasterkey") != "yes" {
-		returnErrorResponse(w, r, formatError(err, "internal"))
+	query, err := parseNodeQuery(r)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	// the networks set by authorize are narrowed by the caller's token, so an admin's scoped token sees only its networks
+	var networks []string
+	json.Unmarshal([]byte(r.Header.Get("networks")), &networks)
 	var nodes []models.Node
-	if user.IsAdmin || r.Header.Get("ismasterkey") == "yes" {
+	if len(networks) > 0 && networks[0] == ALL_NETWORK_ACCESS {
 		nodes, err = logic.GetAllNodes()
 		if err != nil {
 			returnErrorResponse(w, r, formatError(err, "internal"))
 			return
 		}
 	} else {
-		nodes, err = getUsersNodes(user)
+		nodes, err = getUsersNodes(networks)
 		if err != nil {
 			returnErrorResponse(w, r, formatError(err, "internal"))
 			return
 		}
 	}
+	nodes, page, err := logic.QueryNodes(nodes, query)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
 	//Return all the nodes in JSON format
 	logger.Log(3, r.Header.Get("user"), "fetched all nodes they have access to")
+	setPageHeaders(w, page)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(nodes)
 }
 
-func getUsersNodes(user models.User) ([]models.Node, error) {
+func getUsersNodes(networks []string) ([]models.Node, error) {
 	var nodes []models.Node
 	var err error
-	for _, networkName := range user.Networks {
+	for _, networkName := range networks {
 		tmpNodes, err := logic.GetNetworkNodes(networkName)
 		if err != nil {
 			continue
@@ -395,6 +439,7 @@ func getNode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Log(2, r.Header.Get("user"), "fetched node", params["nodeid"])
+	setETag(w, node.Revision)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
@@ -513,6 +558,15 @@ func createNode(w http.ResponseWriter, r *http.Request) {
 		Peers: peerUpdate.Peers,
 	}
 
+	logic.Audit(models.AuditEntry{
+		ActorType:  models.AUDIT_ACTOR_NODE,
+		Actor:      node.ID,
+		Source:     "api",
+		Action:     models.AUDIT_CREATE,
+		TargetType: "node",
+		Target:     node.ID,
+		Network:    node.Network,
+	}, nil, &node)
 	logger.Log(1, r.Header.Get("user"), "created new node", node.Name, "on network", node.Network)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -525,11 +579,13 @@ func uncordonNode(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	w.Header().Set("Content-Type", "application/json")
 	var nodeid = params["nodeid"]
+	before, _ := logic.GetNodeByID(nodeid)
 	node, err := logic.UncordonNode(nodeid)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 	logger.Log(1, r.Header.Get("user"), "uncordoned node", node.Name)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode("SUCCESS")
@@ -537,6 +593,41 @@ func uncordonNode(w http.ResponseWriter, r *http.Request) {
 	runUpdates(&node, false)
 }
 
+// getDeletedNodes - lists deleted nodes that can still be restored, optionally of a single network
+func getDeletedNodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	nodes, err := logic.GetDeletedNodes(r.URL.Query().Get("network"))
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	logger.Log(2, r.Header.Get("user"), "fetched deleted nodes")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// restoreDeletedNode - re-inserts a deleted node with its previous addresses and ACL
+func restoreDeletedNode(w http.ResponseWriter, r *http.Request) {
+	var params = mux.Vars(r)
+	w.Header().Set("Content-Type", "application/json")
+	node, err := logic.RestoreDeletedNode(params["nodeid"])
+	if err != nil {
+		errType := "badrequest"
+		if database.IsEmptyRecord(err) {
+			errType = "notfound"
+		}
+		returnErrorResponse(w, r, formatError(err, errType))
+		return
+	}
+	audit(r, models.AUDIT_CREATE, "node", node.ID, node.Network, nil, &node)
+	logger.Log(1, r.Header.Get("user"), "restored deleted node", node.Name, node.ID, "on network", node.Network)
+	setETag(w, node.Revision)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(node)
+
+	runUpdates(&node, true)
+}
+
 // == EGRESS ==
 
 func createEgressGateway(w http.ResponseWriter, r *http.Request) {
@@ -550,11 +641,13 @@ func createEgressGateway(w http.ResponseWriter, r *http.Request) {
 	}
 	gateway.NetID = params["network"]
 	gateway.NodeID = params["nodeid"]
+	before, _ := logic.GetNodeByID(gateway.NodeID)
 	node, err := logic.CreateEgressGateway(gateway)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 
 	logger.Log(1, r.Header.Get("user"), "created egress gateway on node", gateway.NodeID, "on network", gateway.NetID)
 	w.WriteHeader(http.StatusOK)
@@ -568,11 +661,13 @@ func deleteEgressGateway(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	nodeid := params["nodeid"]
 	netid := params["network"]
+	before, _ := logic.GetNodeByID(nodeid)
 	node, err := logic.DeleteEgressGateway(netid, nodeid)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 
 	logger.Log(1, r.Header.Get("user"), "deleted egress gateway", nodeid, "on network", netid)
 	w.WriteHeader(http.StatusOK)
@@ -588,11 +683,13 @@ func createIngressGateway(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	nodeid := params["nodeid"]
 	netid := params["network"]
+	before, _ := logic.GetNodeByID(nodeid)
 	node, err := logic.CreateIngressGateway(netid, nodeid)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 
 	logger.Log(1, r.Header.Get("user"), "created ingress gateway on node", nodeid, "on network", netid)
 	w.WriteHeader(http.StatusOK)
@@ -605,11 +702,13 @@ func deleteIngressGateway(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
 	nodeid := params["nodeid"]
+	before, _ := logic.GetNodeByID(nodeid)
 	node, err := logic.DeleteIngressGateway(params["network"], nodeid)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 
 	logger.Log(1, r.Header.Get("user"), "deleted ingress gateway", nodeid)
 	w.WriteHeader(http.StatusOK)
@@ -630,6 +729,10 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	if err = checkIfMatch(r, node.Revision); err != nil {
+		returnErrorResponse(w, r, formatError(err, "preconditionfailed"))
+		return
+	}
 
 	var newNode models.Node
 	// we decode our body request params
@@ -660,9 +763,10 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 
 	err = logic.UpdateNode(&node, &newNode)
 	if err != nil {
-		returnErrorResponse(w, r, formatError(err, "internal"))
+		returnErrorResponse(w, r, formatError(err, updateErrorType(err, "internal")))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &node, &newNode)
 	if relayupdate {
 		updatenodes := logic.UpdateRelay(node.Network, node.RelayAddrs, newNode.RelayAddrs)
 		if err = logic.NetworkNodesUpdatePullChanges(node.Network); err != nil {
@@ -680,6 +784,7 @@ func updateNode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Log(1, r.Header.Get("user"), "updated node", node.ID, "on network", node.Network)
+	setETag(w, newNode.Revision)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(newNode)
 
@@ -710,6 +815,7 @@ func deleteNode(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_DELETE, "node", node.ID, node.Network, &node, nil)
 	returnSuccessResponse(w, r, nodeid+" deleted.")
 
 	logger.Log(1, r.Header.Get("user"), "Deleted node", nodeid, "from network", params["network"])
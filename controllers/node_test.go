@@ -2,6 +2,7 @@ package controller
 
 import (
 	"testing"
+	"time"
 
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logic"
@@ -123,6 +124,121 @@ func TestUncordonNode(t *testing.T) {
 	})
 
 }
+func TestUpdateNodeRevision(t *testing.T) {
+	database.InitializeDatabase()
+	deleteAllNetworks()
+	createNet()
+	node := createTestNode()
+	stale, err := logic.GetNodeByID(node.ID)
+	assert.Nil(t, err)
+	t.Run("Update", func(t *testing.T) {
+		current, err := logic.GetNodeByID(node.ID)
+		assert.Nil(t, err)
+		newNode := current
+		newNode.Name = "renamed"
+		err = logic.UpdateNode(&current, &newNode)
+		assert.Nil(t, err)
+		assert.Equal(t, stale.Revision+1, newNode.Revision)
+	})
+	t.Run("StaleUpdate", func(t *testing.T) {
+		newNode := stale
+		newNode.Name = "stale"
+		err := logic.UpdateNode(&stale, &newNode)
+		assert.ErrorIs(t, err, logic.ErrRevisionConflict)
+		current, err := logic.GetNodeByID(node.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "renamed", current.Name)
+	})
+	deleteAllNodes()
+}
+
+func TestDeletedNodes(t *testing.T) {
+	database.InitializeDatabase()
+	deleteAllNetworks()
+	createNet()
+	database.DeleteAllRecords(database.DELETED_NODES_TABLE_NAME)
+	node := createTestNode()
+	t.Run("Restore", func(t *testing.T) {
+		err := logic.DeleteNodeByID(node, false)
+		assert.Nil(t, err)
+		deleted, err := logic.GetDeletedNodes("skynet")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(deleted))
+		restored, err := logic.RestoreDeletedNode(node.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, node.Address, restored.Address)
+		_, err = nodeacls.FetchNodeACL(nodeacls.NetworkID("skynet"), nodeacls.NodeID(node.ID))
+		assert.Nil(t, err)
+		deleted, err = logic.GetDeletedNodes("")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(deleted))
+	})
+	t.Run("AddressTaken", func(t *testing.T) {
+		err := logic.DeleteNodeByID(node, false)
+		assert.Nil(t, err)
+		other := models.Node{PublicKey: "DM5qhLAE20PG9BbfBCger+Ac9D2NDOwCtY1rbYDLf34=", Name: "othernode", Endpoint: "10.0.0.2", MacAddress: "01:02:03:04:05:07", Password: "password", Network: "skynet", OS: "linux"}
+		err = logic.CreateNode(&other)
+		assert.Nil(t, err)
+		assert.Equal(t, node.Address, other.Address)
+		_, err = logic.RestoreDeletedNode(node.ID)
+		assert.NotNil(t, err)
+		err = logic.DeleteNodeByID(&other, true)
+		assert.Nil(t, err)
+	})
+	t.Run("AddressReserved", func(t *testing.T) {
+		_, err := logic.CreateReservation(models.IPReservation{Network: "skynet", Address: node.Address, Hostname: "othernode"})
+		assert.Nil(t, err)
+		_, err = logic.RestoreDeletedNode(node.ID)
+		assert.NotNil(t, err)
+		err = logic.DeleteReservation("skynet", node.Address)
+		assert.Nil(t, err)
+		restored, err := logic.RestoreDeletedNode(node.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, node.Address, restored.Address)
+		err = logic.DeleteNodeByID(&restored, false)
+		assert.Nil(t, err)
+	})
+	t.Run("Purge", func(t *testing.T) {
+		purged, err := logic.PurgeDeletedNodes(time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, purged)
+		purged, err = logic.PurgeDeletedNodes(-time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, purged)
+		_, err = logic.RestoreDeletedNode(node.ID)
+		assert.True(t, database.IsEmptyRecord(err))
+	})
+	deleteAllNodes()
+}
+
+func TestNodeLabels(t *testing.T) {
+	database.InitializeDatabase()
+	deleteAllNetworks()
+	createNet()
+	t.Run("Invalid", func(t *testing.T) {
+		node := models.Node{PublicKey: "DM5qhLAE20PG9BbfBCger+Ac9D2NDOwCtY1rbYDLf34=", Name: "labeled", Endpoint: "10.0.0.1", MacAddress: "01:02:03:04:05:08", Password: "password", Network: "skynet", OS: "linux", Labels: models.Labels{"role,site": "db"}}
+		err := logic.CreateNode(&node)
+		assert.NotNil(t, err)
+	})
+	t.Run("Update", func(t *testing.T) {
+		node := createTestNode()
+		current, err := logic.GetNodeByID(node.ID)
+		assert.Nil(t, err)
+		labeled := current
+		labeled.Labels = models.Labels{"role": "db"}
+		assert.Nil(t, logic.UpdateNode(&current, &labeled))
+		current, _ = logic.GetNodeByID(node.ID)
+		renamed := models.Node{Name: "renamed", Revision: current.Revision}
+		assert.Nil(t, logic.UpdateNode(&current, &renamed))
+		assert.Equal(t, models.Labels{"role": "db"}, renamed.Labels)
+		current, _ = logic.GetNodeByID(node.ID)
+		cleared := models.Node{Labels: models.Labels{}, Revision: current.Revision}
+		assert.Nil(t, logic.UpdateNode(&current, &cleared))
+		assert.Empty(t, cleared.Labels)
+	})
+	deleteAllNodes()
+}
+
 func TestValidateEgressGateway(t *testing.T) {
 	var gateway models.EgressGatewayRequest
 	t.Run("EmptyRange", func(t *testing.T) {
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+// route permissions that are not granted by a network role
+const (
+	// PERMISSION_PUBLIC - routes without user authentication, or authenticated by nodes or access keys
+	PERMISSION_PUBLIC = "public"
+	// PERMISSION_AUTHENTICATED - routes any logged in user may call, they check the user themselves
+	PERMISSION_AUTHENTICATED = "authenticated"
+	// PERMISSION_SERVER_ADMIN - routes only admins and the master key may call
+	PERMISSION_SERVER_ADMIN = "server-admin"
+)
+
+// routePermission - the permission a user needs to call a route, on the network in the route
+// or, for routes without a network, on at least one network with results limited to those networks
+type routePermission struct {
+	permission string
+	// ownExtClient - users without PERMISSION_EXT_CLIENT_MANAGE may only act on ext clients they own
+	ownExtClient bool
+}
+
+// routePermissions - the permission of every route by method and path template,
+// routes missing from here are refused to everyone but admins
+var routePermissions = map[string]routePermission{
+	"GET /api/health/live":                       {permission: PERMISSION_PUBLIC},
+	"GET /api/health/ready":                      {permission: PERMISSION_PUBLIC},
+	"GET /api/users/adm/hasadmin":                {permission: PERMISSION_PUBLIC},
+	"POST /api/users/adm/createadmin":            {permission: PERMISSION_PUBLIC},
+	"POST /api/users/adm/authenticate":           {permission: PERMISSION_PUBLIC},
+	"GET /api/oauth/login":                       {permission: PERMISSION_PUBLIC},
+	"GET /api/oauth/callback":                    {permission: PERMISSION_PUBLIC},
+	"POST /api/nodes/{network}":                  {permission: PERMISSION_PUBLIC},
+	"POST /api/nodes/adm/{network}/authenticate": {permission: PERMISSION_PUBLIC},
+
+	"GET /api/server/getconfig":                     {permission: PERMISSION_AUTHENTICATED},
+	"GET /api/users/{username}":                     {permission: PERMISSION_AUTHENTICATED},
+	"PUT /api/users/{username}":                     {permission: PERMISSION_AUTHENTICATED},
+	"GET /api/users/{username}/tokens":              {permission: PERMISSION_AUTHENTICATED},
+	"POST /api/users/{username}/tokens":             {permission: PERMISSION_AUTHENTICATED},
+	"DELETE /api/users/{username}/tokens/{tokenid}": {permission: PERMISSION_AUTHENTICATED},
+	"PUT /api/users/{username}/roles/{network}":     {permission: logic.PERMISSION_NETWORK_OWNER},
+	"DELETE /api/users/{username}/roles/{network}":  {permission: logic.PERMISSION_NETWORK_OWNER},
+
+	"POST /api/apply":    {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/audit":     {permission: PERMISSION_SERVER_ADMIN},
+	"GET /metrics":       {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/dns":       {permission: PERMISSION_SERVER_ADMIN},
+	"POST /api/networks": {permission: PERMISSION_SERVER_ADMIN},
+	"POST /api/networks/{networkname}/import":         {permission: PERMISSION_SERVER_ADMIN},
+	"PUT /api/networks/{networkname}/nodelimit":       {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/deletednodes":                           {permission: PERMISSION_SERVER_ADMIN},
+	"POST /api/deletednodes/{nodeid}/restore":         {permission: PERMISSION_SERVER_ADMIN},
+	"DELETE /api/server/removenetwork/{network}":      {permission: PERMISSION_SERVER_ADMIN},
+	"POST /api/server/register":                       {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/server/backup":                          {permission: PERMISSION_SERVER_ADMIN},
+	"POST /api/server/restore":                        {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/server/cache":                           {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/server/cache/check":                     {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/server/conflicts":                       {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/users/adm/lockouts":                     {permission: PERMISSION_SERVER_ADMIN},
+	"DELETE /api/users/adm/lockouts/{kind}/{subject}": {permission: PERMISSION_SERVER_ADMIN},
+	"PUT /api/users/networks/{username}":              {permission: PERMISSION_SERVER_ADMIN},
+	"PUT /api/users/{username}/adm":                   {permission: PERMISSION_SERVER_ADMIN},
+	"POST /api/users/{username}":                      {permission: PERMISSION_SERVER_ADMIN},
+	"DELETE /api/users/{username}":                    {permission: PERMISSION_SERVER_ADMIN},
+	"GET /api/users":                                  {permission: PERMISSION_SERVER_ADMIN},
+
+	"GET /api/events":                                {permission: logic.PERMISSION_VIEW},
+	"GET /api/networks":                              {permission: logic.PERMISSION_VIEW},
+	"GET /api/networks/{networkname}":                {permission: logic.PERMISSION_VIEW},
+	"PUT /api/networks/{networkname}":                {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"DELETE /api/networks/{networkname}":             {permission: logic.PERMISSION_NETWORK_OWNER},
+	"GET /api/networks/{networkname}/export":         {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"POST /api/networks/{networkname}/keyupdate":     {permission: logic.PERMISSION_NETWORK_OWNER},
+	"POST /api/networks/{networkname}/keys":          {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"GET /api/networks/{networkname}/keys":           {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"DELETE /api/networks/{networkname}/keys/{name}": {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"PUT /api/networks/{networkname}/acls":           {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"PUT /api/networks/{networkname}/acls/rules":     {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"GET /api/networks/{networkname}/acls":           {permission: logic.PERMISSION_VIEW},
+
+	"GET /api/networks/{networkname}/ipam":                      {permission: logic.PERMISSION_VIEW},
+	"GET /api/networks/{networkname}/reservations":              {permission: logic.PERMISSION_VIEW},
+	"POST /api/networks/{networkname}/reservations":             {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"DELETE /api/networks/{networkname}/reservations/{address}": {permission: logic.PERMISSION_NETWORK_MANAGE},
+
+	"GET /api/nodes":                                     {permission: logic.PERMISSION_VIEW},
+	"GET /api/nodes/{network}":                           {permission: logic.PERMISSION_VIEW},
+	"GET /api/nodes/{network}/{nodeid}":                  {permission: logic.PERMISSION_VIEW},
+	"GET /api/nodes/adm/{network}/lastmodified":          {permission: logic.PERMISSION_VIEW},
+	"PUT /api/nodes/{network}/{nodeid}":                  {permission: logic.PERMISSION_NODE_OPERATE},
+	"DELETE /api/nodes/{network}/{nodeid}":               {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"POST /api/nodes/{network}/{nodeid}/createrelay":     {permission: logic.PERMISSION_NODE_OPERATE},
+	"DELETE /api/nodes/{network}/{nodeid}/deleterelay":   {permission: logic.PERMISSION_NODE_OPERATE},
+	"POST /api/nodes/{network}/{nodeid}/creategateway":   {permission: logic.PERMISSION_NODE_OPERATE},
+	"DELETE /api/nodes/{network}/{nodeid}/deletegateway": {permission: logic.PERMISSION_NODE_OPERATE},
+	"POST /api/nodes/{network}/{nodeid}/createingress":   {permission: logic.PERMISSION_NODE_OPERATE},
+	"DELETE /api/nodes/{network}/{nodeid}/deleteingress": {permission: logic.PERMISSION_NODE_OPERATE},
+	"POST /api/nodes/{network}/{nodeid}/approve":         {permission: logic.PERMISSION_NODE_OPERATE},
+
+	"GET /api/dns/adm/{network}/nodes":   {permission: logic.PERMISSION_VIEW},
+	"GET /api/dns/adm/{network}/custom":  {permission: logic.PERMISSION_VIEW},
+	"GET /api/dns/adm/{network}":         {permission: logic.PERMISSION_VIEW},
+	"POST /api/dns/{network}":            {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"DELETE /api/dns/{network}/{domain}": {permission: logic.PERMISSION_NETWORK_MANAGE},
+	"POST /api/dns/adm/pushdns":          {permission: logic.PERMISSION_NODE_OPERATE},
+
+	"GET /api/extclients":                             {permission: logic.PERMISSION_EXT_CLIENT_SELF},
+	"GET /api/extclients/{network}":                   {permission: logic.PERMISSION_EXT_CLIENT_SELF},
+	"POST /api/extclients/{network}/{nodeid}":         {permission: logic.PERMISSION_EXT_CLIENT_SELF},
+	"GET /api/extclients/{network}/{clientid}":        {permission: logic.PERMISSION_EXT_CLIENT_SELF, ownExtClient: true},
+	"GET /api/extclients/{network}/{clientid}/{type}": {permission: logic.PERMISSION_EXT_CLIENT_SELF, ownExtClient: true},
+	"PUT /api/extclients/{network}/{clientid}":        {permission: logic.PERMISSION_EXT_CLIENT_SELF, ownExtClient: true},
+	"DELETE /api/extclients/{network}/{clientid}":     {permission: logic.PERMISSION_EXT_CLIENT_SELF, ownExtClient: true},
+}
+
+var errNoRole = errors.New("your role does not allow this request")
+
+// checkRoutePermission - checks the roles of a user allow the matched route, admins may call any route
+// networks is what the user's token limits it to, for routes without a network the networks the user
+// holds the permission on are returned, so handlers can limit their results to them
+func checkRoutePermission(r *http.Request, username string, isadmin bool, networks []string) ([]string, error) {
+	if isadmin {
+		return []string{ALL_NETWORK_ACCESS}, nil
+	}
+	var key string
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ := route.GetPathTemplate()
+		key = r.Method + " " + template
+	}
+	required, ok := routePermissions[key]
+	if !ok || required.permission == PERMISSION_SERVER_ADMIN {
+		return nil, errNoRole
+	}
+	if required.permission == PERMISSION_PUBLIC || required.permission == PERMISSION_AUTHENTICATED {
+		return networks, nil
+	}
+	user, err := logic.GetReturnUser(username)
+	if err != nil {
+		return nil, errNoRole
+	}
+	tokenAllows := func(network string) bool {
+		return !usingAPIToken(r) || logic.StringSliceContains(networks, network)
+	}
+	var params = mux.Vars(r)
+	network := params["network"]
+	if network == "" {
+		network = params["networkname"]
+	}
+	if network == "" {
+		var allowed = []string{}
+		for _, permitted := range logic.NetworksWithPermission(&user, required.permission) {
+			if tokenAllows(permitted) {
+				allowed = append(allowed, permitted)
+			}
+		}
+		if len(allowed) == 0 {
+			return nil, errNoRole
+		}
+		return allowed, nil
+	}
+	if !tokenAllows(network) || !logic.HasNetworkPermission(&user, network, required.permission) {
+		return nil, errNoRole
+	}
+	if required.ownExtClient && !logic.HasNetworkPermission(&user, network, logic.PERMISSION_EXT_CLIENT_MANAGE) {
+		client, err := logic.GetExtClient(params["clientid"], network)
+		if err != nil || client.OwnerID != user.UserName {
+			return nil, errNoRole
+		}
+	}
+	return []string{network}, nil
+}
+
+// canManageExtClients - checks the caller may see and change every ext client of a network rather than only their own
+func canManageExtClients(r *http.Request, network string) bool {
+	var networks []string
+	json.Unmarshal([]byte(r.Header.Get("networks")), &networks)
+	if len(networks) > 0 && networks[0] == ALL_NETWORK_ACCESS {
+		return true
+	}
+	user, err := logic.GetReturnUser(r.Header.Get("user"))
+	return err == nil && logic.HasNetworkPermission(&user, network, logic.PERMISSION_EXT_CLIENT_MANAGE)
+}
+
+// ownedExtClients - the ext clients of a network the caller may see
+func ownedExtClients(r *http.Request, network string, clients []models.ExtClient) []models.ExtClient {
+	if canManageExtClients(r, network) {
+		return clients
+	}
+	var owned = []models.ExtClient{}
+	for _, client := range clients {
+		if client.OwnerID == r.Header.Get("user") {
+			owned = append(owned, client)
+		}
+	}
+	return owned
+}
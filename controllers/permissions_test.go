@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePermissionsCoverEveryRoute(t *testing.T) {
+	r := mux.NewRouter()
+	for _, handler := range HttpHandlers {
+		handler.(func(*mux.Router))(r)
+	}
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			assert.Contains(t, routePermissions, method+" "+template)
+		}
+		return nil
+	})
+}
+
+func TestCheckRoutePermission(t *testing.T) {
+	database.InitializeDatabase()
+	deleteAllUsers()
+	for username, roles := range map[string]map[string]string{
+		"viewer":   {"skynet": models.NETWORK_ROLE_VIEWER},
+		"operator": {"skynet": models.NETWORK_ROLE_OPERATOR, "othernet": models.NETWORK_ROLE_VIEWER},
+		"selfserv": {"skynet": models.NETWORK_ROLE_EXT_CLIENT_SELF_SERVICE},
+	} {
+		user := models.ReturnUser{UserName: username, NetworkRoles: roles}
+		for network := range roles {
+			user.Networks = append(user.Networks, network)
+		}
+		data, _ := json.Marshal(&user)
+		database.Insert(username, string(data), database.USERS_TABLE_NAME)
+	}
+	for _, client := range []models.ExtClient{
+		{ClientID: "mine", Network: "skynet", OwnerID: "selfserv"},
+		{ClientID: "theirs", Network: "skynet", OwnerID: "operator"},
+	} {
+		key, _ := logic.GetRecordKey(client.ClientID, client.Network)
+		data, _ := json.Marshal(&client)
+		database.Insert(key, string(data), database.EXT_CLIENT_TABLE_NAME)
+	}
+	check := func(method, template, path, username string) ([]string, error) {
+		var networks []string
+		var err error
+		r := mux.NewRouter()
+		r.HandleFunc(template, func(w http.ResponseWriter, r *http.Request) {
+			networks, err = checkRoutePermission(r, username, false, nil)
+		}).Methods(method)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+		return networks, err
+	}
+
+	t.Run("Viewer", func(t *testing.T) {
+		networks, err := check(http.MethodGet, "/api/nodes/{network}", "/api/nodes/skynet", "viewer")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"skynet"}, networks)
+		_, err = check(http.MethodDelete, "/api/nodes/{network}/{nodeid}", "/api/nodes/skynet/node1", "viewer")
+		assert.Equal(t, errNoRole, err)
+		_, err = check(http.MethodPut, "/api/networks/{networkname}/acls", "/api/networks/skynet/acls", "viewer")
+		assert.Equal(t, errNoRole, err)
+	})
+	t.Run("Operator", func(t *testing.T) {
+		_, err := check(http.MethodPut, "/api/nodes/{network}/{nodeid}", "/api/nodes/skynet/node1", "operator")
+		assert.Nil(t, err)
+		_, err = check(http.MethodPut, "/api/nodes/{network}/{nodeid}", "/api/nodes/othernet/node1", "operator")
+		assert.Equal(t, errNoRole, err)
+		_, err = check(http.MethodDelete, "/api/extclients/{network}/{clientid}", "/api/extclients/skynet/mine", "operator")
+		assert.Nil(t, err)
+		networks, err := check(http.MethodGet, "/api/nodes", "/api/nodes", "operator")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"othernet", "skynet"}, networks)
+		_, err = check(http.MethodPost, "/api/networks", "/api/networks", "operator")
+		assert.Equal(t, errNoRole, err)
+	})
+	t.Run("ExtClientSelfService", func(t *testing.T) {
+		_, err := check(http.MethodPost, "/api/extclients/{network}/{nodeid}", "/api/extclients/skynet/node1", "selfserv")
+		assert.Nil(t, err)
+		_, err = check(http.MethodPut, "/api/extclients/{network}/{clientid}", "/api/extclients/skynet/mine", "selfserv")
+		assert.Nil(t, err)
+		_, err = check(http.MethodDelete, "/api/extclients/{network}/{clientid}", "/api/extclients/skynet/theirs", "selfserv")
+		assert.Equal(t, errNoRole, err)
+		_, err = check(http.MethodGet, "/api/nodes/{network}", "/api/nodes/skynet", "selfserv")
+		assert.Equal(t, errNoRole, err)
+	})
+	t.Run("UnlistedRoute", func(t *testing.T) {
+		_, err := check(http.MethodGet, "/api/unlisted", "/api/unlisted", "operator")
+		assert.Equal(t, errNoRole, err)
+		networks, err := checkRoutePermission(httptest.NewRequest(http.MethodGet, "/api/unlisted", nil), "admin", true, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{ALL_NETWORK_ACCESS}, networks)
+	})
+}
+
+func TestAdminNetworkToken(t *testing.T) {
+	database.InitializeDatabase()
+	deleteAllUsers()
+	deleteAllNetworks()
+	for _, network := range []models.Network{
+		{NetID: "skynet", AddressRange: "10.0.0.1/24"},
+		{NetID: "othernet", AddressRange: "10.20.0.0/24"},
+	} {
+		if _, err := logic.CreateNetwork(network); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, node := range []models.Node{
+		{ID: "skynode", Name: "skynode", Network: "skynet", Address: "10.0.0.2"},
+		{ID: "othernode", Name: "othernode", Network: "othernet", Address: "10.20.0.2"},
+	} {
+		data, _ := json.Marshal(&node)
+		database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+	}
+	defer deleteAllNetworks()
+	admin := models.User{UserName: "tokenadmin", Password: "password", IsAdmin: true}
+	data, _ := json.Marshal(&admin)
+	database.Insert(admin.UserName, string(data), database.USERS_TABLE_NAME)
+	token, err := logic.CreateAPIToken(admin.UserName, models.APIToken{Name: "ci", Scope: models.API_TOKEN_SCOPE_READ, Networks: []string{"skynet"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	nodeHandlers(r)
+	request := httptest.NewRequest(http.MethodGet, "/api/nodes", nil)
+	request.Header.Set("Authorization", "Bearer "+token.Token)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+	var nodes []models.Node
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&nodes))
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "skynode", nodes[0].ID)
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+)
+
+// parseListOptions - reads the sort, order, cursor and limit query parameters
+func parseListOptions(r *http.Request) (logic.ListOptions, error) {
+	values := r.URL.Query()
+	options := logic.ListOptions{
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+	switch strings.ToLower(values.Get("order")) {
+	case "", "asc":
+	case "desc":
+		options.Desc = true
+	default:
+		return options, errors.New("order must be asc or desc")
+	}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		if options.Limit, err = strconv.Atoi(limit); err != nil || options.Limit < 0 {
+			return options, errors.New("limit must be a positive number")
+		}
+	}
+	return options, nil
+}
+
+// parseNodeQuery - reads the filters of a node listing from the query parameters
+func parseNodeQuery(r *http.Request) (logic.NodeQuery, error) {
+	options, err := parseListOptions(r)
+	if err != nil {
+		return logic.NodeQuery{}, err
+	}
+	values := r.URL.Query()
+	query := logic.NodeQuery{
+		ListOptions: options,
+		OS:          values.Get("os"),
+		Version:     values.Get("version"),
+		Pending:     values.Get("pending"),
+		Role:        values.Get("role"),
+		Name:        values.Get("name"),
+		Selector:    values.Get("selector"),
+	}
+	if query.CheckinWithin, err = parseDurationParam(values.Get("checkinwithin")); err != nil {
+		return query, err
+	}
+	if query.CheckinOlder, err = parseDurationParam(values.Get("checkinolder")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// parseExtClientQuery - reads the filters of an ext client listing from the query parameters
+func parseExtClientQuery(r *http.Request) (logic.ExtClientQuery, error) {
+	options, err := parseListOptions(r)
+	if err != nil {
+		return logic.ExtClientQuery{}, err
+	}
+	values := r.URL.Query()
+	return logic.ExtClientQuery{
+		ListOptions: options,
+		Name:        values.Get("name"),
+		Gateway:     values.Get("gateway"),
+		Enabled:     values.Get("enabled"),
+	}, nil
+}
+
+// parseDNSQuery - reads the filters of a dns listing from the query parameters
+func parseDNSQuery(r *http.Request) (logic.DNSQuery, error) {
+	options, err := parseListOptions(r)
+	if err != nil {
+		return logic.DNSQuery{}, err
+	}
+	return logic.DNSQuery{ListOptions: options, Name: r.URL.Query().Get("name")}, nil
+}
+
+// parseDurationParam - parses a duration such as 90s or 2h, an empty value is zero
+func parseDurationParam(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, errors.New("invalid duration " + value)
+	}
+	return duration, nil
+}
+
+// setPageHeaders - sets the total number of matching records and the cursor of the next page, if any
+func setPageHeaders(w http.ResponseWriter, page logic.PageInfo) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+}
@@ -22,11 +22,13 @@ func createRelay(w http.ResponseWriter, r *http.Request) {
 	}
 	relay.NetID = params["network"]
 	relay.NodeID = params["nodeid"]
+	before, _ := logic.GetNodeByID(relay.NodeID)
 	updatenodes, node, err := logic.CreateRelay(relay)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 	logger.Log(1, r.Header.Get("user"), "created relay on node", relay.NodeID, "on network", relay.NetID)
 	for _, relayedNode := range updatenodes {
 		err = mq.NodeUpdate(&relayedNode)
@@ -44,11 +46,13 @@ func deleteRelay(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	nodeid := params["nodeid"]
 	netid := params["network"]
+	before, _ := logic.GetNodeByID(nodeid)
 	updatenodes, node, err := logic.DeleteRelay(netid, nodeid)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "node", node.ID, node.Network, &before, &node)
 	logger.Log(1, r.Header.Get("user"), "deleted relay server", nodeid, "on network", netid)
 	for _, relayedNode := range updatenodes {
 		err = mq.NodeUpdate(&relayedNode)
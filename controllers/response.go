@@ -2,10 +2,17 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
 )
 
 func formatError(err error, errType string) models.ErrorResponse {
@@ -22,6 +29,12 @@ func formatError(err error, errType string) models.ErrorResponse {
 		status = http.StatusUnauthorized
 	case "forbidden":
 		status = http.StatusForbidden
+	case "conflict":
+		status = http.StatusConflict
+	case "preconditionfailed":
+		status = http.StatusPreconditionFailed
+	case "toomanyrequests":
+		status = http.StatusTooManyRequests
 	default:
 		status = http.StatusInternalServerError
 	}
@@ -33,6 +46,83 @@ func formatError(err error, errType string) models.ErrorResponse {
 	return response
 }
 
+// updateErrorType - error type of a failed update, distinguishing concurrent modifications
+func updateErrorType(err error, fallback string) string {
+	if errors.Is(err, logic.ErrRevisionConflict) {
+		return "conflict"
+	}
+	return fallback
+}
+
+// setETag - sets the ETag header to the revision of a record
+func setETag(w http.ResponseWriter, revision uint64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatUint(revision, 10)))
+}
+
+// checkIfMatch - checks the If-Match header, if any, against the revision of a record
+func checkIfMatch(r *http.Request, revision uint64) error {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+	etag := strconv.Quote(strconv.FormatUint(revision, 10))
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return nil
+		}
+	}
+	return errors.New("If-Match " + ifMatch + " does not match current revision " + etag)
+}
+
+// returnLoginLocked - rejects a login that is backing off or locked out, telling the client when to retry
+func returnLoginLocked(w http.ResponseWriter, r *http.Request, wait time.Duration, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
+	returnErrorResponse(w, r, formatError(err, "toomanyrequests"))
+}
+
+// clientIP - address a request came from, X-Forwarded-For is only read when the request comes from a trusted proxy,
+// then the last address not added by a trusted proxy is used as those before it may be set by the client
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	trusted := servercfg.GetTrustedProxies()
+	if !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+	addresses := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		forwarded := strings.TrimSpace(addresses[i])
+		if net.ParseIP(forwarded) == nil {
+			break
+		}
+		ip = forwarded
+		if !isTrustedProxy(ip, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+// isTrustedProxy - checks an address is one of the trusted proxies, given as addresses or ranges
+func isTrustedProxy(address string, trusted []string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trusted {
+		if _, cidr, err := net.ParseCIDR(proxy); err == nil {
+			if cidr.Contains(ip) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func returnSuccessResponse(response http.ResponseWriter, request *http.Request, message string) {
 	var httpResponse models.SuccessResponse
 	httpResponse.Code = http.StatusOK
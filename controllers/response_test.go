@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/gravitl/netmaker/models"
@@ -17,6 +18,33 @@ func TestFormatError(t *testing.T) {
 	assert.Equal(t, "this is a sample error", response.Message)
 }
 
+func TestCheckIfMatch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "http://example.com", nil)
+	assert.Nil(t, checkIfMatch(req, 3))
+	req.Header.Set("If-Match", `"3"`)
+	assert.Nil(t, checkIfMatch(req, 3))
+	assert.NotNil(t, checkIfMatch(req, 4))
+	req.Header.Set("If-Match", `"1", W/"4"`)
+	assert.Nil(t, checkIfMatch(req, 4))
+	req.Header.Set("If-Match", "*")
+	assert.Nil(t, checkIfMatch(req, 7))
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	assert.Equal(t, "203.0.113.7", clientIP(req))
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	assert.Equal(t, "203.0.113.7", clientIP(req))
+	req.RemoteAddr = "10.1.2.3:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 198.51.100.2, 192.0.2.1")
+	assert.Equal(t, "198.51.100.2", clientIP(req))
+	req.Header.Del("X-Forwarded-For")
+	assert.Equal(t, "10.1.2.3", clientIP(req))
+}
+
 func TestReturnSuccessResponse(t *testing.T) {
 	var response models.SuccessResponse
 	handler := func(rw http.ResponseWriter, r *http.Request) {
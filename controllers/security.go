@@ -31,6 +31,12 @@ func securityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 			return
 		}
 
+		if err := checkAPITokenScope(r, bearerToken); err != nil {
+			errorResponse.Code = http.StatusForbidden
+			errorResponse.Message = err.Error()
+			returnErrorResponse(w, r, errorResponse)
+			return
+		}
 		err, networks, username := SecurityCheck(reqAdmin, params["networkname"], bearerToken)
 		if err != nil {
 			if strings.Contains(err.Error(), "does not exist") {
@@ -40,6 +46,13 @@ func securityCheck(reqAdmin bool, next http.Handler) http.HandlerFunc {
 			returnErrorResponse(w, r, errorResponse)
 			return
 		}
+		isadmin := len(networks) > 0 && networks[0] == ALL_NETWORK_ACCESS
+		if networks, err = checkRoutePermission(r, username, isadmin, networks); err != nil {
+			errorResponse.Code = http.StatusForbidden
+			errorResponse.Message = err.Error()
+			returnErrorResponse(w, r, errorResponse)
+			return
+		}
 		networksJson, err := json.Marshal(&networks)
 		if err != nil {
 			errorResponse.Message = err.Error()
@@ -98,6 +111,24 @@ func SecurityCheck(reqAdmin bool, netname string, token string) (error, []string
 	return nil, userNetworks, username
 }
 
+// checkAPITokenScope - checks an API token in the Authorization header is allowed to make the request,
+// other tokens are left to the usual checks
+func checkAPITokenScope(r *http.Request, bearerToken string) error {
+	tokenSplit := strings.Split(bearerToken, " ")
+	if len(tokenSplit) < 2 || !logic.IsAPIToken(tokenSplit[1]) {
+		return nil
+	}
+	token, _, _, err := logic.VerifyAPIToken(tokenSplit[1])
+	if err != nil {
+		return err
+	}
+	var template string
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ = route.GetPathTemplate()
+	}
+	return logic.CheckAPITokenScope(token, r.Method, template)
+}
+
 // Consider a more secure way of setting master key
 func authenticateMaster(tokenString string) bool {
 	return tokenString == servercfg.GetMasterKey() && servercfg.GetMasterKey() != ""
@@ -126,3 +157,16 @@ func continueIfUserMatch(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+// continueIfUserMatchOrAdmin - lets users act on themselves and admins act on any user
+func continueIfUserMatchOrAdmin(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var networks []string
+		json.Unmarshal([]byte(r.Header.Get("networks")), &networks)
+		if len(networks) > 0 && networks[0] == ALL_NETWORK_ACCESS {
+			next.ServeHTTP(w, r)
+			return
+		}
+		continueIfUserMatch(next).ServeHTTP(w, r)
+	}
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"bytes"
 	"crypto/ed25519"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -8,8 +9,10 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/functions"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
@@ -23,6 +26,11 @@ func serverHandlers(r *mux.Router) {
 	r.HandleFunc("/api/server/getconfig", securityCheckServer(false, http.HandlerFunc(getConfig))).Methods("GET")
 	r.HandleFunc("/api/server/removenetwork/{network}", securityCheckServer(true, http.HandlerFunc(removeNetwork))).Methods("DELETE")
 	r.HandleFunc("/api/server/register", authorize(true, false, "node", http.HandlerFunc(register))).Methods("POST")
+	r.HandleFunc("/api/server/backup", securityCheckServer(true, http.HandlerFunc(getBackup))).Methods("GET")
+	r.HandleFunc("/api/server/restore", securityCheckServer(true, http.HandlerFunc(restoreBackup))).Methods("POST")
+	r.HandleFunc("/api/server/cache", securityCheckServer(true, http.HandlerFunc(getCacheStats))).Methods("GET")
+	r.HandleFunc("/api/server/cache/check", securityCheckServer(true, http.HandlerFunc(checkCache))).Methods("GET")
+	r.HandleFunc("/api/server/conflicts", securityCheckServer(true, http.HandlerFunc(getAddressConflicts))).Methods("GET")
 }
 
 //Security check is middleware for every function and just checks to make sure that its the master calling
@@ -47,6 +55,10 @@ func securityCheckServer(adminonly bool, next http.Handler) http.HandlerFunc {
 		} else {
 			authToken = tokenSplit[1]
 		}
+		if err := checkAPITokenScope(r, bearerToken); err != nil {
+			returnErrorResponse(w, r, formatError(err, "forbidden"))
+			return
+		}
 		//all endpoints here require master so not as complicated
 		//still might not be a good  way of doing this
 		user, _, isadmin, err := logic.VerifyUserToken(authToken)
@@ -61,6 +73,10 @@ func securityCheckServer(adminonly bool, next http.Handler) http.HandlerFunc {
 			returnErrorResponse(w, r, errorResponse)
 			return
 		}
+		if _, err := checkRoutePermission(r, user, isadmin || authenticateMaster(authToken), nil); err != nil {
+			returnErrorResponse(w, r, formatError(err, "forbidden"))
+			return
+		}
 		next.ServeHTTP(w, r)
 	}
 }
@@ -72,11 +88,13 @@ func removeNetwork(w http.ResponseWriter, r *http.Request) {
 	// get params
 	var params = mux.Vars(r)
 
+	before, _ := logic.GetParentNetwork(params["network"])
 	err := logic.DeleteNetwork(params["network"])
 	if err != nil {
 		json.NewEncoder(w).Encode("Could not remove server from network " + params["network"])
 		return
 	}
+	audit(r, models.AUDIT_DELETE, "network", params["network"], params["network"], &before, nil)
 
 	json.NewEncoder(w).Encode("Server removed from network " + params["network"])
 }
@@ -111,6 +129,36 @@ func getConfig(w http.ResponseWriter, r *http.Request) {
 // 	json.NewEncoder(w).Encode("Server added to network " + params["network"])
 // }
 
+// getBackup - streams an archive of every table and the root CA
+func getBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=netmaker-backup-%d.tar.gz", time.Now().Unix()))
+	var buf bytes.Buffer
+	if err := logic.WriteBackup(&buf, functions.GetNetmakerPath()); err != nil {
+		logger.Log(0, r.Header.Get("user"), "failed to create backup:", err.Error())
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	logger.Log(1, r.Header.Get("user"), "created server backup")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// restoreBackup - restores a backup archive into an empty database
+func restoreBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	manifest, err := logic.RestoreBackup(r.Body, functions.GetNetmakerPath())
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"), "failed to restore backup:", err.Error())
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	audit(r, models.AUDIT_UPDATE, "server", "backup", "", nil, &manifest)
+	logger.Log(0, r.Header.Get("user"), "restored server backup")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(manifest)
+}
+
 // register - registers a client with the server and return the CA and cert
 func register(w http.ResponseWriter, r *http.Request) {
 	logger.Log(2, "processing registration request")
@@ -170,3 +218,38 @@ func genCerts(clientKey *ed25519.PrivateKey, name *pkix.Name) (*x509.Certificate
 	}
 	return cert, ca, nil
 }
+
+// getCacheStats - returns the hit rate and size of every cached table
+func getCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(logic.GetCacheStats())
+}
+
+// checkCache - compares the cache with the database
+func checkCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	check, err := logic.CheckCache()
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	if !check.Consistent {
+		logger.Log(0, "cache is inconsistent with the database in", fmt.Sprint(len(check.Inconsistencies)), "tables")
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(check)
+}
+
+// getAddressConflicts - reports the ranges of networks, egress gateways, relays and local ranges that overlap
+// where clients would route the same addresses two ways
+func getAddressConflicts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	conflicts, err := logic.GetAddressConflicts()
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(conflicts)
+}
@@ -19,8 +19,15 @@ func userHandlers(r *mux.Router) {
 	r.HandleFunc("/api/users/adm/hasadmin", hasAdmin).Methods("GET")
 	r.HandleFunc("/api/users/adm/createadmin", createAdmin).Methods("POST")
 	r.HandleFunc("/api/users/adm/authenticate", authenticateUser).Methods("POST")
+	r.HandleFunc("/api/users/adm/lockouts", securityCheck(true, http.HandlerFunc(getLoginLockouts))).Methods("GET")
+	r.HandleFunc("/api/users/adm/lockouts/{kind}/{subject}", securityCheck(true, http.HandlerFunc(clearLoginLockout))).Methods("DELETE")
+	r.HandleFunc("/api/users/{username}/tokens", securityCheck(false, continueIfUserMatchOrAdmin(http.HandlerFunc(getAPITokens)))).Methods("GET")
+	r.HandleFunc("/api/users/{username}/tokens", securityCheck(false, continueIfUserMatchOrAdmin(http.HandlerFunc(createAPIToken)))).Methods("POST")
+	r.HandleFunc("/api/users/{username}/tokens/{tokenid}", securityCheck(false, continueIfUserMatchOrAdmin(http.HandlerFunc(deleteAPIToken)))).Methods("DELETE")
 	r.HandleFunc("/api/users/{username}", securityCheck(false, continueIfUserMatch(http.HandlerFunc(updateUser)))).Methods("PUT")
 	r.HandleFunc("/api/users/networks/{username}", securityCheck(true, http.HandlerFunc(updateUserNetworks))).Methods("PUT")
+	r.HandleFunc("/api/users/{username}/roles/{network}", securityCheck(false, http.HandlerFunc(setUserNetworkRole))).Methods("PUT")
+	r.HandleFunc("/api/users/{username}/roles/{network}", securityCheck(false, http.HandlerFunc(deleteUserNetworkRole))).Methods("DELETE")
 	r.HandleFunc("/api/users/{username}/adm", securityCheck(true, http.HandlerFunc(updateUserAdm))).Methods("PUT")
 	r.HandleFunc("/api/users/{username}", securityCheck(true, http.HandlerFunc(createUser))).Methods("POST")
 	r.HandleFunc("/api/users/{username}", securityCheck(true, http.HandlerFunc(deleteUser))).Methods("DELETE")
@@ -48,11 +55,19 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	ip := clientIP(request)
+	attempt, wait, err := logic.CheckLogin(logic.LOGIN_KIND_USER, authRequest.UserName, ip)
+	if err != nil {
+		returnLoginLocked(response, request, wait, err)
+		return
+	}
 	jwt, err := logic.VerifyAuthRequest(authRequest)
 	if err != nil {
+		attempt.Failed()
 		returnErrorResponse(response, request, formatError(err, "badrequest"))
 		return
 	}
+	attempt.Succeeded()
 
 	if jwt == "" {
 		// very unlikely that err is !nil and no jwt returned, but handle it anyways.
@@ -95,6 +110,29 @@ func hasAdmin(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func getLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	logger.Log(2, r.Header.Get("user"), "fetched login lockouts")
+	json.NewEncoder(w).Encode(logic.GetLoginLockouts())
+}
+
+func clearLoginLockout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	kind, subject := params["kind"], params["subject"]
+	if kind != logic.LOGIN_KIND_USER && kind != logic.LOGIN_KIND_NODE && kind != logic.LOGIN_KIND_IP {
+		returnErrorResponse(w, r, formatError(fmt.Errorf("unknown lockout kind %s, must be user, node or ip", kind), "badrequest"))
+		return
+	}
+	if !logic.ClearLoginLockout(kind, subject) {
+		returnErrorResponse(w, r, formatError(fmt.Errorf("no failed logins recorded for %s %s", kind, subject), "notfound"))
+		return
+	}
+	audit(r, models.AUDIT_DELETE, "lockout", kind+":"+subject, "", nil, nil)
+	logger.Log(1, r.Header.Get("user"), "cleared login lockout of", kind, subject)
+	returnSuccessResponse(w, r, "cleared login lockout of "+kind+" "+subject)
+}
+
 // GetUserInternal - gets an internal user
 func GetUserInternal(username string) (models.User, error) {
 
@@ -155,6 +193,7 @@ func createAdmin(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_CREATE, "user", admin.UserName, "", nil, &admin)
 	logger.Log(1, admin.UserName, "was made a new admin")
 	json.NewEncoder(w).Encode(admin)
 }
@@ -172,6 +211,7 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_CREATE, "user", user.UserName, "", nil, &user)
 	logger.Log(1, user.UserName, "was created")
 	json.NewEncoder(w).Encode(user)
 }
@@ -194,15 +234,60 @@ func updateUserNetworks(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	var before = user
 	err = logic.UpdateUserNetworks(userchange.Networks, userchange.IsAdmin, &user)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "user", username, "", &before, &user)
 	logger.Log(1, username, "status was updated")
 	json.NewEncoder(w).Encode(user)
 }
 
+// setUserNetworkRole - gives a user a role on a network, network owners may give roles on their networks
+func setUserNetworkRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	var role models.UserNetworkRole
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	if !logic.IsValidNetworkRole(role.Role) {
+		returnErrorResponse(w, r, formatError(errors.New("unknown role "+role.Role), "badrequest"))
+		return
+	}
+	changeUserNetworkRole(w, r, params["username"], params["network"], role.Role)
+}
+
+// deleteUserNetworkRole - removes the access of a user to a network
+func deleteUserNetworkRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var params = mux.Vars(r)
+	changeUserNetworkRole(w, r, params["username"], params["network"], "")
+}
+
+func changeUserNetworkRole(w http.ResponseWriter, r *http.Request, username, network, role string) {
+	if _, err := logic.GetUser(username); err != nil {
+		returnErrorResponse(w, r, formatError(errors.New("user "+username+" does not exist"), "notfound"))
+		return
+	}
+	before, after, err := logic.SetUserNetworkRole(username, network, role)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "badrequest"))
+		return
+	}
+	audit(r, models.AUDIT_UPDATE, "user", username, network, &before, &after)
+	logger.Log(1, r.Header.Get("user"), "set role of", username, "on network", network, "to", role)
+	user, err := logic.GetReturnUser(username)
+	if err != nil {
+		returnErrorResponse(w, r, formatError(err, "internal"))
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
 func updateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var params = mux.Vars(r)
@@ -226,11 +311,13 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	userchange.Networks = nil
+	var before = user
 	user, err = logic.UpdateUser(userchange, user)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "user", username, "", &before, &user)
 	logger.Log(1, username, "was updated")
 	json.NewEncoder(w).Encode(user)
 }
@@ -257,11 +344,13 @@ func updateUserAdm(w http.ResponseWriter, r *http.Request) {
 		returnErrorResponse(w, r, formatError(err, "internal"))
 		return
 	}
+	var before = user
 	user, err = logic.UpdateUser(userchange, user)
 	if err != nil {
 		returnErrorResponse(w, r, formatError(err, "badrequest"))
 		return
 	}
+	audit(r, models.AUDIT_UPDATE, "user", username, "", &before, &user)
 	logger.Log(1, username, "was updated (admin)")
 	json.NewEncoder(w).Encode(user)
 }
@@ -274,6 +363,7 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 
 	username := params["username"]
+	before, _ := GetUserInternal(username)
 	success, err := logic.DeleteUser(username)
 
 	if err != nil {
@@ -284,6 +374,7 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit(r, models.AUDIT_DELETE, "user", username, "", &before, nil)
 	logger.Log(1, username, "was deleted")
 	json.NewEncoder(w).Encode(params["username"] + " deleted.")
 }
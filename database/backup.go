@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// userDataTables - tables that hold operator data rather than state the server creates for itself
+var userDataTables = []string{
+	NETWORKS_TABLE_NAME,
+	NODES_TABLE_NAME,
+	DELETED_NODES_TABLE_NAME,
+	USERS_TABLE_NAME,
+	DNS_TABLE_NAME,
+	EXT_CLIENT_TABLE_NAME,
+	NODE_ACLS_TABLE_NAME,
+	RESERVATIONS_TABLE_NAME,
+}
+
+// ExportTables - fetches every record of every table, keyed by table name
+// records of secret tables stay sealed with the database encryption key when one is configured
+func ExportTables() (map[string]map[string]string, error) {
+	export := make(map[string]map[string]string, len(tables))
+	for _, table := range tables {
+		records, err := FetchRecords(table)
+		if err != nil {
+			if !IsEmptyRecord(err) {
+				return nil, fmt.Errorf("failed to export table %s: %w", table, err)
+			}
+			records = make(map[string]string)
+		}
+		if err = encryptRecords(table, records); err != nil {
+			return nil, fmt.Errorf("failed to export table %s: %w", table, err)
+		}
+		export[table] = records
+	}
+	return export, nil
+}
+
+// DecryptTables - opens the sealed records of secret tables in an export with the configured key,
+// records exported in plaintext are left as is
+func DecryptTables(records map[string]map[string]string) error {
+	for table, tableRecords := range records {
+		if err := decryptRecords(table, tableRecords); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasUserData - checks if any table holding operator data has records
+func HasUserData() (bool, error) {
+	for _, table := range userDataTables {
+		records, err := FetchRecords(table)
+		if err != nil {
+			if IsEmptyRecord(err) {
+				continue
+			}
+			return false, err
+		}
+		if len(records) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ImportTables - validates and inserts exported records in a single transaction
+// the database must not hold operator data yet, and sealed records must first be opened with DecryptTables
+func ImportTables(records map[string]map[string]string) error {
+	if err := ValidateTables(records); err != nil {
+		return err
+	}
+	hasData, err := HasUserData()
+	if err != nil {
+		return err
+	}
+	if hasData {
+		return errors.New("database is not empty, refusing to import")
+	}
+	return Transaction(func(tx *Tx) error {
+		for table, tableRecords := range records {
+			for key, value := range tableRecords {
+				if err := tx.Insert(key, value, table); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ValidateTables - checks exported records only reference known tables and hold valid JSON
+func ValidateTables(records map[string]map[string]string) error {
+	known := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		known[table] = true
+	}
+	for table, tableRecords := range records {
+		if !known[table] {
+			return fmt.Errorf("unknown table %s", table)
+		}
+		for key, value := range tableRecords {
+			if key == "" || value == "" || !IsJSONString(value) {
+				return fmt.Errorf("invalid record %q in table %s", key, table)
+			}
+		}
+	}
+	return nil
+}
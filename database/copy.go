@@ -0,0 +1,102 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TableCopy - result of copying one table between backends
+type TableCopy struct {
+	Table    string `json:"table"`
+	Records  int    `json:"records"`
+	Checksum string `json:"checksum"`
+}
+
+// CopyTables - copies every table from src to dst and verifies record counts and checksums
+// both backends must be initialized, a destination holding records is only overwritten when forced
+func CopyTables(src Backend, dst Backend, force bool) ([]TableCopy, error) {
+	for _, table := range tables {
+		if err := src.CreateTable(table); err != nil {
+			return nil, fmt.Errorf("source table %s: %w", table, err)
+		}
+		if err := dst.CreateTable(table); err != nil {
+			return nil, fmt.Errorf("destination table %s: %w", table, err)
+		}
+	}
+	for _, table := range tables {
+		records, err := fetchTable(dst, table)
+		if err != nil {
+			return nil, fmt.Errorf("destination table %s: %w", table, err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if !force {
+			return nil, fmt.Errorf("destination table %s is not empty, refusing to overwrite", table)
+		}
+		if err = dst.DeleteAllRecords(table); err != nil {
+			return nil, fmt.Errorf("failed to clear destination table %s: %w", table, err)
+		}
+	}
+	var results []TableCopy
+	for _, table := range tables {
+		records, err := fetchTable(src, table)
+		if err != nil {
+			return results, fmt.Errorf("source table %s: %w", table, err)
+		}
+		if len(records) > 0 {
+			ops := make([]TxOp, 0, len(records))
+			for key, value := range records {
+				ops = append(ops, TxOp{Table: table, Key: key, Value: value})
+			}
+			if err = dst.Commit(ops); err != nil {
+				return results, fmt.Errorf("failed to copy table %s: %w", table, err)
+			}
+		}
+		copied, err := fetchTable(dst, table)
+		if err != nil {
+			return results, fmt.Errorf("destination table %s: %w", table, err)
+		}
+		if len(copied) != len(records) {
+			return results, fmt.Errorf("table %s: copied %d of %d records", table, len(copied), len(records))
+		}
+		checksum := TableChecksum(records)
+		if TableChecksum(copied) != checksum {
+			return results, errors.New("table " + table + ": checksum mismatch after copy")
+		}
+		results = append(results, TableCopy{Table: table, Records: len(records), Checksum: checksum})
+	}
+	return results, nil
+}
+
+// TableChecksum - sha256 over the sorted keys and values of a table
+func TableChecksum(records map[string]string) string {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(records[key]))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// fetchTable - fetches every record of a table, treating an empty table as no records
+func fetchTable(backend Backend, table string) (map[string]string, error) {
+	records, err := backend.FetchRecords(table)
+	if err != nil {
+		if IsEmptyRecord(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	return records, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/config"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
@@ -53,6 +54,15 @@ const GENERATED_TABLE_NAME = "generated"
 // NODE_ACLS_TABLE_NAME - stores the node ACL rules
 const NODE_ACLS_TABLE_NAME = "nodeacls"
 
+// AUDIT_TABLE_NAME - stores the audit log
+const AUDIT_TABLE_NAME = "audit"
+
+// API_TOKENS_TABLE_NAME - stores the hashed API tokens of users
+const API_TOKENS_TABLE_NAME = "apitokens"
+
+// RESERVATIONS_TABLE_NAME - stores the IP reservations of networks
+const RESERVATIONS_TABLE_NAME = "reservations"
+
 // == ERROR CONSTS ==
 
 // NO_RECORD - no singular result found
@@ -61,42 +71,60 @@ const NO_RECORD = "no result found"
 // NO_RECORDS - no results found
 const NO_RECORDS = "could not find any records"
 
-// == Constants ==
-
-// INIT_DB - initialize db
-const INIT_DB = "init"
-
-// CREATE_TABLE - create table const
-const CREATE_TABLE = "createtable"
-
-// INSERT - insert into db const
-const INSERT = "insert"
-
-// INSERT_PEER - insert peer into db const
-const INSERT_PEER = "insertpeer"
-
-// DELETE - delete db record const
-const DELETE = "delete"
-
-// DELETE_ALL - delete a table const
-const DELETE_ALL = "deleteall"
-
-// FETCH_ALL - fetch table contents const
-const FETCH_ALL = "fetchall"
-
-// CLOSE_DB - graceful close of db const
-const CLOSE_DB = "closedb"
+// Backend - the storage operations implemented by each database type
+type Backend interface {
+	// Init - connects to the database
+	Init() error
+	// CreateTable - creates a key/value table if it does not exist
+	CreateTable(tableName string) error
+	// Insert - inserts or replaces a record
+	Insert(key string, value string, tableName string) error
+	// FetchRecord - fetches a single record by key
+	FetchRecord(tableName string, key string) (string, error)
+	// FetchRecords - fetches all records of a table
+	FetchRecords(tableName string) (map[string]string, error)
+	// DeleteRecord - deletes a single record by key
+	DeleteRecord(tableName string, key string) error
+	// DeleteAllRecords - deletes every record of a table
+	DeleteAllRecords(tableName string) error
+	// Commit - applies a batch of writes atomically
+	Commit(ops []TxOp) error
+	// Close - gracefully closes the database
+	Close()
+}
 
-func getCurrentDB() map[string]interface{} {
+func getCurrentDB() Backend {
 	switch servercfg.GetDB() {
 	case "rqlite":
-		return RQLITE_FUNCTIONS
+		return rqliteDB
+	case "sqlite":
+		return sqliteDB
+	case "postgres":
+		return pgDB
+	case "memory":
+		return memoryDB
+	default:
+		return sqliteDB
+	}
+}
+
+// NewBackend - creates a backend of the given type that is independent of the server's database
+// sqlConn is the rqlite connection string, or the database file for sqlite
+func NewBackend(dbType string, sqlConn string, sqlConf config.SQLConfig) (Backend, error) {
+	switch dbType {
+	case "rqlite":
+		if sqlConn == "" {
+			return nil, errors.New("rqlite requires a connection string")
+		}
+		return &rqliteBackend{connString: sqlConn}, nil
 	case "sqlite":
-		return SQLITE_FUNCTIONS
+		return &sqliteBackend{path: sqlConn}, nil
 	case "postgres":
-		return PG_FUNCTIONS
+		return &pgBackend{conf: &sqlConf}, nil
+	case "memory":
+		return &memoryBackend{}, nil
 	default:
-		return SQLITE_FUNCTIONS
+		return nil, errors.New("unknown database type " + dbType)
 	}
 }
 
@@ -105,7 +133,7 @@ func InitializeDatabase() error {
 	logger.Log(0, "connecting to", servercfg.GetDB())
 	tperiod := time.Now().Add(10 * time.Second)
 	for {
-		if err := getCurrentDB()[INIT_DB].(func() error)(); err != nil {
+		if err := getCurrentDB().Init(); err != nil {
 			logger.Log(0, "unable to connect to db, retrying . . .")
 			if time.Now().After(tperiod) {
 				return err
@@ -115,26 +143,46 @@ func InitializeDatabase() error {
 		}
 		time.Sleep(2 * time.Second)
 	}
+	if err := loadEncryptionKey(); err != nil {
+		return err
+	}
 	createTables()
+	if _, err := RunMigrations(servercfg.IsMigrationDryRun()); err != nil {
+		return err
+	}
 	return initializeUUID()
 }
 
+var tables = []string{
+	NETWORKS_TABLE_NAME,
+	NODES_TABLE_NAME,
+	DELETED_NODES_TABLE_NAME,
+	USERS_TABLE_NAME,
+	DNS_TABLE_NAME,
+	EXT_CLIENT_TABLE_NAME,
+	PEERS_TABLE_NAME,
+	SERVERCONF_TABLE_NAME,
+	SERVER_UUID_TABLE_NAME,
+	GENERATED_TABLE_NAME,
+	NODE_ACLS_TABLE_NAME,
+	AUDIT_TABLE_NAME,
+	API_TOKENS_TABLE_NAME,
+	RESERVATIONS_TABLE_NAME,
+}
+
+// Tables - returns the names of every table the server creates
+func Tables() []string {
+	return append([]string{}, tables...)
+}
+
 func createTables() {
-	createTable(NETWORKS_TABLE_NAME)
-	createTable(NODES_TABLE_NAME)
-	createTable(DELETED_NODES_TABLE_NAME)
-	createTable(USERS_TABLE_NAME)
-	createTable(DNS_TABLE_NAME)
-	createTable(EXT_CLIENT_TABLE_NAME)
-	createTable(PEERS_TABLE_NAME)
-	createTable(SERVERCONF_TABLE_NAME)
-	createTable(SERVER_UUID_TABLE_NAME)
-	createTable(GENERATED_TABLE_NAME)
-	createTable(NODE_ACLS_TABLE_NAME)
+	for _, table := range tables {
+		createTable(table)
+	}
 }
 
 func createTable(tableName string) error {
-	return getCurrentDB()[CREATE_TABLE].(func(string) error)(tableName)
+	return getCurrentDB().CreateTable(tableName)
 }
 
 // IsJSONString - checks if valid json
@@ -147,7 +195,13 @@ func IsJSONString(value string) bool {
 // Insert - inserts object into db
 func Insert(key string, value string, tableName string) error {
 	if key != "" && value != "" && IsJSONString(value) {
-		return getCurrentDB()[INSERT].(func(string, string, string) error)(key, value, tableName)
+		encrypted, err := encryptValue(tableName, key, value)
+		if err != nil {
+			return err
+		}
+		return writeAndNotify([]TxOp{{Table: tableName, Key: key, Value: value}}, func() error {
+			return getCurrentDB().Insert(key, encrypted, tableName)
+		})
 	} else {
 		return errors.New("invalid insert " + key + " : " + value)
 	}
@@ -156,7 +210,7 @@ func Insert(key string, value string, tableName string) error {
 // InsertPeer - inserts peer into db
 func InsertPeer(key string, value string) error {
 	if key != "" && value != "" && IsJSONString(value) {
-		return getCurrentDB()[INSERT_PEER].(func(string, string) error)(key, value)
+		return getCurrentDB().Insert(key, value, PEERS_TABLE_NAME)
 	} else {
 		return errors.New("invalid peer insert " + key + " : " + value)
 	}
@@ -164,12 +218,16 @@ func InsertPeer(key string, value string) error {
 
 // DeleteRecord - deletes a record from db
 func DeleteRecord(tableName string, key string) error {
-	return getCurrentDB()[DELETE].(func(string, string) error)(tableName, key)
+	return writeAndNotify([]TxOp{{Table: tableName, Key: key, Delete: true}}, func() error {
+		return getCurrentDB().DeleteRecord(tableName, key)
+	})
 }
 
 // DeleteAllRecords - removes a table and remakes
 func DeleteAllRecords(tableName string) error {
-	err := getCurrentDB()[DELETE_ALL].(func(string) error)(tableName)
+	err := writeAndNotify([]TxOp{{Table: tableName, Delete: true}}, func() error {
+		return getCurrentDB().DeleteAllRecords(tableName)
+	})
 	if err != nil {
 		return err
 	}
@@ -182,19 +240,23 @@ func DeleteAllRecords(tableName string) error {
 
 // FetchRecord - fetches a record
 func FetchRecord(tableName string, key string) (string, error) {
-	results, err := FetchRecords(tableName)
+	value, err := getCurrentDB().FetchRecord(tableName, key)
 	if err != nil {
-		return "", err
-	}
-	if results[key] == "" {
-		return "", errors.New(NO_RECORD)
+		return value, err
 	}
-	return results[key], nil
+	return decryptValue(tableName, key, value)
 }
 
 // FetchRecords - fetches all records in given table
 func FetchRecords(tableName string) (map[string]string, error) {
-	return getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(tableName)
+	records, err := getCurrentDB().FetchRecords(tableName)
+	if err != nil {
+		return records, err
+	}
+	if err = decryptRecords(tableName, records); err != nil {
+		return nil, err
+	}
+	return records, nil
 }
 
 // initializeUUID - create a UUID record for server if none exists
@@ -237,5 +299,13 @@ func initializeUUID() error {
 
 // CloseDB - closes a database gracefully
 func CloseDB() {
-	getCurrentDB()[CLOSE_DB].(func())()
+	getCurrentDB().Close()
+}
+
+// Ping - checks the database answers by reading the server uuid record through the active backend
+func Ping() error {
+	if _, err := getCurrentDB().FetchRecord(SERVER_UUID_TABLE_NAME, SERVER_UUID_RECORD_KEY); err != nil && !IsEmptyRecord(err) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,475 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+const benchTableName = "benchnodes"
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "netmaker-db-test")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err = os.Chdir(dir); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err = InitializeDatabase(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	CloseDB()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestFetchRecord(t *testing.T) {
+	if err := seedTable(benchTableName, 10); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("ExistingRecord", func(t *testing.T) {
+		record, err := FetchRecord(benchTableName, benchKey(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var node models.Node
+		if err = json.Unmarshal([]byte(record), &node); err != nil {
+			t.Fatal(err)
+		}
+		if node.ID != benchKey(3) {
+			t.Fatalf("expected node %s, got %s", benchKey(3), node.ID)
+		}
+	})
+	t.Run("MissingRecord", func(t *testing.T) {
+		_, err := FetchRecord(benchTableName, "doesnotexist")
+		if !IsEmptyRecord(err) {
+			t.Fatalf("expected empty record error, got %v", err)
+		}
+	})
+	t.Run("MissingTable", func(t *testing.T) {
+		_, err := FetchRecord("doesnotexist", benchKey(3))
+		if err == nil {
+			t.Fatal("expected error fetching from missing table")
+		}
+	})
+}
+
+func BenchmarkFetchRecord(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		if err := seedTable(benchTableName, size); err != nil {
+			b.Fatal(err)
+		}
+		key := benchKey(size / 2)
+		b.Run(fmt.Sprintf("PointLookup-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := FetchRecord(benchTableName, key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("TableScan-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := fetchRecordByScan(benchTableName, key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// fetchRecordByScan - the previous FetchRecord implementation, kept for comparison
+func fetchRecordByScan(tableName string, key string) (string, error) {
+	results, err := FetchRecords(tableName)
+	if err != nil {
+		return "", err
+	}
+	if results[key] == "" {
+		return "", errors.New(NO_RECORD)
+	}
+	return results[key], nil
+}
+
+func seedTable(tableName string, size int) error {
+	if err := createTable(tableName); err != nil {
+		return err
+	}
+	if err := DeleteAllRecords(tableName); err != nil {
+		return err
+	}
+	for i := 0; i < size; i++ {
+		node := models.Node{
+			ID:      benchKey(i),
+			Name:    fmt.Sprintf("node-%d", i),
+			Network: "skynet",
+			Address: fmt.Sprintf("10.%d.%d.%d", (i>>16)&255, (i>>8)&255, i&255),
+		}
+		data, err := json.Marshal(&node)
+		if err != nil {
+			return err
+		}
+		if err = Insert(node.ID, string(data), tableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func benchKey(i int) string {
+	return fmt.Sprintf("node-%08d", i)
+}
+
+func TestMemoryBackend(t *testing.T) {
+	var db Backend = &memoryBackend{}
+	if err := db.CreateTable(NODES_TABLE_NAME); err == nil {
+		t.Fatal("expected error creating table before init")
+	}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateTable(NODES_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("EmptyTable", func(t *testing.T) {
+		_, err := db.FetchRecords(NODES_TABLE_NAME)
+		if err == nil || err.Error() != NO_RECORDS {
+			t.Fatalf("expected %q, got %v", NO_RECORDS, err)
+		}
+	})
+	t.Run("InsertAndFetch", func(t *testing.T) {
+		if err := db.Insert("a", `{"id":"a"}`, NODES_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Insert("b", "not json", NODES_TABLE_NAME); err == nil {
+			t.Fatal("expected invalid insert error")
+		}
+		value, err := db.FetchRecord(NODES_TABLE_NAME, "a")
+		if err != nil || value != `{"id":"a"}` {
+			t.Fatalf("unexpected fetch result %q, %v", value, err)
+		}
+		if _, err = db.FetchRecord(NODES_TABLE_NAME, "b"); err == nil || err.Error() != NO_RECORD {
+			t.Fatalf("expected %q, got %v", NO_RECORD, err)
+		}
+		records, err := db.FetchRecords(NODES_TABLE_NAME)
+		if err != nil || len(records) != 1 {
+			t.Fatalf("unexpected fetch all result %v, %v", records, err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := db.DeleteRecord(NODES_TABLE_NAME, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.FetchRecord(NODES_TABLE_NAME, "a"); !IsEmptyRecord(err) {
+			t.Fatalf("expected empty record error, got %v", err)
+		}
+		if err := db.DeleteAllRecords("doesnotexist"); err == nil {
+			t.Fatal("expected error deleting missing table")
+		}
+	})
+	db.Close()
+	if _, err := db.FetchRecords(NODES_TABLE_NAME); err == nil {
+		t.Fatal("expected error after close")
+	}
+}
+
+func TestRunMigrations(t *testing.T) {
+	network := models.Network{NetID: "skynet", AddressRange: "10.0.0.0/24"}
+	data, err := json.Marshal(&network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Insert(network.NetID, string(data), NETWORKS_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteRecord(GENERATED_TABLE_NAME, SCHEMA_VERSION_RECORD_KEY); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteRecord(NETWORKS_TABLE_NAME, network.NetID)
+	t.Run("DryRun", func(t *testing.T) {
+		results, err := RunMigrations(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(migrations) || results[0].Records != 1 {
+			t.Fatalf("unexpected dry run results %+v", results)
+		}
+		record, _ := FetchRecord(NETWORKS_TABLE_NAME, network.NetID)
+		if record != string(data) {
+			t.Fatal("dry run changed a record")
+		}
+		schema, _ := GetSchemaVersion()
+		if schema.Version != 0 {
+			t.Fatalf("dry run stored schema version %d", schema.Version)
+		}
+	})
+	t.Run("Apply", func(t *testing.T) {
+		if _, err := RunMigrations(false); err != nil {
+			t.Fatal(err)
+		}
+		record, err := FetchRecord(NETWORKS_TABLE_NAME, network.NetID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var upgraded models.Network
+		if err = json.Unmarshal([]byte(record), &upgraded); err != nil {
+			t.Fatal(err)
+		}
+		if upgraded.DefaultInterface != "nm-skynet" {
+			t.Fatalf("network defaults not filled, got interface %q", upgraded.DefaultInterface)
+		}
+		schema, err := GetSchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schema.Version != LatestSchemaVersion() || len(schema.Applied) != len(migrations) {
+			t.Fatalf("unexpected schema version %+v", schema)
+		}
+	})
+	t.Run("Idempotent", func(t *testing.T) {
+		results, err := RunMigrations(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 0 {
+			t.Fatalf("expected no pending migrations, got %+v", results)
+		}
+	})
+	t.Run("NewerSchema", func(t *testing.T) {
+		if err := storeSchemaVersion(&SchemaVersion{Version: LatestSchemaVersion() + 1}); err != nil {
+			t.Fatal(err)
+		}
+		defer storeSchemaVersion(&SchemaVersion{Version: LatestSchemaVersion()})
+		if _, err := RunMigrations(false); err == nil {
+			t.Fatal("expected error running migrations against a newer schema")
+		}
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	if err := seedTable(benchTableName, 3); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Commit", func(t *testing.T) {
+		err := Transaction(func(tx *Tx) error {
+			if err := tx.Insert("new", `{"id":"new"}`, benchTableName); err != nil {
+				return err
+			}
+			if err := tx.DeleteRecord(benchTableName, benchKey(0)); err != nil {
+				return err
+			}
+			if value, err := tx.FetchRecord(benchTableName, "new"); err != nil || value != `{"id":"new"}` {
+				t.Fatalf("transaction did not see its own insert: %q, %v", value, err)
+			}
+			if _, err := tx.FetchRecord(benchTableName, benchKey(0)); !IsEmptyRecord(err) {
+				t.Fatalf("transaction did not see its own delete: %v", err)
+			}
+			records, err := tx.FetchRecords(benchTableName)
+			if err != nil || len(records) != 3 {
+				t.Fatalf("unexpected transaction records %v, %v", records, err)
+			}
+			if _, err := FetchRecord(benchTableName, "new"); !IsEmptyRecord(err) {
+				t.Fatal("insert visible before commit")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = FetchRecord(benchTableName, "new"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = FetchRecord(benchTableName, benchKey(0)); !IsEmptyRecord(err) {
+			t.Fatalf("expected deleted record, got %v", err)
+		}
+	})
+	t.Run("Rollback", func(t *testing.T) {
+		err := Transaction(func(tx *Tx) error {
+			if err := tx.DeleteRecord(benchTableName, benchKey(1)); err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		if err == nil || err.Error() != "abort" {
+			t.Fatalf("expected abort error, got %v", err)
+		}
+		if _, err = FetchRecord(benchTableName, benchKey(1)); err != nil {
+			t.Fatalf("rolled back delete was applied: %v", err)
+		}
+	})
+	t.Run("FailedCommit", func(t *testing.T) {
+		tx := BeginTx()
+		tx.DeleteRecord(benchTableName, benchKey(2))
+		tx.Insert("orphan", `{"id":"orphan"}`, "doesnotexist")
+		if err := tx.Commit(); err == nil {
+			t.Fatal("expected commit to a missing table to fail")
+		}
+		if _, err := FetchRecord(benchTableName, benchKey(2)); err != nil {
+			t.Fatalf("partial commit applied: %v", err)
+		}
+		if err := tx.Insert("late", `{"id":"late"}`, benchTableName); err != ErrTxClosed {
+			t.Fatalf("expected closed transaction error, got %v", err)
+		}
+	})
+}
+
+func TestCopyTables(t *testing.T) {
+	src, err := NewBackend("sqlite", "copy/source.db", config.SQLConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = src.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := NewBackend("memory", "", config.SQLConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dst.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if _, err = NewBackend("mongo", "", config.SQLConfig{}); err == nil {
+		t.Fatal("expected error for unknown database type")
+	}
+	if err = src.CreateTable(NETWORKS_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err = src.Insert(fmt.Sprintf("net%d", i), fmt.Sprintf(`{"netid":"net%d"}`, i), NETWORKS_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Copy", func(t *testing.T) {
+		results, err := CopyTables(src, dst, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(Tables()) {
+			t.Fatalf("expected %d tables copied, got %d", len(Tables()), len(results))
+		}
+		for _, result := range results {
+			if result.Table == NETWORKS_TABLE_NAME && result.Records != 10 {
+				t.Fatalf("expected 10 networks copied, got %d", result.Records)
+			}
+		}
+		value, err := dst.FetchRecord(NETWORKS_TABLE_NAME, "net3")
+		if err != nil || value != `{"netid":"net3"}` {
+			t.Fatalf("unexpected copied record %q, %v", value, err)
+		}
+	})
+	t.Run("NonEmptyDestination", func(t *testing.T) {
+		if _, err := CopyTables(src, dst, false); err == nil {
+			t.Fatal("expected copy into a non empty destination to fail")
+		}
+	})
+	t.Run("Force", func(t *testing.T) {
+		if err := dst.Insert("stale", `{"netid":"stale"}`, NETWORKS_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CopyTables(src, dst, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dst.FetchRecord(NETWORKS_TABLE_NAME, "stale"); err == nil {
+			t.Fatal("expected forced copy to clear the destination")
+		}
+	})
+}
+
+func TestEncryption(t *testing.T) {
+	defer SetEncryptionKey(nil)
+	const secret = `{"clientid":"client","privatekey":"c2VjcmV0"}`
+	if err := Insert("legacy", secret, EXT_CLIENT_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteAllRecords(EXT_CLIENT_TABLE_NAME)
+	key, err := ParseEncryptionKey(base64.StdEncoding.EncodeToString(make([]byte, ENCRYPTION_KEY_SIZE)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseEncryptionKey("c2hvcnQ="); err == nil {
+		t.Fatal("expected error parsing a short key")
+	}
+	if err = SetEncryptionKey(key); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("EncryptsSecretTables", func(t *testing.T) {
+		if err := Insert("client", secret, EXT_CLIENT_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := getCurrentDB().FetchRecord(EXT_CLIENT_TABLE_NAME, "client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(raw, "privatekey") {
+			t.Fatalf("secret stored in plaintext: %s", raw)
+		}
+		value, err := FetchRecord(EXT_CLIENT_TABLE_NAME, "client")
+		if err != nil || value != secret {
+			t.Fatalf("unexpected decrypted record %q, %v", value, err)
+		}
+	})
+	t.Run("ReadsPlaintextRecords", func(t *testing.T) {
+		records, err := FetchRecords(EXT_CLIENT_TABLE_NAME)
+		if err != nil || records["legacy"] != secret {
+			t.Fatalf("unexpected records %v, %v", records, err)
+		}
+	})
+	t.Run("Transaction", func(t *testing.T) {
+		if err := Transaction(func(tx *Tx) error {
+			return tx.Insert("txclient", secret, EXT_CLIENT_TABLE_NAME)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := getCurrentDB().FetchRecord(EXT_CLIENT_TABLE_NAME, "txclient")
+		if err != nil || strings.Contains(raw, "privatekey") {
+			t.Fatalf("secret stored in plaintext: %s, %v", raw, err)
+		}
+	})
+	t.Run("Rotate", func(t *testing.T) {
+		newKey := make([]byte, ENCRYPTION_KEY_SIZE)
+		newKey[0] = 1
+		count, err := RotateEncryptionKey(newKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count < 3 {
+			t.Fatalf("expected at least 3 records rotated, got %d", count)
+		}
+		if err = SetEncryptionKey(key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = FetchRecord(EXT_CLIENT_TABLE_NAME, "legacy"); err == nil {
+			t.Fatal("expected error decrypting with the old key")
+		}
+		if err = SetEncryptionKey(newKey); err != nil {
+			t.Fatal(err)
+		}
+		value, err := FetchRecord(EXT_CLIENT_TABLE_NAME, "legacy")
+		if err != nil || value != secret {
+			t.Fatalf("unexpected decrypted record %q, %v", value, err)
+		}
+	})
+	t.Run("Decrypt", func(t *testing.T) {
+		if _, err := RotateEncryptionKey(nil); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := getCurrentDB().FetchRecord(EXT_CLIENT_TABLE_NAME, "client")
+		if err != nil || raw != secret {
+			t.Fatalf("expected plaintext record, got %q, %v", raw, err)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// ENCRYPTION_KEY_SIZE - size in bytes of the database encryption key
+const ENCRYPTION_KEY_SIZE = 32
+
+// ENCRYPTED_RECORD_VERSION - version of the envelope wrapping encrypted records
+const ENCRYPTED_RECORD_VERSION = "v1"
+
+// encryptedTables - tables whose records hold secrets and are encrypted at rest
+var encryptedTables = map[string]bool{
+	EXT_CLIENT_TABLE_NAME:  true,
+	SERVERCONF_TABLE_NAME:  true,
+	SERVER_UUID_TABLE_NAME: true,
+}
+
+// encryptedRecord - envelope stored in place of a record,
+// the record is sealed with a random data key which is in turn sealed with the server's key
+type encryptedRecord struct {
+	Encrypted string `json:"encrypted"`
+	KeyID     string `json:"keyid"`
+	DataKey   string `json:"datakey"`
+	Data      string `json:"data"`
+}
+
+var (
+	encryptionMutex sync.RWMutex
+	encryptionKey   []byte
+)
+
+// ParseEncryptionKey - decodes a base64 encoded database encryption key
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid database encryption key: %w", err)
+	}
+	if len(key) != ENCRYPTION_KEY_SIZE {
+		return nil, fmt.Errorf("invalid database encryption key: must be %d bytes, got %d", ENCRYPTION_KEY_SIZE, len(key))
+	}
+	return key, nil
+}
+
+// ReadEncryptionKeyFile - reads a base64 encoded database encryption key from a file
+func ReadEncryptionKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEncryptionKey(string(data))
+}
+
+// SetEncryptionKey - sets the key used to encrypt secrets at rest, a nil key stores them in plaintext
+func SetEncryptionKey(key []byte) error {
+	if key != nil && len(key) != ENCRYPTION_KEY_SIZE {
+		return fmt.Errorf("invalid database encryption key: must be %d bytes, got %d", ENCRYPTION_KEY_SIZE, len(key))
+	}
+	encryptionMutex.Lock()
+	defer encryptionMutex.Unlock()
+	encryptionKey = key
+	return nil
+}
+
+// IsEncrypted - checks if secrets are encrypted at rest
+func IsEncrypted() bool {
+	encryptionMutex.RLock()
+	defer encryptionMutex.RUnlock()
+	return encryptionKey != nil
+}
+
+// RotateEncryptionKey - re-encrypts every secret record with newKey in a single batch and makes it the current key
+// a nil newKey decrypts the records back to plaintext, records still in plaintext are encrypted,
+// servers using the database must be stopped first as they keep the key they were started with
+func RotateEncryptionKey(newKey []byte) (int, error) {
+	if newKey != nil && len(newKey) != ENCRYPTION_KEY_SIZE {
+		return 0, fmt.Errorf("invalid database encryption key: must be %d bytes, got %d", ENCRYPTION_KEY_SIZE, len(newKey))
+	}
+	var ops []TxOp
+	for _, table := range tables {
+		if !encryptedTables[table] {
+			continue
+		}
+		records, err := FetchRecords(table)
+		if err != nil {
+			if IsEmptyRecord(err) {
+				continue
+			}
+			return 0, fmt.Errorf("failed to read table %s: %w", table, err)
+		}
+		for key, value := range records {
+			if newKey != nil {
+				if value, err = sealRecord(newKey, table, key, value); err != nil {
+					return 0, err
+				}
+			}
+			ops = append(ops, TxOp{Table: table, Key: key, Value: value})
+		}
+	}
+	encryptionMutex.Lock()
+	defer encryptionMutex.Unlock()
+	if len(ops) > 0 {
+		if err := getCurrentDB().Commit(ops); err != nil {
+			return 0, err
+		}
+	}
+	encryptionKey = newKey
+	return len(ops), nil
+}
+
+// loadEncryptionKey - loads the encryption key from the server config, if one is set
+func loadEncryptionKey() error {
+	var key []byte
+	var err error
+	if keyFile := servercfg.GetDBEncryptionKeyFile(); keyFile != "" {
+		key, err = ReadEncryptionKeyFile(keyFile)
+	} else if encoded := servercfg.GetDBEncryptionKey(); encoded != "" {
+		key, err = ParseEncryptionKey(encoded)
+	}
+	if err != nil {
+		return err
+	}
+	return SetEncryptionKey(key)
+}
+
+// encryptValue - encrypts a record of a secret table with the current key
+func encryptValue(tableName string, key string, value string) (string, error) {
+	if !encryptedTables[tableName] {
+		return value, nil
+	}
+	encryptionMutex.RLock()
+	defer encryptionMutex.RUnlock()
+	if encryptionKey == nil {
+		return value, nil
+	}
+	return sealRecord(encryptionKey, tableName, key, value)
+}
+
+// encryptOps - encrypts the values of queued writes to secret tables
+func encryptOps(ops []TxOp) ([]TxOp, error) {
+	encrypted := make([]TxOp, len(ops))
+	for i, op := range ops {
+		if !op.Delete {
+			var err error
+			if op.Value, err = encryptValue(op.Table, op.Key, op.Value); err != nil {
+				return nil, err
+			}
+		}
+		encrypted[i] = op
+	}
+	return encrypted, nil
+}
+
+// decryptValue - decrypts a record of a secret table, records stored before encryption was enabled are returned as is
+func decryptValue(tableName string, key string, value string) (string, error) {
+	if !encryptedTables[tableName] {
+		return value, nil
+	}
+	var envelope encryptedRecord
+	if err := json.Unmarshal([]byte(value), &envelope); err != nil || envelope.Encrypted == "" {
+		return value, nil
+	}
+	encryptionMutex.RLock()
+	defer encryptionMutex.RUnlock()
+	if encryptionKey == nil {
+		return "", fmt.Errorf("record %s in table %s is encrypted but no database encryption key is configured", key, tableName)
+	}
+	return openRecord(encryptionKey, tableName, key, &envelope)
+}
+
+// decryptRecords - decrypts every record of a secret table in place
+func decryptRecords(tableName string, records map[string]string) error {
+	if !encryptedTables[tableName] {
+		return nil
+	}
+	for key, value := range records {
+		decrypted, err := decryptValue(tableName, key, value)
+		if err != nil {
+			return err
+		}
+		records[key] = decrypted
+	}
+	return nil
+}
+
+// encryptRecords - encrypts every record of a secret table in place with the current key
+func encryptRecords(tableName string, records map[string]string) error {
+	for key, value := range records {
+		encrypted, err := encryptValue(tableName, key, value)
+		if err != nil {
+			return err
+		}
+		records[key] = encrypted
+	}
+	return nil
+}
+
+// sealRecord - wraps a record in an envelope sealed with a new data key
+// the table and key are authenticated so a sealed record cannot be moved to another record
+func sealRecord(masterKey []byte, tableName string, key string, value string) (string, error) {
+	dataKey := make([]byte, ENCRYPTION_KEY_SIZE)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	location := []byte(tableName + "/" + key)
+	data, err := seal(dataKey, []byte(value), location)
+	if err != nil {
+		return "", err
+	}
+	sealedKey, err := seal(masterKey, dataKey, location)
+	if err != nil {
+		return "", err
+	}
+	envelope, err := json.Marshal(&encryptedRecord{
+		Encrypted: ENCRYPTED_RECORD_VERSION,
+		KeyID:     encryptionKeyID(masterKey),
+		DataKey:   base64.StdEncoding.EncodeToString(sealedKey),
+		Data:      base64.StdEncoding.EncodeToString(data),
+	})
+	return string(envelope), err
+}
+
+// openRecord - unwraps a record sealed by sealRecord
+func openRecord(masterKey []byte, tableName string, key string, envelope *encryptedRecord) (string, error) {
+	if envelope.Encrypted != ENCRYPTED_RECORD_VERSION {
+		return "", fmt.Errorf("record %s in table %s uses unsupported encryption version %s", key, tableName, envelope.Encrypted)
+	}
+	if envelope.KeyID != encryptionKeyID(masterKey) {
+		return "", fmt.Errorf("record %s in table %s is encrypted with key %s, configured key is %s", key, tableName, envelope.KeyID, encryptionKeyID(masterKey))
+	}
+	sealedKey, err := base64.StdEncoding.DecodeString(envelope.DataKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return "", err
+	}
+	location := []byte(tableName + "/" + key)
+	dataKey, err := open(masterKey, sealedKey, location)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt record %s in table %s: %w", key, tableName, err)
+	}
+	value, err := open(dataKey, data, location)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt record %s in table %s: %w", key, tableName, err)
+	}
+	return string(value), nil
+}
+
+// encryptionKeyID - short fingerprint identifying which key sealed a record
+func encryptionKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
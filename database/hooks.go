@@ -0,0 +1,40 @@
+package database
+
+import "sync"
+
+// WriteHook - notified of the plaintext records after a write succeeds
+// a Delete op with an empty Key means every record of the table was deleted
+type WriteHook func(ops []TxOp)
+
+var (
+	hooksMutex sync.RWMutex
+	writeHooks []WriteHook
+	// writeMutex - orders writes and their notifications so hooks see writes in the order they were applied
+	writeMutex sync.Mutex
+)
+
+// AddWriteHook - registers a hook notified of every write made through this package
+func AddWriteHook(hook WriteHook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	writeHooks = append(writeHooks, hook)
+}
+
+// writeAndNotify - runs write and passes ops to the write hooks if it succeeds
+func writeAndNotify(ops []TxOp, write func() error) error {
+	hooksMutex.RLock()
+	hooks := writeHooks
+	hooksMutex.RUnlock()
+	if len(hooks) == 0 {
+		return write()
+	}
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	if err := write(); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		hook(ops)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"errors"
+	"sync"
+)
+
+// memoryBackend - Backend implementation that keeps every table in process memory
+// nothing is persisted, so it is meant for tests and ephemeral dev environments
+type memoryBackend struct {
+	mu     sync.RWMutex
+	tables map[string]map[string]string
+}
+
+var memoryDB Backend = instrument("memory", &memoryBackend{})
+
+func (m *memoryBackend) Init() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables == nil {
+		m.tables = make(map[string]map[string]string)
+	}
+	return nil
+}
+
+func (m *memoryBackend) CreateTable(tableName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables == nil {
+		return errors.New("memory database is not initialized")
+	}
+	if _, ok := m.tables[tableName]; !ok {
+		m.tables[tableName] = make(map[string]string)
+	}
+	return nil
+}
+
+func (m *memoryBackend) Insert(key string, value string, tableName string) error {
+	if key == "" || value == "" || !IsJSONString(value) {
+		return errors.New("invalid insert " + key + " : " + value)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(tableName)
+	if err != nil {
+		return err
+	}
+	table[key] = value
+	return nil
+}
+
+func (m *memoryBackend) FetchRecord(tableName string, key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	table, err := m.table(tableName)
+	if err != nil {
+		return "", err
+	}
+	if len(table) == 0 {
+		return "", errors.New(NO_RECORDS)
+	}
+	value, ok := table[key]
+	if !ok || value == "" {
+		return "", errors.New(NO_RECORD)
+	}
+	return value, nil
+}
+
+func (m *memoryBackend) FetchRecords(tableName string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	table, err := m.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, errors.New(NO_RECORDS)
+	}
+	records := make(map[string]string, len(table))
+	for key, value := range table {
+		records[key] = value
+	}
+	return records, nil
+}
+
+func (m *memoryBackend) DeleteRecord(tableName string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table, err := m.table(tableName)
+	if err != nil {
+		return err
+	}
+	delete(table, key)
+	return nil
+}
+
+func (m *memoryBackend) DeleteAllRecords(tableName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.table(tableName); err != nil {
+		return err
+	}
+	m.tables[tableName] = make(map[string]string)
+	return nil
+}
+
+func (m *memoryBackend) Commit(ops []TxOp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, op := range ops {
+		if _, err := m.table(op.Table); err != nil {
+			return err
+		}
+	}
+	for _, op := range ops {
+		if op.Delete {
+			delete(m.tables[op.Table], op.Key)
+		} else {
+			m.tables[op.Table][op.Key] = op.Value
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables = nil
+}
+
+// table - returns the named table, caller must hold the lock
+func (m *memoryBackend) table(tableName string) (map[string]string, error) {
+	table, ok := m.tables[tableName]
+	if !ok {
+		return nil, errors.New("no such table: " + tableName)
+	}
+	return table, nil
+}
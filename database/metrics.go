@@ -0,0 +1,72 @@
+package database
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/metrics"
+)
+
+var operationDuration = metrics.NewHistogramVec(
+	"netmaker_db_operation_duration_seconds",
+	"Latency of database operations by backend and operation.",
+	metrics.DefaultBuckets,
+	"backend", "operation",
+)
+
+// instrumentedBackend - records the latency of every operation of the server's database
+type instrumentedBackend struct {
+	name    string
+	backend Backend
+}
+
+func instrument(name string, backend Backend) Backend {
+	return &instrumentedBackend{name: name, backend: backend}
+}
+
+func (i *instrumentedBackend) observe(operation string, start time.Time) {
+	operationDuration.WithLabelValues(i.name, operation).Observe(time.Since(start).Seconds())
+}
+
+func (i *instrumentedBackend) Init() error {
+	defer i.observe("init", time.Now())
+	return i.backend.Init()
+}
+
+func (i *instrumentedBackend) CreateTable(tableName string) error {
+	defer i.observe("createtable", time.Now())
+	return i.backend.CreateTable(tableName)
+}
+
+func (i *instrumentedBackend) Insert(key string, value string, tableName string) error {
+	defer i.observe("insert", time.Now())
+	return i.backend.Insert(key, value, tableName)
+}
+
+func (i *instrumentedBackend) FetchRecord(tableName string, key string) (string, error) {
+	defer i.observe("fetchrecord", time.Now())
+	return i.backend.FetchRecord(tableName, key)
+}
+
+func (i *instrumentedBackend) FetchRecords(tableName string) (map[string]string, error) {
+	defer i.observe("fetchrecords", time.Now())
+	return i.backend.FetchRecords(tableName)
+}
+
+func (i *instrumentedBackend) DeleteRecord(tableName string, key string) error {
+	defer i.observe("deleterecord", time.Now())
+	return i.backend.DeleteRecord(tableName, key)
+}
+
+func (i *instrumentedBackend) DeleteAllRecords(tableName string) error {
+	defer i.observe("deleteallrecords", time.Now())
+	return i.backend.DeleteAllRecords(tableName)
+}
+
+func (i *instrumentedBackend) Commit(ops []TxOp) error {
+	defer i.observe("commit", time.Now())
+	return i.backend.Commit(ops)
+}
+
+func (i *instrumentedBackend) Close() {
+	i.backend.Close()
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// SCHEMA_VERSION_RECORD_KEY - key of the schema version record in the generated table
+const SCHEMA_VERSION_RECORD_KEY = "schemaversion"
+
+// Migration - an ordered upgrade step for the JSON records of a table
+// Upgrade receives a stored record and returns its upgraded form,
+// it must be idempotent as it may run again on already upgraded records
+type Migration struct {
+	Version     int
+	Description string
+	Table       string
+	Upgrade     func(key string, value string) (string, error)
+}
+
+// AppliedMigration - log entry of a migration run against the database
+type AppliedMigration struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Table       string `json:"table"`
+	Records     int    `json:"records"`
+	AppliedAt   int64  `json:"appliedat"`
+}
+
+// SchemaVersion - the schema version of stored records and the migrations applied to reach it
+type SchemaVersion struct {
+	Version int                `json:"version"`
+	Applied []AppliedMigration `json:"applied"`
+}
+
+var (
+	migrationsMutex sync.Mutex
+	migrations      []Migration
+)
+
+func init() {
+	RegisterMigration(Migration{
+		Version:     1,
+		Description: "fill network defaults",
+		Table:       NETWORKS_TABLE_NAME,
+		Upgrade: func(key, value string) (string, error) {
+			var network models.Network
+			if err := json.Unmarshal([]byte(value), &network); err != nil {
+				return "", err
+			}
+			network.SetDefaults()
+			data, err := json.Marshal(&network)
+			return string(data), err
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     2,
+		Description: "give users the network admin role on their networks",
+		Table:       USERS_TABLE_NAME,
+		Upgrade: func(key, value string) (string, error) {
+			// roles are stored beside the fields of models.User
+			var user struct {
+				models.User
+				NetworkRoles map[string]string `json:"networkroles"`
+			}
+			if err := json.Unmarshal([]byte(value), &user); err != nil {
+				return "", err
+			}
+			// users could already do everything on their networks short of deleting them
+			if user.IsAdmin || user.NetworkRoles != nil {
+				return value, nil
+			}
+			user.NetworkRoles = make(map[string]string, len(user.Networks))
+			for _, network := range user.Networks {
+				user.NetworkRoles[network] = models.NETWORK_ROLE_ADMIN
+			}
+			data, err := json.Marshal(&user)
+			return string(data), err
+		},
+	})
+}
+
+// RegisterMigration - adds a migration to the registry, versions must be unique
+func RegisterMigration(migration Migration) {
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	for i := range migrations {
+		if migrations[i].Version == migration.Version {
+			panic(fmt.Sprintf("duplicate database migration version %d", migration.Version))
+		}
+	}
+	migrations = append(migrations, migration)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// LatestSchemaVersion - the schema version this server writes
+func LatestSchemaVersion() int {
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// GetSchemaVersion - fetches the schema version of the stored records
+func GetSchemaVersion() (SchemaVersion, error) {
+	var schema SchemaVersion
+	record, err := FetchRecord(GENERATED_TABLE_NAME, SCHEMA_VERSION_RECORD_KEY)
+	if err != nil {
+		if IsEmptyRecord(err) {
+			return schema, nil
+		}
+		return schema, err
+	}
+	err = json.Unmarshal([]byte(record), &schema)
+	return schema, err
+}
+
+// RunMigrations - applies every migration newer than the stored schema version in order
+// in dry run mode the records that would change are counted but nothing is written
+func RunMigrations(dryRun bool) ([]AppliedMigration, error) {
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+	schema, err := GetSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	var latest int
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if schema.Version > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than supported version %d", schema.Version, latest)
+	}
+	var results []AppliedMigration
+	for _, migration := range migrations {
+		if migration.Version <= schema.Version {
+			continue
+		}
+		changed, err := runMigration(&migration, dryRun)
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		applied := AppliedMigration{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Table:       migration.Table,
+			Records:     changed,
+			AppliedAt:   time.Now().Unix(),
+		}
+		results = append(results, applied)
+		if dryRun {
+			logger.Log(0, "dry run: migration", fmt.Sprint(migration.Version), migration.Description, "would change", fmt.Sprint(changed), "records in", migration.Table)
+			continue
+		}
+		schema.Version = migration.Version
+		schema.Applied = append(schema.Applied, applied)
+		if err = storeSchemaVersion(&schema); err != nil {
+			return results, err
+		}
+		logger.Log(0, "applied migration", fmt.Sprint(migration.Version), migration.Description, "changed", fmt.Sprint(changed), "records in", migration.Table)
+	}
+	return results, nil
+}
+
+// runMigration - upgrades each record of the migration's table, returning the number changed
+func runMigration(migration *Migration, dryRun bool) (int, error) {
+	records, err := FetchRecords(migration.Table)
+	if err != nil {
+		if IsEmptyRecord(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var changed int
+	for key, value := range records {
+		upgraded, err := migration.Upgrade(key, value)
+		if err != nil {
+			return changed, fmt.Errorf("record %s: %w", key, err)
+		}
+		if upgraded == value {
+			continue
+		}
+		changed++
+		if dryRun {
+			continue
+		}
+		if err = Insert(key, upgraded, migration.Table); err != nil {
+			return changed, fmt.Errorf("record %s: %w", key, err)
+		}
+	}
+	return changed, nil
+}
+
+func storeSchemaVersion(schema *SchemaVersion) error {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	return Insert(SCHEMA_VERSION_RECORD_KEY, string(data), GENERATED_TABLE_NAME)
+}
@@ -5,47 +5,45 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/gravitl/netmaker/config"
 	"github.com/gravitl/netmaker/servercfg"
 	_ "github.com/lib/pq"
 )
 
-// PGDB - database object for PostGreSQL
-var PGDB *sql.DB
-
-// PG_FUNCTIONS - map of db functions for PostGreSQL
-var PG_FUNCTIONS = map[string]interface{}{
-	INIT_DB:      initPGDB,
-	CREATE_TABLE: pgCreateTable,
-	INSERT:       pgInsert,
-	INSERT_PEER:  pgInsertPeer,
-	DELETE:       pgDeleteRecord,
-	DELETE_ALL:   pgDeleteAllRecords,
-	FETCH_ALL:    pgFetchRecords,
-	CLOSE_DB:     pgCloseDB,
+// pgBackend - Backend implementation for PostGreSQL
+// connects with the server's SQL config unless conf is set
+type pgBackend struct {
+	db   *sql.DB
+	conf *config.SQLConfig
 }
 
-func getPGConnString() string {
-	pgconf := servercfg.GetSQLConf()
+var pgDB Backend = instrument("postgres", &pgBackend{})
+
+func getPGConnString(pgconf config.SQLConfig) string {
 	pgConn := fmt.Sprintf("host=%s port=%d user=%s "+
 		"password=%s dbname=%s sslmode=%s connect_timeout=5",
 		pgconf.Host, pgconf.Port, pgconf.Username, pgconf.Password, pgconf.DB, pgconf.SSLMode)
 	return pgConn
 }
 
-func initPGDB() error {
-	connString := getPGConnString()
+func (p *pgBackend) Init() error {
+	if p.conf == nil {
+		conf := servercfg.GetSQLConf()
+		p.conf = &conf
+	}
+	connString := getPGConnString(*p.conf)
 	var dbOpenErr error
-	PGDB, dbOpenErr = sql.Open("postgres", connString)
+	p.db, dbOpenErr = sql.Open("postgres", connString)
 	if dbOpenErr != nil {
 		return dbOpenErr
 	}
-	dbOpenErr = PGDB.Ping()
+	dbOpenErr = p.db.Ping()
 
 	return dbOpenErr
 }
 
-func pgCreateTable(tableName string) error {
-	statement, err := PGDB.Prepare("CREATE TABLE IF NOT EXISTS " + tableName + " (key TEXT NOT NULL UNIQUE PRIMARY KEY, value TEXT)")
+func (p *pgBackend) CreateTable(tableName string) error {
+	statement, err := p.db.Prepare("CREATE TABLE IF NOT EXISTS " + tableName + " (key TEXT NOT NULL UNIQUE PRIMARY KEY, value TEXT)")
 	if err != nil {
 		return err
 	}
@@ -57,10 +55,10 @@ func pgCreateTable(tableName string) error {
 	return nil
 }
 
-func pgInsert(key string, value string, tableName string) error {
+func (p *pgBackend) Insert(key string, value string, tableName string) error {
 	if key != "" && value != "" && IsJSONString(value) {
 		insertSQL := "INSERT INTO " + tableName + " (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = $3;"
-		statement, err := PGDB.Prepare(insertSQL)
+		statement, err := p.db.Prepare(insertSQL)
 		if err != nil {
 			return err
 		}
@@ -75,21 +73,9 @@ func pgInsert(key string, value string, tableName string) error {
 	}
 }
 
-func pgInsertPeer(key string, value string) error {
-	if key != "" && value != "" && IsJSONString(value) {
-		err := pgInsert(key, value, PEERS_TABLE_NAME)
-		if err != nil {
-			return err
-		}
-		return nil
-	} else {
-		return errors.New("invalid peer insert " + key + " : " + value)
-	}
-}
-
-func pgDeleteRecord(tableName string, key string) error {
+func (p *pgBackend) DeleteRecord(tableName string, key string) error {
 	deleteSQL := "DELETE FROM " + tableName + " WHERE key = $1;"
-	statement, err := PGDB.Prepare(deleteSQL)
+	statement, err := p.db.Prepare(deleteSQL)
 	if err != nil {
 		return err
 	}
@@ -100,9 +86,9 @@ func pgDeleteRecord(tableName string, key string) error {
 	return nil
 }
 
-func pgDeleteAllRecords(tableName string) error {
+func (p *pgBackend) DeleteAllRecords(tableName string) error {
 	deleteSQL := "DELETE FROM " + tableName
-	statement, err := PGDB.Prepare(deleteSQL)
+	statement, err := p.db.Prepare(deleteSQL)
 	if err != nil {
 		return err
 	}
@@ -113,8 +99,32 @@ func pgDeleteAllRecords(tableName string) error {
 	return nil
 }
 
-func pgFetchRecords(tableName string) (map[string]string, error) {
-	row, err := PGDB.Query("SELECT * FROM " + tableName + " ORDER BY key")
+func (p *pgBackend) FetchRecord(tableName string, key string) (string, error) {
+	var value string
+	err := p.db.QueryRow("SELECT value FROM "+tableName+" WHERE key = $1;", key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", p.missingRecordErr(tableName)
+		}
+		return "", err
+	}
+	if value == "" {
+		return "", errors.New(NO_RECORD)
+	}
+	return value, nil
+}
+
+// pgBackend.missingRecordErr - keeps the NO_RECORDS/NO_RECORD distinction of a full table fetch
+func (p *pgBackend) missingRecordErr(tableName string) error {
+	var exists bool
+	if err := p.db.QueryRow("SELECT EXISTS (SELECT 1 FROM " + tableName + ");").Scan(&exists); err == nil && !exists {
+		return errors.New(NO_RECORDS)
+	}
+	return errors.New(NO_RECORD)
+}
+
+func (p *pgBackend) FetchRecords(tableName string) (map[string]string, error) {
+	row, err := p.db.Query("SELECT * FROM " + tableName + " ORDER BY key")
 	if err != nil {
 		return nil, err
 	}
@@ -132,6 +142,25 @@ func pgFetchRecords(tableName string) (map[string]string, error) {
 	return records, nil
 }
 
-func pgCloseDB() {
-	PGDB.Close()
+func (p *pgBackend) Commit(ops []TxOp) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Delete {
+			_, err = tx.Exec("DELETE FROM "+op.Table+" WHERE key = $1;", op.Key)
+		} else {
+			_, err = tx.Exec("INSERT INTO "+op.Table+" (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = $3;", op.Key, op.Value, op.Value)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *pgBackend) Close() {
+	p.db.Close()
 }
@@ -2,48 +2,46 @@ package database
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/gravitl/netmaker/servercfg"
 	"github.com/rqlite/gorqlite"
 )
 
-// RQliteDatabase - the rqlite db connection
-var RQliteDatabase gorqlite.Connection
-
-// RQLITE_FUNCTIONS - all the functions to run with rqlite
-var RQLITE_FUNCTIONS = map[string]interface{}{
-	INIT_DB:      initRqliteDatabase,
-	CREATE_TABLE: rqliteCreateTable,
-	INSERT:       rqliteInsert,
-	INSERT_PEER:  rqliteInsertPeer,
-	DELETE:       rqliteDeleteRecord,
-	DELETE_ALL:   rqliteDeleteAllRecords,
-	FETCH_ALL:    rqliteFetchRecords,
-	CLOSE_DB:     rqliteCloseDB,
+// rqliteBackend - Backend implementation for rqlite
+// connects with the server's SQL connection string unless connString is set
+type rqliteBackend struct {
+	conn       gorqlite.Connection
+	connString string
 }
 
-func initRqliteDatabase() error {
+var rqliteDB Backend = instrument("rqlite", &rqliteBackend{})
 
-	conn, err := gorqlite.Open(servercfg.GetSQLConn())
+func (r *rqliteBackend) Init() error {
+
+	if r.connString == "" {
+		r.connString = servercfg.GetSQLConn()
+	}
+	conn, err := gorqlite.Open(r.connString)
 	if err != nil {
 		return err
 	}
-	RQliteDatabase = conn
-	RQliteDatabase.SetConsistencyLevel("strong")
+	r.conn = conn
+	r.conn.SetConsistencyLevel("strong")
 	return nil
 }
 
-func rqliteCreateTable(tableName string) error {
-	_, err := RQliteDatabase.WriteOne("CREATE TABLE IF NOT EXISTS " + tableName + " (key TEXT NOT NULL UNIQUE PRIMARY KEY, value TEXT)")
+func (r *rqliteBackend) CreateTable(tableName string) error {
+	_, err := r.conn.WriteOne("CREATE TABLE IF NOT EXISTS " + tableName + " (key TEXT NOT NULL UNIQUE PRIMARY KEY, value TEXT)")
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func rqliteInsert(key string, value string, tableName string) error {
+func (r *rqliteBackend) Insert(key string, value string, tableName string) error {
 	if key != "" && value != "" && IsJSONString(value) {
-		_, err := RQliteDatabase.WriteOne("INSERT OR REPLACE INTO " + tableName + " (key, value) VALUES ('" + key + "', '" + value + "')")
+		_, err := r.conn.WriteOne("INSERT OR REPLACE INTO " + tableName + " (key, value) VALUES ('" + key + "', '" + value + "')")
 		if err != nil {
 			return err
 		}
@@ -52,50 +50,55 @@ func rqliteInsert(key string, value string, tableName string) error {
 	return errors.New("invalid insert " + key + " : " + value)
 }
 
-func rqliteInsertPeer(key string, value string) error {
-	if key != "" && value != "" && IsJSONString(value) {
-		_, err := RQliteDatabase.WriteOne("INSERT OR REPLACE INTO " + PEERS_TABLE_NAME + " (key, value) VALUES ('" + key + "', '" + value + "')")
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-	return errors.New("invalid peer insert " + key + " : " + value)
-}
-
-func rqliteDeleteRecord(tableName string, key string) error {
-	_, err := RQliteDatabase.WriteOne("DELETE FROM " + tableName + " WHERE key = \"" + key + "\"")
+func (r *rqliteBackend) DeleteRecord(tableName string, key string) error {
+	_, err := r.conn.WriteOne("DELETE FROM " + tableName + " WHERE key = \"" + key + "\"")
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func rqliteDeleteAllRecords(tableName string) error {
-	_, err := RQliteDatabase.WriteOne("DELETE TABLE " + tableName)
+func (r *rqliteBackend) DeleteAllRecords(tableName string) error {
+	_, err := r.conn.WriteOne("DELETE TABLE " + tableName)
 	if err != nil {
 		return err
 	}
-	err = rqliteCreateTable(tableName)
+	err = r.CreateTable(tableName)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func rqliteFetchRecord(tableName string, key string) (string, error) {
-	results, err := FetchRecords(tableName)
+func (r *rqliteBackend) FetchRecord(tableName string, key string) (string, error) {
+	row, err := r.conn.QueryOne("SELECT value FROM " + tableName + " WHERE key = '" + rqliteEscape(key) + "'")
 	if err != nil {
 		return "", err
 	}
-	if results[key] == "" {
+	if !row.Next() {
+		return "", r.missingRecordErr(tableName)
+	}
+	var value string
+	if err := row.Scan(&value); err != nil {
+		return "", err
+	}
+	if value == "" {
 		return "", errors.New(NO_RECORD)
 	}
-	return results[key], nil
+	return value, nil
+}
+
+// rqliteBackend.missingRecordErr - keeps the NO_RECORDS/NO_RECORD distinction of a full table fetch
+func (r *rqliteBackend) missingRecordErr(tableName string) error {
+	row, err := r.conn.QueryOne("SELECT 1 FROM " + tableName + " LIMIT 1")
+	if err == nil && row.NumRows() == 0 {
+		return errors.New(NO_RECORDS)
+	}
+	return errors.New(NO_RECORD)
 }
 
-func rqliteFetchRecords(tableName string) (map[string]string, error) {
-	row, err := RQliteDatabase.QueryOne("SELECT * FROM " + tableName + " ORDER BY key")
+func (r *rqliteBackend) FetchRecords(tableName string) (map[string]string, error) {
+	row, err := r.conn.QueryOne("SELECT * FROM " + tableName + " ORDER BY key")
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +115,24 @@ func rqliteFetchRecords(tableName string) (map[string]string, error) {
 	return records, nil
 }
 
-func rqliteCloseDB() {
-	RQliteDatabase.Close()
+// Commit - rqlite executes a batch of statements in a single transaction
+func (r *rqliteBackend) Commit(ops []TxOp) error {
+	statements := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.Delete {
+			statements = append(statements, "DELETE FROM "+op.Table+" WHERE key = '"+rqliteEscape(op.Key)+"'")
+		} else {
+			statements = append(statements, "INSERT OR REPLACE INTO "+op.Table+" (key, value) VALUES ('"+rqliteEscape(op.Key)+"', '"+rqliteEscape(op.Value)+"')")
+		}
+	}
+	_, err := r.conn.Write(statements)
+	return err
+}
+
+func rqliteEscape(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+func (r *rqliteBackend) Close() {
+	r.conn.Close()
 }
@@ -12,42 +12,39 @@ import (
 // == sqlite ==
 const dbFilename = "netmaker.db"
 
-// SqliteDB is the db object for sqlite database connections
-var SqliteDB *sql.DB
-
-// SQLITE_FUNCTIONS - contains a map of the functions for sqlite
-var SQLITE_FUNCTIONS = map[string]interface{}{
-	INIT_DB:      initSqliteDB,
-	CREATE_TABLE: sqliteCreateTable,
-	INSERT:       sqliteInsert,
-	INSERT_PEER:  sqliteInsertPeer,
-	DELETE:       sqliteDeleteRecord,
-	DELETE_ALL:   sqliteDeleteAllRecords,
-	FETCH_ALL:    sqliteFetchRecords,
-	CLOSE_DB:     sqliteCloseDB,
+// sqliteBackend - Backend implementation for sqlite
+// uses data/netmaker.db unless path is set
+type sqliteBackend struct {
+	db   *sql.DB
+	path string
 }
 
-func initSqliteDB() error {
+var sqliteDB Backend = instrument("sqlite", &sqliteBackend{})
+
+func (s *sqliteBackend) Init() error {
+	if s.path == "" {
+		s.path = filepath.Join("data", dbFilename)
+	}
 	// == create db file if not present ==
-	if _, err := os.Stat("data"); os.IsNotExist(err) {
-		os.Mkdir("data", 0700)
+	if _, err := os.Stat(filepath.Dir(s.path)); os.IsNotExist(err) {
+		os.MkdirAll(filepath.Dir(s.path), 0700)
 	}
-	dbFilePath := filepath.Join("data", dbFilename)
+	dbFilePath := s.path
 	if _, err := os.Stat(dbFilePath); os.IsNotExist(err) {
 		os.Create(dbFilePath)
 	}
 	// == "connect" the database ==
 	var dbOpenErr error
-	SqliteDB, dbOpenErr = sql.Open("sqlite3", dbFilePath)
+	s.db, dbOpenErr = sql.Open("sqlite3", dbFilePath)
 	if dbOpenErr != nil {
 		return dbOpenErr
 	}
-	SqliteDB.SetMaxOpenConns(1)
+	s.db.SetMaxOpenConns(1)
 	return nil
 }
 
-func sqliteCreateTable(tableName string) error {
-	statement, err := SqliteDB.Prepare("CREATE TABLE IF NOT EXISTS " + tableName + " (key TEXT NOT NULL UNIQUE PRIMARY KEY, value TEXT)")
+func (s *sqliteBackend) CreateTable(tableName string) error {
+	statement, err := s.db.Prepare("CREATE TABLE IF NOT EXISTS " + tableName + " (key TEXT NOT NULL UNIQUE PRIMARY KEY, value TEXT)")
 	if err != nil {
 		return err
 	}
@@ -59,10 +56,10 @@ func sqliteCreateTable(tableName string) error {
 	return nil
 }
 
-func sqliteInsert(key string, value string, tableName string) error {
+func (s *sqliteBackend) Insert(key string, value string, tableName string) error {
 	if key != "" && value != "" && IsJSONString(value) {
 		insertSQL := "INSERT OR REPLACE INTO " + tableName + " (key, value) VALUES (?, ?)"
-		statement, err := SqliteDB.Prepare(insertSQL)
+		statement, err := s.db.Prepare(insertSQL)
 		if err != nil {
 			return err
 		}
@@ -76,20 +73,9 @@ func sqliteInsert(key string, value string, tableName string) error {
 	return errors.New("invalid insert " + key + " : " + value)
 }
 
-func sqliteInsertPeer(key string, value string) error {
-	if key != "" && value != "" && IsJSONString(value) {
-		err := sqliteInsert(key, value, PEERS_TABLE_NAME)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-	return errors.New("invalid peer insert " + key + " : " + value)
-}
-
-func sqliteDeleteRecord(tableName string, key string) error {
+func (s *sqliteBackend) DeleteRecord(tableName string, key string) error {
 	deleteSQL := "DELETE FROM " + tableName + " WHERE key = \"" + key + "\""
-	statement, err := SqliteDB.Prepare(deleteSQL)
+	statement, err := s.db.Prepare(deleteSQL)
 	if err != nil {
 		return err
 	}
@@ -100,9 +86,9 @@ func sqliteDeleteRecord(tableName string, key string) error {
 	return nil
 }
 
-func sqliteDeleteAllRecords(tableName string) error {
+func (s *sqliteBackend) DeleteAllRecords(tableName string) error {
 	deleteSQL := "DELETE FROM " + tableName
-	statement, err := SqliteDB.Prepare(deleteSQL)
+	statement, err := s.db.Prepare(deleteSQL)
 	if err != nil {
 		return err
 	}
@@ -113,8 +99,32 @@ func sqliteDeleteAllRecords(tableName string) error {
 	return nil
 }
 
-func sqliteFetchRecords(tableName string) (map[string]string, error) {
-	row, err := SqliteDB.Query("SELECT * FROM " + tableName + " ORDER BY key")
+func (s *sqliteBackend) FetchRecord(tableName string, key string) (string, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM "+tableName+" WHERE key = ?", key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", s.missingRecordErr(tableName)
+		}
+		return "", err
+	}
+	if value == "" {
+		return "", errors.New(NO_RECORD)
+	}
+	return value, nil
+}
+
+// sqliteBackend.missingRecordErr - keeps the NO_RECORDS/NO_RECORD distinction of a full table fetch
+func (s *sqliteBackend) missingRecordErr(tableName string) error {
+	var exists bool
+	if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM " + tableName + ")").Scan(&exists); err == nil && !exists {
+		return errors.New(NO_RECORDS)
+	}
+	return errors.New(NO_RECORD)
+}
+
+func (s *sqliteBackend) FetchRecords(tableName string) (map[string]string, error) {
+	row, err := s.db.Query("SELECT * FROM " + tableName + " ORDER BY key")
 	if err != nil {
 		return nil, err
 	}
@@ -132,6 +142,25 @@ func sqliteFetchRecords(tableName string) (map[string]string, error) {
 	return records, nil
 }
 
-func sqliteCloseDB() {
-	SqliteDB.Close()
+func (s *sqliteBackend) Commit(ops []TxOp) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Delete {
+			_, err = tx.Exec("DELETE FROM "+op.Table+" WHERE key = ?", op.Key)
+		} else {
+			_, err = tx.Exec("INSERT OR REPLACE INTO "+op.Table+" (key, value) VALUES (?, ?)", op.Key, op.Value)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteBackend) Close() {
+	s.db.Close()
 }
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"errors"
+	"sync"
+)
+
+// TxOp - a single write queued in a transaction
+type TxOp struct {
+	Table  string
+	Key    string
+	Value  string
+	Delete bool
+}
+
+// Tx - queues record writes and applies them atomically on Commit
+// reads through a Tx see its own queued writes
+// a nil *Tx writes straight to the database, so callers can thread an optional transaction
+type Tx struct {
+	mu     sync.Mutex
+	ops    []TxOp
+	closed bool
+}
+
+// ErrTxClosed - returned when using a transaction after Commit or Rollback
+var ErrTxClosed = errors.New("transaction already committed or rolled back")
+
+// BeginTx - starts a new transaction
+func BeginTx() *Tx {
+	return &Tx{}
+}
+
+// Transaction - runs fn in a transaction, committing if it succeeds and discarding its writes otherwise
+func Transaction(fn func(tx *Tx) error) error {
+	tx := BeginTx()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Tx.Insert - queues an insert of a record
+func (tx *Tx) Insert(key string, value string, tableName string) error {
+	if tx == nil {
+		return Insert(key, value, tableName)
+	}
+	if key == "" || value == "" || !IsJSONString(value) {
+		return errors.New("invalid insert " + key + " : " + value)
+	}
+	return tx.queue(TxOp{Table: tableName, Key: key, Value: value})
+}
+
+// Tx.DeleteRecord - queues the deletion of a record
+func (tx *Tx) DeleteRecord(tableName string, key string) error {
+	if tx == nil {
+		return DeleteRecord(tableName, key)
+	}
+	return tx.queue(TxOp{Table: tableName, Key: key, Delete: true})
+}
+
+// Tx.FetchRecord - fetches a record, including writes queued in the transaction
+func (tx *Tx) FetchRecord(tableName string, key string) (string, error) {
+	if tx == nil {
+		return FetchRecord(tableName, key)
+	}
+	tx.mu.Lock()
+	for i := len(tx.ops) - 1; i >= 0; i-- {
+		op := tx.ops[i]
+		if op.Table == tableName && op.Key == key {
+			tx.mu.Unlock()
+			if op.Delete {
+				return "", errors.New(NO_RECORD)
+			}
+			return op.Value, nil
+		}
+	}
+	tx.mu.Unlock()
+	return FetchRecord(tableName, key)
+}
+
+// Tx.FetchRecords - fetches all records of a table, including writes queued in the transaction
+func (tx *Tx) FetchRecords(tableName string) (map[string]string, error) {
+	if tx == nil {
+		return FetchRecords(tableName)
+	}
+	records, err := FetchRecords(tableName)
+	if err != nil && !IsEmptyRecord(err) {
+		return nil, err
+	}
+	if records == nil {
+		records = make(map[string]string)
+	}
+	tx.mu.Lock()
+	for _, op := range tx.ops {
+		if op.Table != tableName {
+			continue
+		}
+		if op.Delete {
+			delete(records, op.Key)
+		} else {
+			records[op.Key] = op.Value
+		}
+	}
+	tx.mu.Unlock()
+	if len(records) == 0 {
+		return nil, errors.New(NO_RECORDS)
+	}
+	return records, nil
+}
+
+// Tx.Commit - applies every queued write atomically
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.closed {
+		return ErrTxClosed
+	}
+	tx.closed = true
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	ops, err := encryptOps(tx.ops)
+	if err != nil {
+		return err
+	}
+	return writeAndNotify(tx.ops, func() error {
+		return getCurrentDB().Commit(ops)
+	})
+}
+
+// Tx.Rollback - discards every queued write
+func (tx *Tx) Rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.closed = true
+	tx.ops = nil
+}
+
+func (tx *Tx) queue(op TxOp) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.closed {
+		return ErrTxClosed
+	}
+	tx.ops = append(tx.ops, op)
+	return nil
+}
@@ -80,6 +80,7 @@ func CreateAccessKey(accesskey models.AccessKey, network models.Network) (models
 	}
 
 	network.AccessKeys = append(network.AccessKeys, accesskey)
+	network.Revision++
 	data, err := json.Marshal(&network)
 	if err != nil {
 		return models.AccessKey{}, err
@@ -112,6 +113,7 @@ func DeleteKey(keyname, netname string) error {
 		return errors.New("key " + keyname + " does not exist")
 	}
 	network.AccessKeys = updatedKeys
+	network.Revision++
 	data, err := json.Marshal(&network)
 	if err != nil {
 		return err
@@ -139,10 +141,15 @@ func GetKeys(net string) ([]models.AccessKey, error) {
 
 // DecrimentKey - decriments key uses
 func DecrimentKey(networkName string, keyvalue string) {
+	decrimentKey(nil, networkName, keyvalue)
+}
+
+// decrimentKey - decriments key uses as part of a transaction
+func decrimentKey(tx *database.Tx, networkName string, keyvalue string) {
 
 	var network models.Network
 
-	network, err := GetParentNetwork(networkName)
+	network, err := getParentNetwork(tx, networkName)
 	if err != nil {
 		return
 	}
@@ -160,11 +167,12 @@ func DecrimentKey(networkName string, keyvalue string) {
 		}
 	}
 
+	network.Revision++
 	if newNetworkData, err := json.Marshal(&network); err != nil {
 		logger.Log(2, "failed to decrement key")
 		return
 	} else {
-		database.Insert(network.NetID, string(newNetworkData), database.NETWORKS_TABLE_NAME)
+		tx.Insert(network.NetID, string(newNetworkData), database.NETWORKS_TABLE_NAME)
 	}
 }
 
@@ -4,8 +4,33 @@ import (
 	"encoding/json"
 
 	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/cache"
 )
 
+// aclCache - caches the ACL containers of every network
+var aclCache = cache.New(database.NODE_ACLS_TABLE_NAME, func(value string) (interface{}, error) {
+	var aclContainer ACLContainer
+	err := json.Unmarshal([]byte(value), &aclContainer)
+	return aclContainer, err
+}, func(record interface{}) interface{} {
+	aclContainer := record.(ACLContainer)
+	if aclContainer == nil {
+		return aclContainer
+	}
+	clone := make(ACLContainer, len(aclContainer))
+	for id, acl := range aclContainer {
+		if acl == nil {
+			clone[id] = nil
+			continue
+		}
+		clone[id] = make(ACL, len(acl))
+		for peer, value := range acl {
+			clone[id][peer] = value
+		}
+	}
+	return clone
+})
+
 // == type functions ==
 
 // ACL.Allow - allows access by ID in memory
@@ -58,24 +83,39 @@ func (networkACL ACLContainer) ChangeAccess(ID1, ID2 AclID, value byte) {
 
 // ACLContainer.Save - saves the state of a ACLContainer to the db
 func (aclContainer ACLContainer) Save(containerID ContainerID) (ACLContainer, error) {
-	return upsertACLContainer(containerID, aclContainer)
+	return upsertACLContainer(nil, containerID, aclContainer)
+}
+
+// ACLContainer.SaveTx - saves the state of a ACLContainer as part of a transaction
+func (aclContainer ACLContainer) SaveTx(tx *database.Tx, containerID ContainerID) (ACLContainer, error) {
+	return upsertACLContainer(tx, containerID, aclContainer)
 }
 
 // ACLContainer.New - saves the state of a ACLContainer to the db
 func (aclContainer ACLContainer) New(containerID ContainerID) (ACLContainer, error) {
-	return upsertACLContainer(containerID, nil)
+	return upsertACLContainer(nil, containerID, nil)
 }
 
 // ACLContainer.Get - saves the state of a ACLContainer to the db
 func (aclContainer ACLContainer) Get(containerID ContainerID) (ACLContainer, error) {
-	return fetchACLContainer(containerID)
+	return fetchACLContainer(nil, containerID)
+}
+
+// ACLContainer.GetTx - fetches the state of a ACLContainer, including writes queued in a transaction
+func (aclContainer ACLContainer) GetTx(tx *database.Tx, containerID ContainerID) (ACLContainer, error) {
+	return fetchACLContainer(tx, containerID)
 }
 
 // == private ==
 
 // fetchACLContainer - fetches all current rules in given ACL container
-func fetchACLContainer(containerID ContainerID) (ACLContainer, error) {
-	aclJson, err := fetchACLContainerJson(ContainerID(containerID))
+func fetchACLContainer(tx *database.Tx, containerID ContainerID) (ACLContainer, error) {
+	if tx == nil {
+		if record, ok := aclCache.Get(string(containerID)); ok {
+			return record.(ACLContainer), nil
+		}
+	}
+	aclJson, err := fetchACLContainerJson(tx, ContainerID(containerID))
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +127,8 @@ func fetchACLContainer(containerID ContainerID) (ACLContainer, error) {
 }
 
 // fetchACLContainerJson - fetch the current ACL of given container except in json string
-func fetchACLContainerJson(containerID ContainerID) (ACLJson, error) {
-	currentACLs, err := database.FetchRecord(database.NODE_ACLS_TABLE_NAME, string(containerID))
+func fetchACLContainerJson(tx *database.Tx, containerID ContainerID) (ACLJson, error) {
+	currentACLs, err := tx.FetchRecord(database.NODE_ACLS_TABLE_NAME, string(containerID))
 	if err != nil {
 		return ACLJson(""), err
 	}
@@ -97,22 +137,22 @@ func fetchACLContainerJson(containerID ContainerID) (ACLJson, error) {
 
 // upsertACL - applies a ACL to the db, overwrites or creates
 func upsertACL(containerID ContainerID, ID AclID, acl ACL) (ACL, error) {
-	currentNetACL, err := fetchACLContainer(containerID)
+	currentNetACL, err := fetchACLContainer(nil, containerID)
 	if err != nil {
 		return acl, err
 	}
 	currentNetACL[ID] = acl
-	_, err = upsertACLContainer(containerID, currentNetACL)
+	_, err = upsertACLContainer(nil, containerID, currentNetACL)
 	return acl, err
 }
 
 // upsertACLContainer - Inserts or updates a network ACL given the json string of the ACL and the container ID
 // if nil, create it
-func upsertACLContainer(containerID ContainerID, aclContainer ACLContainer) (ACLContainer, error) {
+func upsertACLContainer(tx *database.Tx, containerID ContainerID, aclContainer ACLContainer) (ACLContainer, error) {
 	if aclContainer == nil {
 		aclContainer = make(ACLContainer)
 	}
-	return aclContainer, database.Insert(string(containerID), string(convertNetworkACLtoACLJson(aclContainer)), database.NODE_ACLS_TABLE_NAME)
+	return aclContainer, tx.Insert(string(containerID), string(convertNetworkACLtoACLJson(aclContainer)), database.NODE_ACLS_TABLE_NAME)
 }
 
 func convertNetworkACLtoACLJson(networkACL ACLContainer) ACLJson {
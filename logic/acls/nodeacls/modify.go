@@ -7,16 +7,18 @@ import (
 
 // CreateNodeACL - inserts or updates a node ACL on given network and adds to state
 func CreateNodeACL(networkID NetworkID, nodeID NodeID, defaultVal byte) (acls.ACL, error) {
+	return CreateNodeACLTx(nil, networkID, nodeID, defaultVal)
+}
+
+// CreateNodeACLTx - inserts or updates a node ACL on given network as part of a transaction
+func CreateNodeACLTx(tx *database.Tx, networkID NetworkID, nodeID NodeID, defaultVal byte) (acls.ACL, error) {
 	if defaultVal != acls.NotAllowed && defaultVal != acls.Allowed {
 		defaultVal = acls.NotAllowed
 	}
-	var currentNetworkACL, err = FetchAllACLs(networkID)
+	var currentNetworkACL, err = fetchAllACLs(tx, networkID)
 	if err != nil {
 		if database.IsEmptyRecord(err) {
-			currentNetworkACL, err = currentNetworkACL.New(acls.ContainerID(networkID))
-			if err != nil {
-				return nil, err
-			}
+			currentNetworkACL = make(acls.ACLContainer)
 		} else {
 			return nil, err
 		}
@@ -26,8 +28,33 @@ func CreateNodeACL(networkID NetworkID, nodeID NodeID, defaultVal byte) (acls.AC
 		currentNetworkACL[existingNodeID][acls.AclID(nodeID)] = defaultVal // set the old nodes to default value for new node
 		newNodeACL[existingNodeID] = defaultVal                            // set the old nodes in new node ACL to default value
 	}
-	currentNetworkACL[acls.AclID(nodeID)] = newNodeACL                        // append the new node's ACL
-	retNetworkACL, err := currentNetworkACL.Save(acls.ContainerID(networkID)) // insert into db
+	currentNetworkACL[acls.AclID(nodeID)] = newNodeACL                              // append the new node's ACL
+	retNetworkACL, err := currentNetworkACL.SaveTx(tx, acls.ContainerID(networkID)) // insert into db
+	if err != nil {
+		return nil, err
+	}
+	return retNetworkACL[acls.AclID(nodeID)], nil
+}
+
+// RestoreNodeACLTx - recreates a node's ACL from a saved copy as part of a transaction
+// nodes missing from the saved ACL get the default value
+func RestoreNodeACLTx(tx *database.Tx, networkID NetworkID, nodeID NodeID, savedACL acls.ACL, defaultVal byte) (acls.ACL, error) {
+	if _, err := CreateNodeACLTx(tx, networkID, nodeID, defaultVal); err != nil {
+		return nil, err
+	}
+	currentNetworkACL, err := fetchAllACLs(tx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	for peerID, value := range savedACL {
+		if _, ok := currentNetworkACL[peerID]; !ok || peerID == acls.AclID(nodeID) {
+			continue
+		}
+		if value == acls.Allowed || value == acls.NotAllowed {
+			currentNetworkACL.ChangeAccess(acls.AclID(nodeID), peerID, value)
+		}
+	}
+	retNetworkACL, err := currentNetworkACL.SaveTx(tx, acls.ContainerID(networkID))
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +95,12 @@ func UpdateNodeACL(networkID NetworkID, nodeID NodeID, acl acls.ACL) (acls.ACL,
 
 // RemoveNodeACL - removes a specific Node's ACL, returns the NetworkACL and error
 func RemoveNodeACL(networkID NetworkID, nodeID NodeID) (acls.ACLContainer, error) {
-	var currentNetworkACL, err = FetchAllACLs(networkID)
+	return RemoveNodeACLTx(nil, networkID, nodeID)
+}
+
+// RemoveNodeACLTx - removes a specific Node's ACL as part of a transaction
+func RemoveNodeACLTx(tx *database.Tx, networkID NetworkID, nodeID NodeID) (acls.ACLContainer, error) {
+	var currentNetworkACL, err = fetchAllACLs(tx, networkID)
 	if err != nil {
 		return nil, err
 	}
@@ -78,10 +110,15 @@ func RemoveNodeACL(networkID NetworkID, nodeID NodeID) (acls.ACLContainer, error
 		}
 	}
 	delete(currentNetworkACL, acls.AclID(nodeID))
-	return currentNetworkACL.Save(acls.ContainerID(networkID))
+	return currentNetworkACL.SaveTx(tx, acls.ContainerID(networkID))
 }
 
 // DeleteACLContainer - removes an ACLContainer state from db
 func DeleteACLContainer(network NetworkID) error {
-	return database.DeleteRecord(database.NODE_ACLS_TABLE_NAME, string(network))
+	return DeleteACLContainerTx(nil, network)
+}
+
+// DeleteACLContainerTx - removes an ACLContainer state from db as part of a transaction
+func DeleteACLContainerTx(tx *database.Tx, network NetworkID) error {
+	return tx.DeleteRecord(database.NODE_ACLS_TABLE_NAME, string(network))
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logic/acls"
 )
 
@@ -18,7 +19,12 @@ func AreNodesAllowed(networkID NetworkID, node1, node2 NodeID) bool {
 
 // FetchNodeACL - fetches a specific node's ACL in a given network
 func FetchNodeACL(networkID NetworkID, nodeID NodeID) (acls.ACL, error) {
-	var currentNetworkACL, err = FetchAllACLs(networkID)
+	return FetchNodeACLTx(nil, networkID, nodeID)
+}
+
+// FetchNodeACLTx - fetches a specific node's ACL in a given network, including writes queued in a transaction
+func FetchNodeACLTx(tx *database.Tx, networkID NetworkID, nodeID NodeID) (acls.ACL, error) {
+	var currentNetworkACL, err = fetchAllACLs(tx, networkID)
 	if err != nil {
 		return nil, err
 	}
@@ -43,9 +49,14 @@ func FetchNodeACLJson(networkID NetworkID, nodeID NodeID) (acls.ACLJson, error)
 
 // FetchAllACLs - fetchs all node
 func FetchAllACLs(networkID NetworkID) (acls.ACLContainer, error) {
+	return fetchAllACLs(nil, networkID)
+}
+
+// fetchAllACLs - fetches all node ACLs of a network, including writes queued in a transaction
+func fetchAllACLs(tx *database.Tx, networkID NetworkID) (acls.ACLContainer, error) {
 	var err error
 	var currentNetworkACL acls.ACLContainer
-	currentNetworkACL, err = currentNetworkACL.Get(acls.ContainerID(networkID))
+	currentNetworkACL, err = currentNetworkACL.GetTx(tx, acls.ContainerID(networkID))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,108 @@
+package logic
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// IsAddressInNetwork - checks if an IPv4 or IPv6 address is in the primary or a secondary range of a network
+func IsAddressInNetwork(address string, network *models.Network) bool {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return addressRangeOf(address, network.AddressRanges6()) != ""
+	}
+	for _, addressRange := range network.AddressRanges() {
+		if IsAddressInCIDR(address, addressRange) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressRangeOf - the range of a list holding an address, empty when none does
+func addressRangeOf(address string, ranges []string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	for _, addressRange := range ranges {
+		if _, cidr, err := net.ParseCIDR(addressRange); err == nil && cidr.Contains(ip) {
+			return addressRange
+		}
+	}
+	return ""
+}
+
+// rangesOverlap - checks if two CIDRs share any address, invalid CIDRs share none
+func rangesOverlap(a, b string) bool {
+	_, cidrA, errA := net.ParseCIDR(a)
+	_, cidrB, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return cidrA.Contains(cidrB.IP) || cidrB.Contains(cidrA.IP)
+}
+
+// validateAddressRanges - refuses secondary ranges without a primary one, and ranges of a network that overlap each other
+func validateAddressRanges(network *models.Network) error {
+	if (network.AddressRange == "" && len(network.SecondaryRanges) > 0) || (network.AddressRange6 == "" && len(network.SecondaryRanges6) > 0) {
+		return fmt.Errorf("secondary ranges of network %s need a primary range of the same family", network.NetID)
+	}
+	for _, ranges := range [][]string{network.AddressRanges(), network.AddressRanges6()} {
+		for i := range ranges {
+			for j := i + 1; j < len(ranges); j++ {
+				if rangesOverlap(ranges[i], ranges[j]) {
+					return fmt.Errorf("address ranges %s and %s of network %s overlap", ranges[i], ranges[j], network.NetID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRangeUpdate - refuses range changes that leave allocated addresses out of a network, such as shrinking a range
+// below the nodes and ext clients on it, a primary range moved somewhere it does not overlap still re-addresses nodes
+func checkRangeUpdate(current, next *models.Network) error {
+	if sameStrings(current.AddressRanges(), next.AddressRanges()) && sameStrings(current.AddressRanges6(), next.AddressRanges6()) {
+		return nil
+	}
+	moved := current.AddressRange != "" && next.AddressRange != "" && !rangesOverlap(current.AddressRange, next.AddressRange)
+	moved6 := current.AddressRange6 != "" && next.AddressRange6 != "" && !rangesOverlap(current.AddressRange6, next.AddressRange6)
+	check := func(kind, id, address string, ranges []string, primary string, primaryMoved bool) error {
+		if address == "" || len(ranges) == 0 || addressRangeOf(address, ranges) != "" {
+			return nil
+		}
+		if primaryMoved && addressRangeOf(address, []string{primary}) != "" {
+			return nil
+		}
+		return fmt.Errorf("address %s of %s %s is outside the new ranges of network %s, ranges cannot shrink below allocated addresses", address, kind, id, next.NetID)
+	}
+
+	nodes, err := GetNetworkNodes(next.NetID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, node := range nodes {
+		if err = check("node", node.ID, node.Address, next.AddressRanges(), current.AddressRange, moved); err != nil {
+			return err
+		}
+		if err = check("node", node.ID, node.Address6, next.AddressRanges6(), current.AddressRange6, moved6); err != nil {
+			return err
+		}
+	}
+	clients, err := GetNetworkExtClients(next.NetID)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, client := range clients {
+		if err = check("ext client", client.ClientID, client.Address, next.AddressRanges(), current.AddressRange, moved); err != nil {
+			return err
+		}
+		if err = check("ext client", client.ClientID, client.Address6, next.AddressRanges6(), current.AddressRange6, moved6); err != nil {
+			return err
+		}
+	}
+	return nil
+}
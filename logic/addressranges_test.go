@@ -0,0 +1,98 @@
+package logic
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func updateTestRanges(t *testing.T, netid, addressRange string, secondary []string) error {
+	current, err := GetParentNetwork(netid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := current
+	next.AddressRange = addressRange
+	next.SecondaryRanges = secondary
+	rangeupdate, _, _, err := UpdateNetwork(&current, &next)
+	if err == nil && rangeupdate {
+		err = UpdateNetworkNodeAddresses(netid)
+	}
+	return err
+}
+
+func TestAddressRanges(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+
+	if _, err := CreateNetwork(models.Network{NetID: "ranges", AddressRange: "10.94.0.0/30"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []models.Node{
+		{ID: "first", Name: "first", Network: "ranges", Address: "10.94.0.1"},
+		{ID: "second", Name: "second", Network: "ranges", Address: "10.94.0.2"},
+	} {
+		data, _ := json.Marshal(&node)
+		database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+	}
+	nodeAddress := func(id string) string {
+		node, err := GetNodeByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node.Address
+	}
+
+	t.Run("Exhausted", func(t *testing.T) {
+		if address, err := UniqueAddress("ranges", false); err == nil {
+			t.Fatalf("expected a full range to hand out nothing, got %s", address)
+		}
+	})
+	t.Run("Secondary", func(t *testing.T) {
+		if err := updateTestRanges(t, "ranges", "10.94.0.0/30", []string{"10.94.1.0/30"}); err != nil {
+			t.Fatal(err)
+		}
+		if address, err := UniqueAddress("ranges", false); err != nil || address != "10.94.1.1" {
+			t.Fatalf("expected an address of the secondary range, got %s %v", address, err)
+		}
+		network, _ := GetParentNetwork("ranges")
+		if !IsAddressInNetwork("10.94.1.2", &network) || IsAddressInNetwork("10.94.2.1", &network) {
+			t.Fatal("expected addresses to be checked against every range")
+		}
+		if err := updateTestRanges(t, "ranges", "10.94.0.0/30", []string{"10.94.0.0/31"}); err == nil {
+			t.Fatal("expected overlapping ranges to be refused")
+		}
+	})
+	t.Run("Expand", func(t *testing.T) {
+		if err := updateTestRanges(t, "ranges", "10.94.0.0/22", nil); err != nil {
+			t.Fatal(err)
+		}
+		if nodeAddress("first") != "10.94.0.1" || nodeAddress("second") != "10.94.0.2" {
+			t.Fatal("expected addresses that still fit to be kept")
+		}
+	})
+	t.Run("Shrink", func(t *testing.T) {
+		if err := updateTestRanges(t, "ranges", "10.94.0.0/31", nil); err == nil {
+			t.Fatal("expected shrinking below allocated addresses to be refused")
+		}
+		if network, _ := GetParentNetwork("ranges"); network.AddressRange != "10.94.0.0/22" {
+			t.Fatalf("expected a refused shrink to change nothing, got %s", network.AddressRange)
+		}
+	})
+	t.Run("Move", func(t *testing.T) {
+		if err := updateTestRanges(t, "ranges", "10.93.0.0/24", nil); err != nil {
+			t.Fatal(err)
+		}
+		network, _ := GetParentNetwork("ranges")
+		if first := nodeAddress("first"); !IsAddressInNetwork(first, &network) || first == nodeAddress("second") {
+			t.Fatalf("expected nodes of a moved range to be re-addressed, got %s", first)
+		}
+	})
+}
@@ -0,0 +1,374 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// addressPendingTTL - how long an address handed out is held back for the node or ext client that will hold it,
+// so concurrent joins are not handed the same address before either is saved
+var addressPendingTTL = time.Minute
+
+// addresses - the addresses held on every network, shared by everything allocating them
+var addresses = newAddressIndex()
+
+// addressIndex - the addresses held by the nodes and ext clients of every network and those handed out but not yet saved
+// like the cache it is kept in sync by every write made through the database package, and until loaded allocation reads
+// the nodes and ext clients from the database instead
+type addressIndex struct {
+	mu      sync.Mutex
+	loaded  bool
+	holders map[string]indexedHolder
+	used    map[string]map[string]int
+	pending map[string]map[string]time.Time
+	// expiries - per network, when the first of its pending addresses expires, so they are only swept when one has
+	expiries map[string]time.Time
+	// cursors - per network, range and direction, where allocation starts as every address before it is taken
+	cursors map[string]net.IP
+}
+
+// indexedHolder - the network and addresses of a node or ext client record
+type indexedHolder struct {
+	Network  string `json:"network"`
+	Address  string `json:"address"`
+	Address6 string `json:"address6"`
+}
+
+func newAddressIndex() *addressIndex {
+	index := &addressIndex{pending: make(map[string]map[string]time.Time), expiries: make(map[string]time.Time), cursors: make(map[string]net.IP)}
+	database.AddWriteHook(index.apply)
+	return index
+}
+
+// addressIndex.load - indexes the addresses held by every node and ext client
+// the lock is held while reading so writes made meanwhile are applied after the load
+func (index *addressIndex) load() error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.holders = make(map[string]indexedHolder)
+	index.used = make(map[string]map[string]int)
+	index.pending = make(map[string]map[string]time.Time)
+	index.expiries = make(map[string]time.Time)
+	index.cursors = make(map[string]net.IP)
+	for _, table := range []string{database.NODES_TABLE_NAME, database.EXT_CLIENT_TABLE_NAME} {
+		records, err := database.FetchRecords(table)
+		if err != nil && !database.IsEmptyRecord(err) {
+			index.holders, index.used = nil, nil
+			return err
+		}
+		for key, value := range records {
+			index.put(table, key, value)
+		}
+	}
+	index.loaded = true
+	logger.Log(2, "indexed the addresses of", fmt.Sprint(len(index.holders)), "nodes and ext clients")
+	return nil
+}
+
+// addressIndex.unload - drops the index and the addresses held back, allocation reads from the database until loaded again
+func (index *addressIndex) unload() {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.loaded = false
+	index.holders, index.used = nil, nil
+	index.pending = make(map[string]map[string]time.Time)
+	index.expiries = make(map[string]time.Time)
+	index.cursors = make(map[string]net.IP)
+}
+
+// addressIndex.apply - applies committed writes of nodes, ext clients and reservations to the index
+func (index *addressIndex) apply(ops []database.TxOp) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, op := range ops {
+		switch op.Table {
+		case database.NODES_TABLE_NAME, database.EXT_CLIENT_TABLE_NAME:
+		case database.RESERVATIONS_TABLE_NAME:
+			// a removed reservation frees addresses that may be below the cursors of its network
+			if op.Delete {
+				keyParts := strings.Split(op.Key, "###")
+				index.resetCursors(keyParts[len(keyParts)-1])
+			}
+			continue
+		default:
+			continue
+		}
+		if !index.loaded {
+			// the addresses saved are read from the database from now on, so they are no longer held back
+			if holder, ok := readHolder(op.Value); ok && !op.Delete {
+				delete(index.pending[holder.Network], holder.Address)
+				delete(index.pending[holder.Network], holder.Address6)
+			}
+			continue
+		}
+		switch {
+		case op.Delete && op.Key == "":
+			for key := range index.holders {
+				if strings.HasPrefix(key, op.Table+"/") {
+					index.remove(key)
+				}
+			}
+		case op.Delete:
+			index.remove(op.Table + "/" + op.Key)
+		default:
+			index.put(op.Table, op.Key, op.Value)
+		}
+	}
+}
+
+// addressIndex.put - indexes the addresses of a record in place of those it held before
+func (index *addressIndex) put(table, key, value string) {
+	holder, ok := readHolder(value)
+	if !ok {
+		return
+	}
+	index.remove(table + "/" + key)
+	index.holders[table+"/"+key] = holder
+	for _, address := range []string{holder.Address, holder.Address6} {
+		if address == "" {
+			continue
+		}
+		if index.used[holder.Network] == nil {
+			index.used[holder.Network] = make(map[string]int)
+		}
+		index.used[holder.Network][address]++
+		delete(index.pending[holder.Network], address)
+	}
+}
+
+// addressIndex.remove - releases the addresses of a record
+func (index *addressIndex) remove(key string) {
+	holder, ok := index.holders[key]
+	if !ok {
+		return
+	}
+	delete(index.holders, key)
+	for _, address := range []string{holder.Address, holder.Address6} {
+		if address == "" {
+			continue
+		}
+		if index.used[holder.Network][address]--; index.used[holder.Network][address] <= 0 {
+			delete(index.used[holder.Network], address)
+			index.release(holder.Network, address)
+		}
+	}
+}
+
+// addressIndex.release - moves the cursors of a network back to an address that became free
+func (index *addressIndex) release(netid, address string) {
+	ip := net.ParseIP(address)
+	for key, cursor := range index.cursors {
+		if !strings.HasPrefix(key, netid+"|") {
+			continue
+		}
+		freed := toFamily(ip, len(cursor))
+		if freed == nil {
+			continue
+		}
+		if up := strings.HasSuffix(key, "|up"); (up && bytes.Compare(freed, cursor) < 0) || (!up && bytes.Compare(freed, cursor) > 0) {
+			index.cursors[key] = freed
+		}
+	}
+}
+
+// addressIndex.resetCursors - drops the cursors of a network, or of every network when netid is empty
+func (index *addressIndex) resetCursors(netid string) {
+	for key := range index.cursors {
+		if netid == "" || strings.HasPrefix(key, netid+"|") {
+			delete(index.cursors, key)
+		}
+	}
+}
+
+// addressIndex.hold - holds an address back for a node or ext client about to be saved, false when it is taken
+func (index *addressIndex) hold(netid, address string) (bool, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	taken, err := index.taken(netid)
+	if err != nil {
+		return false, err
+	}
+	if address = normalizeAddress(address); taken(address) {
+		return false, nil
+	}
+	index.pend(netid, address)
+	return true, nil
+}
+
+// addressIndex.unhold - stops holding back an address for a node or ext client that will not be saved after all
+func (index *addressIndex) unhold(netid, address string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	address = normalizeAddress(address)
+	if _, ok := index.pending[netid][address]; ok {
+		delete(index.pending[netid], address)
+		index.release(netid, address)
+	}
+}
+
+// addressIndex.allocate - hands out and holds back the first free address of a list of ranges, or the last of each range
+// when reversed, skipping reserved addresses
+func (index *addressIndex) allocate(netid string, ranges []string, reservations []models.IPReservation, reverse, isIpv6 bool) (string, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	taken, err := index.taken(netid)
+	if err != nil {
+		return "", err
+	}
+	for _, addressRange := range ranges {
+		first, last, ok := rangeBounds(addressRange, isIpv6)
+		if !ok {
+			logger.Log(0, "failed to read range", addressRange, "of network", netid)
+			continue
+		}
+		ip, end, step, cursorKey := first, last, stepUp, netid+"|"+addressRange+"|up"
+		if reverse {
+			ip, end, step, cursorKey = last, first, stepDown, netid+"|"+addressRange+"|down"
+		}
+		if cursor, ok := index.cursors[cursorKey]; ok && index.loaded && bytes.Compare(cursor, first) >= 0 && bytes.Compare(cursor, last) <= 0 {
+			ip = append(net.IP{}, cursor...)
+		}
+		for {
+			address := ip.String()
+			if !(len(ip) == net.IPv4len && (ip[3] == 0 || ip[3] == 255)) && !taken(address) && !isAddressReserved(address, reservations) {
+				if index.loaded {
+					index.cursors[cursorKey] = append(net.IP{}, ip...)
+				}
+				index.pend(netid, address)
+				return address, nil
+			}
+			if bytes.Equal(ip, end) || !step(ip) {
+				break
+			}
+		}
+	}
+	return "", errors.New("no unique addresses available")
+}
+
+// addressIndex.taken - tells if an address of a network is held or pending, reading the database when not loaded
+func (index *addressIndex) taken(netid string) (func(address string) bool, error) {
+	if now := time.Now(); len(index.pending[netid]) > 0 && !now.Before(index.expiries[netid]) {
+		var next time.Time
+		for address, expiry := range index.pending[netid] {
+			if !now.Before(expiry) {
+				delete(index.pending[netid], address)
+				index.release(netid, address)
+			} else if next.IsZero() || expiry.Before(next) {
+				next = expiry
+			}
+		}
+		index.expiries[netid] = next
+	}
+	pending := index.pending[netid]
+	if index.loaded {
+		used := index.used[netid]
+		return func(address string) bool {
+			_, isPending := pending[address]
+			return used[address] > 0 || isPending
+		}, nil
+	}
+	used := make(map[string]bool)
+	for _, table := range []string{database.NODES_TABLE_NAME, database.EXT_CLIENT_TABLE_NAME} {
+		records, err := database.FetchRecords(table)
+		if err != nil && !database.IsEmptyRecord(err) {
+			return nil, err
+		}
+		for _, value := range records {
+			if holder, ok := readHolder(value); ok && holder.Network == netid {
+				used[holder.Address] = true
+				used[holder.Address6] = true
+			}
+		}
+	}
+	return func(address string) bool {
+		_, isPending := pending[address]
+		return used[address] || isPending
+	}, nil
+}
+
+func (index *addressIndex) pend(netid, address string) {
+	if index.pending[netid] == nil {
+		index.pending[netid] = make(map[string]time.Time)
+	}
+	expiry := time.Now().Add(addressPendingTTL)
+	index.pending[netid][address] = expiry
+	if next, ok := index.expiries[netid]; !ok || next.IsZero() || expiry.Before(next) {
+		index.expiries[netid] = expiry
+	}
+}
+
+// readHolder - reads the network and addresses of a node or ext client record
+func readHolder(value string) (indexedHolder, bool) {
+	var holder indexedHolder
+	if err := json.Unmarshal([]byte(value), &holder); err != nil {
+		return holder, false
+	}
+	holder.Address, holder.Address6 = normalizeAddress(holder.Address), normalizeAddress(holder.Address6)
+	return holder, true
+}
+
+// rangeBounds - the first and last address of a range that may be handed out, leaving out the network and broadcast
+// addresses of IPv4 ranges and the first address of IPv6 ones
+func rangeBounds(addressRange string, isIpv6 bool) (net.IP, net.IP, bool) {
+	_, cidr, err := net.ParseCIDR(addressRange)
+	if err != nil || (cidr.IP.To4() == nil) != isIpv6 {
+		return nil, nil, false
+	}
+	first := append(net.IP{}, cidr.IP...)
+	last := append(net.IP{}, cidr.IP...)
+	for i := range last {
+		last[i] |= ^cidr.Mask[i]
+	}
+	ones, bits := cidr.Mask.Size()
+	if (!isIpv6 && ones < 31) || (isIpv6 && ones < bits) {
+		stepUp(first)
+	}
+	if !isIpv6 && ones < 31 {
+		stepDown(last)
+	}
+	return first, last, true
+}
+
+// stepUp - increments an address in place, false when it overflows
+func stepUp(ip net.IP) bool {
+	for i := len(ip) - 1; i >= 0; i-- {
+		if ip[i]++; ip[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stepDown - decrements an address in place, false when it underflows
+func stepDown(ip net.IP) bool {
+	for i := len(ip) - 1; i >= 0; i-- {
+		if ip[i]--; ip[i] != 255 {
+			return true
+		}
+	}
+	return false
+}
+
+func toFamily(ip net.IP, length int) net.IP {
+	if length == net.IPv4len {
+		return ip.To4()
+	}
+	return ip.To16()
+}
+
+func normalizeAddress(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	return address
+}
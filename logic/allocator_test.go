@@ -0,0 +1,177 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func insertAllocatorNode(t testing.TB, id, netid, address, address6 string) {
+	node := models.Node{ID: id, Name: id, Network: netid, Address: address, Address6: address6}
+	data, _ := json.Marshal(&node)
+	if err := database.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// releasePending - frees the addresses handed out by a benchmark, so runs do not exhaust the range
+func releasePending() {
+	addresses.mu.Lock()
+	defer addresses.mu.Unlock()
+	addresses.pending = make(map[string]map[string]time.Time)
+	addresses.resetCursors("")
+}
+
+func TestAddressIndex(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	if _, err := CreateNetwork(models.Network{NetID: "alloc", AddressRange: "10.91.0.0/24", AddressRange6: "fd00:91::/120", IsIPv6: "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addresses.load(); err != nil {
+		t.Fatal(err)
+	}
+	defer addresses.unload()
+
+	t.Run("Release", func(t *testing.T) {
+		for _, id := range []string{"first", "second"} {
+			address, err := UniqueAddress("alloc", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			insertAllocatorNode(t, id, "alloc", address, "")
+		}
+		if first, _ := GetNodeByID("first"); first.Address != "10.91.0.1" {
+			t.Fatalf("expected the first address of the range, got %s", first.Address)
+		}
+		if err := database.DeleteRecord(database.NODES_TABLE_NAME, "first"); err != nil {
+			t.Fatal(err)
+		}
+		if address, err := UniqueAddress("alloc", false); err != nil || address != "10.91.0.1" {
+			t.Fatalf("expected the address of a removed node to be handed out again, got %s %v", address, err)
+		}
+	})
+	t.Run("Pending", func(t *testing.T) {
+		held, _ := UniqueAddress("alloc", false)
+		if again, _ := UniqueAddress("alloc", false); again == held {
+			t.Fatalf("expected an address handed out to be held back, got %s twice", held)
+		}
+		addressPendingTTL = 0
+		defer func() { addressPendingTTL = time.Minute }()
+		expiring, _ := UniqueAddress("alloc", false)
+		if again, _ := UniqueAddress("alloc", false); again != expiring {
+			t.Fatalf("expected an address never saved to be handed out again once expired, got %s then %s", expiring, again)
+		}
+	})
+	t.Run("Unhold", func(t *testing.T) {
+		if held, err := addresses.hold("alloc", "10.91.0.200"); err != nil || !held {
+			t.Fatalf("expected a free address to be held, got %v %v", held, err)
+		}
+		if held, _ := addresses.hold("alloc", "10.91.0.200"); held {
+			t.Fatal("expected a held address not to be held twice")
+		}
+		addresses.unhold("alloc", "10.91.0.200")
+		if held, _ := addresses.hold("alloc", "10.91.0.200"); !held {
+			t.Fatal("expected an address no longer held to be free")
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		handedOut := make(map[string]bool)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				address, err := UniqueAddress6("alloc", false)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil || handedOut[address] {
+					t.Errorf("expected a distinct address for every join, got %s %v", address, err)
+				}
+				handedOut[address] = true
+			}()
+		}
+		wg.Wait()
+	})
+	t.Run("Database", func(t *testing.T) {
+		addresses.unload()
+		defer addresses.load()
+		insertAllocatorNode(t, "third", "alloc", "10.91.0.1", "")
+		address, err := UniqueAddress("alloc", false)
+		if err != nil || address == "10.91.0.1" || address == "10.91.0.2" {
+			t.Fatalf("expected addresses held in the database to be skipped, got %s %v", address, err)
+		}
+	})
+}
+
+func BenchmarkUniqueAddress(b *testing.B) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		b.Fatal(err)
+	}
+	defer database.CloseDB()
+	if _, err := CreateNetwork(models.Network{NetID: "bench", AddressRange: "10.90.0.0/16", AddressRange6: "fd00:90::/64", IsIPv6: "yes"}); err != nil {
+		b.Fatal(err)
+	}
+	if err := addresses.load(); err != nil {
+		b.Fatal(err)
+	}
+	defer addresses.unload()
+	for i := 0; i < 10000; i++ {
+		address, err := UniqueAddress("bench", false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		address6, err := UniqueAddress6("bench", false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		insertAllocatorNode(b, fmt.Sprintf("node-%d", i), "bench", address, address6)
+	}
+
+	b.Run("Indexed", func(b *testing.B) {
+		releasePending()
+		for i := 0; i < b.N; i++ {
+			if _, err := UniqueAddress("bench", false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Indexed6", func(b *testing.B) {
+		releasePending()
+		for i := 0; i < b.N; i++ {
+			if _, err := UniqueAddress6("bench", false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("IndexedServer", func(b *testing.B) {
+		releasePending()
+		for i := 0; i < b.N; i++ {
+			if _, err := UniqueAddress("bench", true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Database", func(b *testing.B) {
+		addresses.unload()
+		defer addresses.load()
+		for i := 0; i < b.N; i++ {
+			if _, err := UniqueAddress("bench", false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// API_TOKEN_PREFIX - starts every API token, so they are told apart from JWTs and the master key
+const API_TOKEN_PREFIX = "nmt."
+
+// API_TOKEN_LAST_USED_INTERVAL - how often the last use of a token is saved
+const API_TOKEN_LAST_USED_INTERVAL = time.Minute
+
+// apiTokenMutex - keeps recording the use of a token from bringing back a token revoked meanwhile
+var apiTokenMutex sync.Mutex
+
+var (
+	// ErrInvalidAPIToken - returned for unknown, revoked or expired API tokens
+	ErrInvalidAPIToken = errors.New("invalid API token")
+	// ErrAPITokenScope - returned when an API token is used outside of its scope
+	ErrAPITokenScope = errors.New("API token scope does not allow this request")
+)
+
+// IsAPIToken - checks if a bearer token is an API token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, API_TOKEN_PREFIX)
+}
+
+// CreateAPIToken - creates an API token for a user, returning its secret which is not stored
+func CreateAPIToken(username string, token models.APIToken) (models.CreatedAPIToken, error) {
+	user, err := GetUser(username)
+	if err != nil {
+		return models.CreatedAPIToken{}, err
+	}
+	if err = validator.New().Struct(token); err != nil {
+		return models.CreatedAPIToken{}, err
+	}
+	now := time.Now().Unix()
+	if token.Expiration != 0 && token.Expiration <= now {
+		return models.CreatedAPIToken{}, errors.New("expiration must be in the future")
+	}
+	for _, network := range token.Networks {
+		if !user.IsAdmin && !StringSliceContains(user.Networks, network) {
+			return models.CreatedAPIToken{}, fmt.Errorf("user %s has no access to network %s", username, network)
+		}
+	}
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return models.CreatedAPIToken{}, err
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return models.CreatedAPIToken{}, err
+	}
+	token.ID = hex.EncodeToString(id)
+	token.UserName = user.UserName
+	token.Created = now
+	token.LastUsed = 0
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	token.Hash = hashAPITokenSecret(encodedSecret)
+	if err = saveAPIToken(&token); err != nil {
+		return models.CreatedAPIToken{}, err
+	}
+	token.Hash = ""
+	return models.CreatedAPIToken{APIToken: token, Token: API_TOKEN_PREFIX + token.ID + "." + encodedSecret}, nil
+}
+
+// GetAPITokens - gets the tokens of a user without their hashes, oldest first
+func GetAPITokens(username string) ([]models.APIToken, error) {
+	records, err := database.FetchRecords(database.API_TOKENS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	var tokens = []models.APIToken{}
+	for _, record := range records {
+		var token models.APIToken
+		if err := json.Unmarshal([]byte(record), &token); err != nil || token.UserName != username {
+			continue
+		}
+		token.Hash = ""
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].Created != tokens[j].Created {
+			return tokens[i].Created < tokens[j].Created
+		}
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// DeleteAPIToken - revokes a token of a user
+func DeleteAPIToken(username string, id string) error {
+	apiTokenMutex.Lock()
+	defer apiTokenMutex.Unlock()
+	token, err := getAPIToken(id)
+	if err != nil || token.UserName != username {
+		return errors.New("API token " + id + " does not exist")
+	}
+	return database.DeleteRecord(database.API_TOKENS_TABLE_NAME, id)
+}
+
+// DeleteUserAPITokens - revokes every token of a user, used when the user is deleted
+func DeleteUserAPITokens(username string) error {
+	apiTokenMutex.Lock()
+	defer apiTokenMutex.Unlock()
+	tokens, err := GetAPITokens(username)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err = database.DeleteRecord(database.API_TOKENS_TABLE_NAME, token.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyAPIToken - gets the token a bearer token refers to if it is valid and unexpired,
+// along with the networks it may act on and whether it acts as an admin
+func VerifyAPIToken(tokenString string) (token models.APIToken, networks []string, isadmin bool, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(tokenString, API_TOKEN_PREFIX), ".", 2)
+	if !IsAPIToken(tokenString) || len(parts) != 2 {
+		return token, nil, false, ErrInvalidAPIToken
+	}
+	if token, err = getAPIToken(parts[0]); err != nil {
+		return token, nil, false, ErrInvalidAPIToken
+	}
+	if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashAPITokenSecret(parts[1]))) != 1 {
+		return token, nil, false, ErrInvalidAPIToken
+	}
+	now := time.Now()
+	if token.Expiration != 0 && token.Expiration <= now.Unix() {
+		return token, nil, false, ErrInvalidAPIToken
+	}
+	user, err := GetUser(token.UserName)
+	if err != nil || user.UserName == "" {
+		return token, nil, false, ErrInvalidAPIToken
+	}
+	// the user is read on every request, so access removed from the user is removed from its tokens
+	switch {
+	case len(token.Networks) == 0:
+		isadmin, networks = user.IsAdmin, user.Networks
+	case user.IsAdmin:
+		networks = token.Networks
+	default:
+		for _, network := range token.Networks {
+			if StringSliceContains(user.Networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	if now.Unix()-token.LastUsed >= int64(API_TOKEN_LAST_USED_INTERVAL.Seconds()) {
+		token.LastUsed = now.Unix()
+		touchAPIToken(token.ID, token.LastUsed)
+	}
+	token.Hash = ""
+	return token, networks, isadmin, nil
+}
+
+// apiTokenReadRoutes - the routes read and node tokens may read, by method and path template, reads returning
+// secrets such as backups, metrics, the audit log, lockouts, access keys, ext client keys and the server config are left out
+var apiTokenReadRoutes = map[string]bool{
+	"GET /api/users/{username}":                    true,
+	"GET /api/users/{username}/tokens":             true,
+	"GET /api/users":                               true,
+	"GET /api/events":                              true,
+	"GET /api/dns":                                 true,
+	"GET /api/networks":                            true,
+	"GET /api/networks/{networkname}":              true,
+	"GET /api/networks/{networkname}/acls":         true,
+	"GET /api/networks/{networkname}/ipam":         true,
+	"GET /api/networks/{networkname}/reservations": true,
+	"GET /api/nodes":                               true,
+	"GET /api/nodes/{network}":                     true,
+	"GET /api/nodes/{network}/{nodeid}":            true,
+	"GET /api/nodes/adm/{network}/lastmodified":    true,
+	"GET /api/deletednodes":                        true,
+	"GET /api/dns/adm/{network}/nodes":             true,
+	"GET /api/dns/adm/{network}/custom":            true,
+	"GET /api/dns/adm/{network}":                   true,
+	"GET /api/server/conflicts":                    true,
+}
+
+// CheckAPITokenScope - checks the scope of an API token allows a request to a route, given by its path template,
+// read tokens may only make the reads in apiTokenReadRoutes and node tokens may also change nodes
+func CheckAPITokenScope(token models.APIToken, method string, route string) error {
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	switch {
+	case token.Scope == models.API_TOKEN_SCOPE_ADMIN:
+		return nil
+	case apiTokenReadRoutes[method+" "+route]:
+		return nil
+	case token.Scope == models.API_TOKEN_SCOPE_NODES && method != http.MethodGet && strings.HasPrefix(route, "/api/nodes/"):
+		return nil
+	}
+	return ErrAPITokenScope
+}
+
+// touchAPIToken - saves the last use of a token unless it was revoked since it was read
+func touchAPIToken(id string, lastUsed int64) {
+	apiTokenMutex.Lock()
+	defer apiTokenMutex.Unlock()
+	token, err := getAPIToken(id)
+	if err != nil {
+		return
+	}
+	token.LastUsed = lastUsed
+	if err = saveAPIToken(&token); err != nil {
+		logger.Log(1, "could not record use of API token", id, err.Error())
+	}
+}
+
+func getAPIToken(id string) (models.APIToken, error) {
+	var token models.APIToken
+	record, err := database.FetchRecord(database.API_TOKENS_TABLE_NAME, id)
+	if err != nil {
+		return token, err
+	}
+	err = json.Unmarshal([]byte(record), &token)
+	return token, err
+}
+
+func saveAPIToken(token *models.APIToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return database.Insert(token.ID, string(data), database.API_TOKENS_TABLE_NAME)
+}
+
+// hashAPITokenSecret - secrets are random 256 bit values, so a single sha256 is enough to store them
+func hashAPITokenSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,109 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func insertUser(t *testing.T, user models.User) {
+	data, err := json.Marshal(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	insertUser(t, models.User{UserName: "automation", Password: "hash", Networks: []string{"net1", "net2"}})
+
+	if _, err := CreateAPIToken("automation", models.APIToken{Name: "ci", Scope: models.API_TOKEN_SCOPE_NODES, Networks: []string{"net3"}}); err == nil {
+		t.Fatal("expected a token for a network the user cannot access to be refused")
+	}
+	if _, err := CreateAPIToken("automation", models.APIToken{Name: "ci", Scope: "root"}); err == nil {
+		t.Fatal("expected an unknown scope to be refused")
+	}
+	created, err := CreateAPIToken("automation", models.APIToken{Name: "ci", Scope: models.API_TOKEN_SCOPE_NODES, Networks: []string{"net1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := getAPIToken(created.ID)
+	if err != nil || stored.Hash == "" || stored.Hash == created.Token {
+		t.Fatalf("expected only a hash of the token to be stored, got %+v %v", stored, err)
+	}
+
+	t.Run("Verify", func(t *testing.T) {
+		username, networks, isadmin, err := VerifyUserToken(created.Token)
+		if err != nil || username != "automation" || isadmin || len(networks) != 1 || networks[0] != "net1" {
+			t.Fatalf("expected the token to act as automation on net1, got %s %v %v %v", username, networks, isadmin, err)
+		}
+		if _, _, _, err = VerifyUserToken(created.Token + "x"); err == nil {
+			t.Fatal("expected a wrong secret to be refused")
+		}
+	})
+	t.Run("Scope", func(t *testing.T) {
+		token, _, _, _ := VerifyAPIToken(created.Token)
+		if err := CheckAPITokenScope(token, http.MethodPost, "/api/nodes/{network}/{nodeid}/approve"); err != nil {
+			t.Fatalf("expected a node token to create nodes, got %v", err)
+		}
+		if err := CheckAPITokenScope(token, http.MethodDelete, "/api/networks/{networkname}"); !errors.Is(err, ErrAPITokenScope) {
+			t.Fatalf("expected a node token not to delete networks, got %v", err)
+		}
+		token.Scope = models.API_TOKEN_SCOPE_READ
+		if err := CheckAPITokenScope(token, http.MethodGet, "/api/networks"); err != nil {
+			t.Fatalf("expected a read token to read, got %v", err)
+		}
+		if err := CheckAPITokenScope(token, http.MethodPut, "/api/nodes/{network}/{nodeid}"); !errors.Is(err, ErrAPITokenScope) {
+			t.Fatalf("expected a read token not to update nodes, got %v", err)
+		}
+		for _, route := range []string{"/api/server/backup", "/metrics", "/api/audit", "/api/users/adm/lockouts", "/api/networks/{networkname}/keys", "/api/extclients/{network}/{clientid}/{type}"} {
+			if err := CheckAPITokenScope(token, http.MethodGet, route); !errors.Is(err, ErrAPITokenScope) {
+				t.Fatalf("expected a read token not to read %s, got %v", route, err)
+			}
+		}
+		token.Scope = models.API_TOKEN_SCOPE_ADMIN
+		if err := CheckAPITokenScope(token, http.MethodGet, "/api/server/backup"); err != nil {
+			t.Fatalf("expected an admin token to read backups, got %v", err)
+		}
+	})
+	t.Run("Expiry", func(t *testing.T) {
+		expiring, err := CreateAPIToken("automation", models.APIToken{Name: "short", Scope: models.API_TOKEN_SCOPE_READ, Expiration: time.Now().Unix() + 60})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := getAPIToken(expiring.ID)
+		stored.Expiration = time.Now().Unix() - 1
+		saveAPIToken(&stored)
+		if _, _, _, err = VerifyAPIToken(expiring.Token); !errors.Is(err, ErrInvalidAPIToken) {
+			t.Fatalf("expected an expired token to be refused, got %v", err)
+		}
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		if err := DeleteAPIToken("someoneelse", created.ID); err == nil {
+			t.Fatal("expected the token of another user not to be revoked")
+		}
+		if err := DeleteAPIToken("automation", created.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := VerifyUserToken(created.Token); err == nil {
+			t.Fatal("expected a revoked token to be refused")
+		}
+		if tokens, _ := GetAPITokens("automation"); len(tokens) != 1 || tokens[0].Hash != "" {
+			t.Fatalf("expected the expired token without its hash to remain, got %+v", tokens)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// AUDIT_REDACTED - placeholder recorded in place of secret values
+const AUDIT_REDACTED = "(redacted)"
+
+// auditSecretFields - fields whose values are never written to the audit log, at any depth
+var auditSecretFields = map[string]bool{
+	"password":     true,
+	"privatekey":   true,
+	"accessstring": true,
+	"value":        true,
+	"accesskey":    true,
+}
+
+// AuditQuery - filters, ordering and pagination of the audit log, From and To bound the timestamp when set
+type AuditQuery struct {
+	ListOptions
+	From       time.Time
+	To         time.Time
+	Actor      string
+	ActorType  string
+	Action     string
+	TargetType string
+	Target     string
+}
+
+var auditSortKeys = map[string]func(*models.AuditEntry) sortValue{
+	"timestamp": func(e *models.AuditEntry) sortValue { return sortValue{Num: e.Timestamp} },
+	"actor":     func(e *models.AuditEntry) sortValue { return sortValue{Str: e.Actor} },
+	"action":    func(e *models.AuditEntry) sortValue { return sortValue{Str: e.Action} },
+	"target":    func(e *models.AuditEntry) sortValue { return sortValue{Str: e.Target} },
+}
+
+// Audit - records a change in the audit log, before is nil for creates and after is nil for deletes
+// failing to record is logged rather than returned so the change itself is not undone
+func Audit(entry models.AuditEntry, before, after interface{}) {
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		logger.Log(0, "failed to diff audit entry for", entry.TargetType, entry.Target, err.Error())
+	}
+	now := time.Now()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	entry.ID = fmt.Sprintf("%019d-%s", now.UnixNano(), hex.EncodeToString(suffix))
+	entry.Timestamp = now.Unix()
+	entry.Changes = changes
+	data, err := json.Marshal(&entry)
+	if err == nil {
+		err = database.Insert(entry.ID, string(data), database.AUDIT_TABLE_NAME)
+	}
+	if err != nil {
+		logger.Log(0, "failed to record audit entry", entry.Action, entry.TargetType, entry.Target, "by", entry.Actor, err.Error())
+		return
+	}
+	logger.Log(2, "audit:", entry.Actor, entry.Action, entry.TargetType, entry.Target)
+}
+
+// GetAuditEntries - fetches the audit log matching a query, newest first unless another sort key is given
+func GetAuditEntries(query AuditQuery) ([]models.AuditEntry, PageInfo, error) {
+	if query.Sort == "" {
+		query.Sort = "timestamp"
+		query.Desc = true
+	}
+	sortKey, ok := auditSortKeys[query.Sort]
+	if !ok {
+		return nil, PageInfo{}, fmt.Errorf("%w: unknown audit sort key %s", ErrInvalidQuery, query.Sort)
+	}
+	records, err := database.FetchRecords(database.AUDIT_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, PageInfo{}, err
+	}
+	var entries []models.AuditEntry
+	var items []queryItem
+	for key, value := range records {
+		var entry models.AuditEntry
+		if err = json.Unmarshal([]byte(value), &entry); err != nil {
+			logger.Log(2, "skipping unreadable audit entry", key)
+			continue
+		}
+		if !auditEntryMatches(&entry, &query) {
+			continue
+		}
+		items = append(items, queryItem{index: len(entries), value: sortKey(&entry), id: entry.ID})
+		entries = append(entries, entry)
+	}
+	items, page, err := paginate(items, query.ListOptions)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	var result = make([]models.AuditEntry, 0, len(items))
+	for _, item := range items {
+		result = append(result, entries[item.index])
+	}
+	return result, page, nil
+}
+
+func auditEntryMatches(entry *models.AuditEntry, query *AuditQuery) bool {
+	if !query.From.IsZero() && entry.Timestamp < query.From.Unix() {
+		return false
+	}
+	if !query.To.IsZero() && entry.Timestamp > query.To.Unix() {
+		return false
+	}
+	if query.Actor != "" && entry.Actor != query.Actor {
+		return false
+	}
+	if query.ActorType != "" && entry.ActorType != query.ActorType {
+		return false
+	}
+	if query.Action != "" && entry.Action != query.Action {
+		return false
+	}
+	if query.TargetType != "" && entry.TargetType != query.TargetType {
+		return false
+	}
+	if query.Target != "" && entry.Target != query.Target {
+		return false
+	}
+	return true
+}
+
+// auditChanges - diffs the top level fields of two records, redacting secrets
+func auditChanges(before, after interface{}) ([]models.AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	var changes []models.AuditChange
+	for _, field := range fields {
+		beforeValue, afterValue := beforeFields[field], afterFields[field]
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		changes = append(changes, models.AuditChange{
+			Field:  field,
+			Before: redactAuditField(field, beforeValue),
+			After:  redactAuditField(field, afterValue),
+		})
+	}
+	return changes, nil
+}
+
+// auditFields - decodes a record into its json fields, a record that is not an object is kept under "record"
+func auditFields(record interface{}) (map[string]interface{}, error) {
+	if record == nil || (reflect.ValueOf(record).Kind() == reflect.Ptr && reflect.ValueOf(record).IsNil()) {
+		return map[string]interface{}{}, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	fields, ok := decoded.(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{"record": decoded}, nil
+	}
+	return fields, nil
+}
+
+// redactAuditField - redacts a field entirely if it is a secret, otherwise the secrets nested in it
+// secrets are diffed before redaction so changing one is still recorded
+func redactAuditField(field string, value interface{}) interface{} {
+	if auditSecretFields[strings.ToLower(field)] {
+		if value == nil || value == "" {
+			return value
+		}
+		return AUDIT_REDACTED
+	}
+	return redactAuditValue(value)
+}
+
+// redactAuditValue - replaces the values of secret fields nested at any depth with AUDIT_REDACTED
+func redactAuditValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			typed[key] = redactAuditField(key, field)
+		}
+	case []interface{}:
+		for i := range typed {
+			typed[i] = redactAuditValue(typed[i])
+		}
+	}
+	return value
+}
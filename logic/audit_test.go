@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestAudit(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+
+	before := models.User{UserName: "alice", Password: "hash1", Networks: []string{"a"}}
+	after := models.User{UserName: "alice", Password: "hash2", Networks: []string{"a", "b"}}
+	Audit(models.AuditEntry{ActorType: models.AUDIT_ACTOR_USER, Actor: "admin", Action: models.AUDIT_UPDATE, TargetType: "user", Target: "alice"}, &before, &after)
+	Audit(models.AuditEntry{ActorType: models.AUDIT_ACTOR_NODE, Actor: "node1", Action: models.AUDIT_DELETE, TargetType: "node", Target: "node1"}, &models.Node{ID: "node1"}, nil)
+
+	t.Run("Diff", func(t *testing.T) {
+		entries, _, err := GetAuditEntries(AuditQuery{Actor: "admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("expected one entry by admin, got %d", len(entries))
+		}
+		changes := map[string]models.AuditChange{}
+		for _, change := range entries[0].Changes {
+			changes[change.Field] = change
+		}
+		if len(changes) != 2 {
+			t.Fatalf("expected password and networks to change, got %+v", entries[0].Changes)
+		}
+		if changes["password"].Before != AUDIT_REDACTED || changes["password"].After != AUDIT_REDACTED {
+			t.Fatalf("expected password to be redacted, got %+v", changes["password"])
+		}
+		if _, ok := changes["networks"]; !ok {
+			t.Fatal("expected networks change to be recorded")
+		}
+	})
+	t.Run("Filters", func(t *testing.T) {
+		entries, page, err := GetAuditEntries(AuditQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || page.Total != 2 {
+			t.Fatalf("expected 2 entries, got %d", len(entries))
+		}
+		if entries[0].Actor != "node1" {
+			t.Fatalf("expected newest entry first, got %s", entries[0].Actor)
+		}
+		entries, _, _ = GetAuditEntries(AuditQuery{ActorType: models.AUDIT_ACTOR_NODE, Action: models.AUDIT_DELETE})
+		if len(entries) != 1 || entries[0].Target != "node1" {
+			t.Fatalf("expected the node deletion, got %+v", entries)
+		}
+		entries, _, _ = GetAuditEntries(AuditQuery{To: time.Now().Add(-time.Hour)})
+		if len(entries) != 0 {
+			t.Fatalf("expected no entries older than an hour, got %d", len(entries))
+		}
+	})
+}
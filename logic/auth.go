@@ -85,6 +85,11 @@ func CreateUser(user models.User) (models.User, error) {
 	if err != nil {
 		return models.User{}, err
 	}
+	var stored = storedUser{User: user}
+	if !user.IsAdmin {
+		stored.setNetworks(user.Networks)
+		user.Networks = stored.Networks
+	}
 
 	// encrypt that password so we never see it again
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 5)
@@ -102,11 +107,8 @@ func CreateUser(user models.User) (models.User, error) {
 	}
 
 	// connect db
-	data, err := json.Marshal(&user)
-	if err != nil {
-		return user, err
-	}
-	err = database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME)
+	stored.User = user
+	err = stored.save()
 
 	return user, err
 }
@@ -156,25 +158,24 @@ func VerifyAuthRequest(authRequest models.UserAuthParams) (string, error) {
 // UpdateUserNetworks - updates the networks of a given user
 func UpdateUserNetworks(newNetworks []string, isadmin bool, currentUser *models.User) error {
 	// check if user exists
-	if returnedUser, err := GetUser(currentUser.UserName); err != nil {
+	stored, err := getStoredUser(currentUser.UserName)
+	if err != nil {
 		return err
-	} else if returnedUser.IsAdmin {
-		return fmt.Errorf("can not make changes to an admin user, attempted to change %s", returnedUser.UserName)
+	} else if stored.IsAdmin {
+		return fmt.Errorf("can not make changes to an admin user, attempted to change %s", stored.UserName)
 	}
+	stored.User = *currentUser
 	if isadmin {
-		currentUser.IsAdmin = true
-		currentUser.Networks = nil
+		stored.IsAdmin = true
+		stored.Networks = nil
+		stored.NetworkRoles = nil
 	} else {
-		currentUser.Networks = newNetworks
-	}
-
-	data, err := json.Marshal(currentUser)
-	if err != nil {
-		return err
+		stored.setNetworks(newNetworks)
 	}
-	if err = database.Insert(currentUser.UserName, string(data), database.USERS_TABLE_NAME); err != nil {
+	if err = stored.save(); err != nil {
 		return err
 	}
+	*currentUser = stored.User
 
 	return nil
 }
@@ -182,11 +183,12 @@ func UpdateUserNetworks(newNetworks []string, isadmin bool, currentUser *models.
 // UpdateUser - updates a given user
 func UpdateUser(userchange models.User, user models.User) (models.User, error) {
 	//check if user exists
-	if _, err := GetUser(user.UserName); err != nil {
+	stored, err := getStoredUser(user.UserName)
+	if err != nil {
 		return models.User{}, err
 	}
 
-	err := ValidateUser(userchange)
+	err = ValidateUser(userchange)
 	if err != nil {
 		return models.User{}, err
 	}
@@ -197,7 +199,8 @@ func UpdateUser(userchange models.User, user models.User) (models.User, error) {
 		user.UserName = userchange.UserName
 	}
 	if len(userchange.Networks) > 0 {
-		user.Networks = userchange.Networks
+		stored.setNetworks(userchange.Networks)
+		user.Networks = stored.Networks
 	}
 	if userchange.Password != "" {
 		// encrypt that password so we never see it again
@@ -214,11 +217,8 @@ func UpdateUser(userchange models.User, user models.User) (models.User, error) {
 	if err = database.DeleteRecord(database.USERS_TABLE_NAME, queryUser); err != nil {
 		return models.User{}, err
 	}
-	data, err := json.Marshal(&user)
-	if err != nil {
-		return models.User{}, err
-	}
-	if err = database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME); err != nil {
+	stored.User = user
+	if err = stored.save(); err != nil {
 		return models.User{}, err
 	}
 	logger.Log(1, "updated user", queryUser)
@@ -251,6 +251,9 @@ func DeleteUser(user string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if err = DeleteUserAPITokens(user); err != nil {
+		logger.Log(0, "failed to revoke API tokens of deleted user", user, err.Error())
+	}
 	return true, nil
 }
 
@@ -0,0 +1,277 @@
+package logic
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// BACKUP_FORMAT_VERSION - version of the backup archive layout,
+// version 2 keeps the records of secret tables sealed with the database encryption key
+const BACKUP_FORMAT_VERSION = 2
+
+const (
+	backupManifestFile = "manifest.json"
+	backupTablesDir    = "tables/"
+	backupCADir        = "ca/"
+)
+
+// backupCAFiles - root CA files carried in a backup
+var backupCAFiles = []string{"root.pem", "root.key"}
+
+// limits on what a restore reads, so a corrupt or crafted archive cannot exhaust the server's memory
+var (
+	backupMaxEntrySize int64 = 256 << 20
+	backupMaxSize      int64 = 1 << 30
+)
+
+// BackupManifest - describes the contents of a backup archive
+type BackupManifest struct {
+	FormatVersion int      `json:"formatversion"`
+	SchemaVersion int      `json:"schemaversion"`
+	ServerVersion string   `json:"serverversion"`
+	Database      string   `json:"database"`
+	CreatedAt     int64    `json:"createdat"`
+	Tables        []string `json:"tables"`
+	Encrypted     bool     `json:"encrypted"`
+}
+
+// WriteBackup - writes a gzipped tar archive of every table and the root CA found in caDir
+// when a database encryption key is configured the ext client, server config and server UUID records
+// stay sealed with it, so restoring needs the same key, every other record and the root CA key are
+// written in plaintext and the archive must be stored as securely as the server's data directory
+func WriteBackup(w io.Writer, caDir string) error {
+	records, err := database.ExportTables()
+	if err != nil {
+		return err
+	}
+	schema, err := database.GetSchemaVersion()
+	if err != nil {
+		return err
+	}
+	manifest := BackupManifest{
+		FormatVersion: BACKUP_FORMAT_VERSION,
+		SchemaVersion: schema.Version,
+		ServerVersion: servercfg.GetVersion(),
+		Database:      servercfg.GetDB(),
+		CreatedAt:     time.Now().Unix(),
+		Tables:        database.Tables(),
+		Encrypted:     database.IsEncrypted(),
+	}
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	manifestData, err := json.Marshal(&manifest)
+	if err != nil {
+		return err
+	}
+	if err = writeBackupFile(tw, backupManifestFile, manifestData, 0600); err != nil {
+		return err
+	}
+	for _, table := range manifest.Tables {
+		data, err := json.Marshal(records[table])
+		if err != nil {
+			return err
+		}
+		if err = writeBackupFile(tw, backupTablesDir+table+".json", data, 0600); err != nil {
+			return err
+		}
+	}
+	for _, name := range backupCAFiles {
+		data, err := os.ReadFile(filepath.Join(caDir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logger.Log(1, "backup: root CA file", name, "not found, skipping")
+				continue
+			}
+			return err
+		}
+		if err = writeBackupFile(tw, backupCADir+name, data, 0600); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// RestoreBackup - restores a backup archive into an empty database and writes its root CA to caDir
+// every record is validated before anything is written
+func RestoreBackup(r io.Reader, caDir string) (BackupManifest, error) {
+	var manifest BackupManifest
+	var hasManifest bool
+	records := make(map[string]map[string]string)
+	caFiles := make(map[string][]byte)
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, fmt.Errorf("invalid backup archive: %w", err)
+	}
+	defer gzr.Close()
+	archive := &io.LimitedReader{R: gzr, N: backupMaxSize + 1}
+	tr := tar.NewReader(archive)
+	for {
+		header, err := tr.Next()
+		if archive.N <= 0 {
+			return manifest, fmt.Errorf("invalid backup archive: larger than %d bytes", backupMaxSize)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("invalid backup archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > backupMaxEntrySize {
+			return manifest, fmt.Errorf("invalid backup archive: %s is larger than %d bytes", header.Name, backupMaxEntrySize)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, backupMaxEntrySize+1))
+		if err != nil {
+			return manifest, err
+		}
+		if archive.N <= 0 {
+			return manifest, fmt.Errorf("invalid backup archive: larger than %d bytes", backupMaxSize)
+		}
+		if int64(len(data)) > backupMaxEntrySize {
+			return manifest, fmt.Errorf("invalid backup archive: %s is larger than %d bytes", header.Name, backupMaxEntrySize)
+		}
+		switch {
+		case header.Name == backupManifestFile:
+			if err = json.Unmarshal(data, &manifest); err != nil {
+				return manifest, fmt.Errorf("invalid backup manifest: %w", err)
+			}
+			hasManifest = true
+		case strings.HasPrefix(header.Name, backupTablesDir):
+			table := strings.TrimSuffix(strings.TrimPrefix(header.Name, backupTablesDir), ".json")
+			var tableRecords map[string]string
+			if err = json.Unmarshal(data, &tableRecords); err != nil {
+				return manifest, fmt.Errorf("invalid records for table %s: %w", table, err)
+			}
+			records[table] = tableRecords
+		case strings.HasPrefix(header.Name, backupCADir):
+			name := strings.TrimPrefix(header.Name, backupCADir)
+			for _, caFile := range backupCAFiles {
+				if name == caFile {
+					caFiles[name] = data
+				}
+			}
+		}
+	}
+	if !hasManifest {
+		return manifest, errors.New("invalid backup archive: missing manifest")
+	}
+	if manifest.FormatVersion > BACKUP_FORMAT_VERSION {
+		return manifest, fmt.Errorf("backup format version %d is newer than supported version %d", manifest.FormatVersion, BACKUP_FORMAT_VERSION)
+	}
+	if manifest.SchemaVersion > database.LatestSchemaVersion() {
+		return manifest, fmt.Errorf("backup schema version %d is newer than supported version %d", manifest.SchemaVersion, database.LatestSchemaVersion())
+	}
+	if manifest.Encrypted && !database.IsEncrypted() {
+		return manifest, errors.New("backup holds records sealed with a database encryption key, configure the key before restoring")
+	}
+	if err = database.DecryptTables(records); err != nil {
+		return manifest, err
+	}
+	if err = validateBackupRecords(records); err != nil {
+		return manifest, err
+	}
+	// the restored records carry the schema version they were written with
+	if records[database.GENERATED_TABLE_NAME] == nil {
+		records[database.GENERATED_TABLE_NAME] = make(map[string]string)
+	}
+	if _, ok := records[database.GENERATED_TABLE_NAME][database.SCHEMA_VERSION_RECORD_KEY]; !ok {
+		schemaData, err := json.Marshal(&database.SchemaVersion{Version: manifest.SchemaVersion})
+		if err != nil {
+			return manifest, err
+		}
+		records[database.GENERATED_TABLE_NAME][database.SCHEMA_VERSION_RECORD_KEY] = string(schemaData)
+	}
+	if err = database.ImportTables(records); err != nil {
+		return manifest, err
+	}
+	// bring records written by an older server up to date
+	if _, err = database.RunMigrations(false); err != nil {
+		return manifest, err
+	}
+	if len(caFiles) > 0 {
+		if err = restoreCAFiles(caDir, caFiles); err != nil {
+			return manifest, err
+		}
+	}
+	logger.Log(0, "restored backup created at", time.Unix(manifest.CreatedAt, 0).String(), "from", manifest.Database, "server version", manifest.ServerVersion)
+	return manifest, nil
+}
+
+// validateBackupRecords - checks records parse as the models stored in their table
+func validateBackupRecords(records map[string]map[string]string) error {
+	if err := database.ValidateTables(records); err != nil {
+		return err
+	}
+	for table, tableRecords := range records {
+		for key, value := range tableRecords {
+			var err error
+			switch table {
+			case database.NETWORKS_TABLE_NAME:
+				err = json.Unmarshal([]byte(value), &models.Network{})
+			case database.NODES_TABLE_NAME, database.DELETED_NODES_TABLE_NAME:
+				err = json.Unmarshal([]byte(value), &models.Node{})
+			case database.USERS_TABLE_NAME:
+				err = json.Unmarshal([]byte(value), &models.User{})
+			case database.DNS_TABLE_NAME:
+				err = json.Unmarshal([]byte(value), &models.DNSEntry{})
+			case database.EXT_CLIENT_TABLE_NAME:
+				err = json.Unmarshal([]byte(value), &models.ExtClient{})
+			case database.RESERVATIONS_TABLE_NAME:
+				err = json.Unmarshal([]byte(value), &models.IPReservation{})
+			}
+			if err != nil {
+				return fmt.Errorf("invalid record %q in table %s: %w", key, table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreCAFiles - writes the restored root CA and drops the server certificate so it is reissued on restart
+func restoreCAFiles(caDir string, caFiles map[string][]byte) error {
+	if err := os.MkdirAll(caDir, 0700); err != nil {
+		return err
+	}
+	for name, data := range caFiles {
+		if err := os.WriteFile(filepath.Join(caDir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(caDir, "server.pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	logger.Log(0, "restored root CA, restart the server to reissue its certificate")
+	return nil
+}
+
+func writeBackupFile(tw *tar.Writer, name string, data []byte, mode int64) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    mode,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
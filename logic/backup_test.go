@@ -0,0 +1,126 @@
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestBackupRestore(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	if _, err := CreateNetwork(models.Network{NetID: "backupnet", AddressRange: "10.10.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	srcCA := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcCA, "root.pem"), []byte("root cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := WriteBackup(&archive, srcCA); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("NonEmptyDatabase", func(t *testing.T) {
+		if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()), t.TempDir()); err == nil {
+			t.Fatal("expected restore into a non empty database to fail")
+		}
+	})
+	t.Run("InvalidArchive", func(t *testing.T) {
+		if _, err := RestoreBackup(bytes.NewReader([]byte("not an archive")), t.TempDir()); err == nil {
+			t.Fatal("expected restore of an invalid archive to fail")
+		}
+	})
+	t.Run("TooLarge", func(t *testing.T) {
+		defer func(entrySize, size int64) { backupMaxEntrySize, backupMaxSize = entrySize, size }(backupMaxEntrySize, backupMaxSize)
+		backupMaxEntrySize = 16
+		if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()), t.TempDir()); err == nil || !strings.Contains(err.Error(), "larger than") {
+			t.Fatalf("expected an entry over the size limit to be refused, got %v", err)
+		}
+		backupMaxEntrySize, backupMaxSize = 1<<20, 1024
+		if _, err := RestoreBackup(bytes.NewReader(archive.Bytes()), t.TempDir()); err == nil || !strings.Contains(err.Error(), "larger than") {
+			t.Fatalf("expected an archive over the size limit to be refused, got %v", err)
+		}
+	})
+	t.Run("Restore", func(t *testing.T) {
+		for _, table := range database.Tables() {
+			if err := database.DeleteAllRecords(table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dstCA := t.TempDir()
+		manifest, err := RestoreBackup(bytes.NewReader(archive.Bytes()), dstCA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if manifest.FormatVersion != BACKUP_FORMAT_VERSION || manifest.Database != "memory" {
+			t.Fatalf("unexpected manifest %+v", manifest)
+		}
+		network, err := GetNetwork("backupnet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if network.AddressRange != "10.10.0.0/24" {
+			t.Fatalf("unexpected restored address range %s", network.AddressRange)
+		}
+		cert, err := os.ReadFile(filepath.Join(dstCA, "root.pem"))
+		if err != nil || string(cert) != "root cert" {
+			t.Fatalf("root CA not restored: %q, %v", cert, err)
+		}
+	})
+	t.Run("Encrypted", func(t *testing.T) {
+		defer database.SetEncryptionKey(nil)
+		const secret = `{"clientid":"backupclient","privatekey":"c2VjcmV0"}`
+		if err := database.Insert("backupclient", secret, database.EXT_CLIENT_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		key := make([]byte, database.ENCRYPTION_KEY_SIZE)
+		if _, err := database.RotateEncryptionKey(key); err != nil {
+			t.Fatal(err)
+		}
+		var sealed bytes.Buffer
+		if err := WriteBackup(&sealed, t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		gzr, err := gzip.NewReader(bytes.NewReader(sealed.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := io.ReadAll(gzr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(contents), "c2VjcmV0") {
+			t.Fatal("expected ext client secrets to stay sealed in the backup")
+		}
+
+		for _, table := range database.Tables() {
+			if err := database.DeleteAllRecords(table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		database.SetEncryptionKey(nil)
+		if _, err := RestoreBackup(bytes.NewReader(sealed.Bytes()), t.TempDir()); err == nil {
+			t.Fatal("expected restore of a sealed backup without the key to fail")
+		}
+		database.SetEncryptionKey(key)
+		if _, err := RestoreBackup(bytes.NewReader(sealed.Bytes()), t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+		value, err := database.FetchRecord(database.EXT_CLIENT_TABLE_NAME, "backupclient")
+		if err != nil || value != secret {
+			t.Fatalf("unexpected restored ext client %q, %v", value, err)
+		}
+	})
+}
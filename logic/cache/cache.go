@@ -0,0 +1,271 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// Table - decoded records of a database table held in memory
+// it is kept in sync by every write made through the database package
+// and serves nothing until loaded, so reads fall back to the database while caching is disabled
+type Table struct {
+	name    string
+	decode  func(value string) (interface{}, error)
+	clone   func(record interface{}) interface{}
+	mu      sync.RWMutex
+	records map[string]interface{}
+	loaded  bool
+	hits    uint64
+	misses  uint64
+}
+
+// Stats - hit rate and size of a cached table
+type Stats struct {
+	Table   string  `json:"table"`
+	Loaded  bool    `json:"loaded"`
+	Records int     `json:"records"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitrate"`
+}
+
+// Inconsistency - records that differ between a cached table and the database
+type Inconsistency struct {
+	Table      string   `json:"table"`
+	Missing    []string `json:"missing"`
+	Stale      []string `json:"stale"`
+	Unexpected []string `json:"unexpected"`
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []*Table
+	hookOnce      sync.Once
+)
+
+// New - creates a cache for a table, clone must return a copy sharing no mutable state with its argument
+func New(name string, decode func(value string) (interface{}, error), clone func(record interface{}) interface{}) *Table {
+	table := &Table{name: name, decode: decode, clone: clone}
+	registryMutex.Lock()
+	registry = append(registry, table)
+	registryMutex.Unlock()
+	hookOnce.Do(func() {
+		database.AddWriteHook(applyWrites)
+	})
+	return table
+}
+
+// LoadAll - populates every cached table from the database
+func LoadAll() error {
+	for _, table := range tables() {
+		if err := table.Load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnloadAll - drops every cached table, reads go to the database until loaded again
+func UnloadAll() {
+	for _, table := range tables() {
+		table.Unload()
+	}
+}
+
+// AllStats - returns the stats of every cached table
+func AllStats() []Stats {
+	var stats []Stats
+	for _, table := range tables() {
+		stats = append(stats, table.Stats())
+	}
+	return stats
+}
+
+// CheckAll - compares every loaded table with the database
+func CheckAll() ([]Inconsistency, error) {
+	var inconsistencies []Inconsistency
+	for _, table := range tables() {
+		inconsistency, err := table.Check()
+		if err != nil {
+			return nil, err
+		}
+		if inconsistency != nil {
+			inconsistencies = append(inconsistencies, *inconsistency)
+		}
+	}
+	return inconsistencies, nil
+}
+
+// Table.Load - populates the table from the database
+// the lock is held while reading so writes made meanwhile are applied after the load
+func (t *Table) Load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records, err := t.fetch()
+	if err != nil {
+		return err
+	}
+	t.records = records
+	t.loaded = true
+	logger.Log(2, "cached", fmt.Sprint(len(records)), "records of table", t.name)
+	return nil
+}
+
+// Table.Unload - drops the cached records
+func (t *Table) Unload() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = nil
+	t.loaded = false
+}
+
+// Table.Get - returns a copy of a cached record, ok is false if it must be read from the database
+func (t *Table) Get(key string) (interface{}, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.loaded {
+		return nil, false
+	}
+	record, ok := t.records[key]
+	if !ok {
+		atomic.AddUint64(&t.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&t.hits, 1)
+	return t.clone(record), true
+}
+
+// Table.List - returns copies of every cached record, ok is false if they must be read from the database
+// an empty table is not served so callers keep the database's empty table error
+func (t *Table) List() ([]interface{}, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.loaded || len(t.records) == 0 {
+		if t.loaded {
+			atomic.AddUint64(&t.misses, 1)
+		}
+		return nil, false
+	}
+	atomic.AddUint64(&t.hits, 1)
+	records := make([]interface{}, 0, len(t.records))
+	for _, record := range t.records {
+		records = append(records, t.clone(record))
+	}
+	return records, true
+}
+
+// Table.Stats - returns the hit rate and size of the table
+func (t *Table) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	stats := Stats{
+		Table:   t.name,
+		Loaded:  t.loaded,
+		Records: len(t.records),
+		Hits:    atomic.LoadUint64(&t.hits),
+		Misses:  atomic.LoadUint64(&t.misses),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// Table.Check - compares the cached records with the database, returning nil if they match or the table is not loaded
+func (t *Table) Check() (*Inconsistency, error) {
+	stored, err := t.fetch()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.loaded {
+		return nil, nil
+	}
+	inconsistency := Inconsistency{Table: t.name}
+	for key, record := range stored {
+		cached, ok := t.records[key]
+		if !ok {
+			inconsistency.Missing = append(inconsistency.Missing, key)
+		} else if !reflect.DeepEqual(cached, record) {
+			inconsistency.Stale = append(inconsistency.Stale, key)
+		}
+	}
+	for key := range t.records {
+		if _, ok := stored[key]; !ok {
+			inconsistency.Unexpected = append(inconsistency.Unexpected, key)
+		}
+	}
+	if len(inconsistency.Missing)+len(inconsistency.Stale)+len(inconsistency.Unexpected) == 0 {
+		return nil, nil
+	}
+	sort.Strings(inconsistency.Missing)
+	sort.Strings(inconsistency.Stale)
+	sort.Strings(inconsistency.Unexpected)
+	return &inconsistency, nil
+}
+
+// Table.fetch - reads and decodes every record of the table from the database
+func (t *Table) fetch() (map[string]interface{}, error) {
+	values, err := database.FetchRecords(t.name)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	records := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		record, err := t.decode(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode record %s of table %s: %w", key, t.name, err)
+		}
+		records[key] = record
+	}
+	return records, nil
+}
+
+// Table.apply - applies committed writes to the cached records
+// a record that fails to decode unloads the table so reads go back to the database
+func (t *Table) apply(ops []database.TxOp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.loaded {
+		return
+	}
+	for _, op := range ops {
+		if op.Table != t.name {
+			continue
+		}
+		switch {
+		case op.Delete && op.Key == "":
+			t.records = make(map[string]interface{})
+		case op.Delete:
+			delete(t.records, op.Key)
+		default:
+			record, err := t.decode(op.Value)
+			if err != nil {
+				logger.Log(1, "failed to cache record", op.Key, "of table", t.name, err.Error())
+				t.records = nil
+				t.loaded = false
+				return
+			}
+			t.records[op.Key] = record
+		}
+	}
+}
+
+func applyWrites(ops []database.TxOp) {
+	for _, table := range tables() {
+		table.apply(ops)
+	}
+}
+
+func tables() []*Table {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	return append([]*Table{}, registry...)
+}
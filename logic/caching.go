@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"encoding/json"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/cache"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+var (
+	nodeCache = cache.New(database.NODES_TABLE_NAME, func(value string) (interface{}, error) {
+		var node models.Node
+		err := json.Unmarshal([]byte(value), &node)
+		return node, err
+	}, func(record interface{}) interface{} {
+		node := record.(models.Node)
+		node.AllowedIPs = copyStrings(node.AllowedIPs)
+		node.EgressGatewayRanges = copyStrings(node.EgressGatewayRanges)
+		node.RelayAddrs = copyStrings(node.RelayAddrs)
+		node.Labels = copyLabels(node.Labels)
+		return node
+	})
+	networkCache = cache.New(database.NETWORKS_TABLE_NAME, func(value string) (interface{}, error) {
+		var network models.Network
+		err := json.Unmarshal([]byte(value), &network)
+		return network, err
+	}, func(record interface{}) interface{} {
+		network := record.(models.Network)
+		if network.AccessKeys != nil {
+			network.AccessKeys = append(make([]models.AccessKey, 0, len(network.AccessKeys)), network.AccessKeys...)
+		}
+		network.SecondaryRanges = copyStrings(network.SecondaryRanges)
+		network.SecondaryRanges6 = copyStrings(network.SecondaryRanges6)
+		return network
+	})
+	extClientCache = cache.New(database.EXT_CLIENT_TABLE_NAME, func(value string) (interface{}, error) {
+		var extclient models.ExtClient
+		err := json.Unmarshal([]byte(value), &extclient)
+		return extclient, err
+	}, func(record interface{}) interface{} {
+		return record.(models.ExtClient)
+	})
+)
+
+// InitCache - loads nodes, networks, ext clients, ACLs and the addresses they hold into memory if caching is enabled
+func InitCache() error {
+	if !servercfg.IsCachingEnabled() {
+		return nil
+	}
+	if err := cache.LoadAll(); err != nil {
+		return err
+	}
+	return addresses.load()
+}
+
+// CacheCheck - result of comparing the cache with the database
+type CacheCheck struct {
+	Consistent      bool                  `json:"consistent"`
+	Inconsistencies []cache.Inconsistency `json:"inconsistencies"`
+}
+
+// GetCacheStats - gets the hit rate and size of every cached table
+func GetCacheStats() []cache.Stats {
+	return cache.AllStats()
+}
+
+// CheckCache - compares every cached table with the database
+func CheckCache() (CacheCheck, error) {
+	inconsistencies, err := cache.CheckAll()
+	if err != nil {
+		return CacheCheck{}, err
+	}
+	return CacheCheck{Consistent: len(inconsistencies) == 0, Inconsistencies: inconsistencies}, nil
+}
+
+// cachedNode - fetches a node from the cache, ok is false if it must be read from the database
+func cachedNode(id string) (models.Node, bool) {
+	record, ok := nodeCache.Get(id)
+	if !ok {
+		return models.Node{}, false
+	}
+	return record.(models.Node), true
+}
+
+// cachedNodes - fetches every node from the cache, ok is false if they must be read from the database
+func cachedNodes() ([]models.Node, bool) {
+	records, ok := nodeCache.List()
+	if !ok {
+		return nil, false
+	}
+	nodes := make([]models.Node, 0, len(records))
+	for _, record := range records {
+		nodes = append(nodes, record.(models.Node))
+	}
+	return nodes, true
+}
+
+// cachedNetwork - fetches a network from the cache, ok is false if it must be read from the database
+func cachedNetwork(netid string) (models.Network, bool) {
+	record, ok := networkCache.Get(netid)
+	if !ok {
+		return models.Network{}, false
+	}
+	return record.(models.Network), true
+}
+
+// cachedNetworks - fetches every network from the cache, ok is false if they must be read from the database
+func cachedNetworks() ([]models.Network, bool) {
+	records, ok := networkCache.List()
+	if !ok {
+		return nil, false
+	}
+	networks := make([]models.Network, 0, len(records))
+	for _, record := range records {
+		networks = append(networks, record.(models.Network))
+	}
+	return networks, true
+}
+
+// cachedExtClient - fetches an ext client from the cache, ok is false if it must be read from the database
+func cachedExtClient(key string) (models.ExtClient, bool) {
+	record, ok := extClientCache.Get(key)
+	if !ok {
+		return models.ExtClient{}, false
+	}
+	return record.(models.ExtClient), true
+}
+
+// cachedExtClients - fetches every ext client from the cache, ok is false if they must be read from the database
+func cachedExtClients() ([]models.ExtClient, bool) {
+	records, ok := extClientCache.List()
+	if !ok {
+		return nil, false
+	}
+	extclients := make([]models.ExtClient, 0, len(records))
+	for _, record := range records {
+		extclients = append(extclients, record.(models.ExtClient))
+	}
+	return extclients, true
+}
+
+// copyStrings - copies a slice, keeping nil and empty slices distinct
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(values)), values...)
+}
+
+// copyLabels - copies the labels of a node, keeping nil and empty labels distinct
+func copyLabels(labels models.Labels) models.Labels {
+	if labels == nil {
+		return nil
+	}
+	copied := make(models.Labels, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
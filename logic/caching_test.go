@@ -0,0 +1,111 @@
+package logic
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/cache"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestCache(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	if _, err := CreateNetwork(models.Network{NetID: "cachenet", AddressRange: "10.20.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitCache(); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.UnloadAll()
+
+	t.Run("Hit", func(t *testing.T) {
+		before := networkCache.Stats()
+		network, err := GetNetwork("cachenet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if network.AddressRange != "10.20.0.0/24" {
+			t.Fatalf("unexpected cached address range %s", network.AddressRange)
+		}
+		if after := networkCache.Stats(); after.Hits != before.Hits+1 {
+			t.Fatalf("expected a cache hit, stats before %+v after %+v", before, after)
+		}
+	})
+	t.Run("WriteThrough", func(t *testing.T) {
+		network, err := GetNetwork("cachenet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		network.NodeLimit = 42
+		if err = SaveNetwork(&network); err != nil {
+			t.Fatal(err)
+		}
+		cached, err := GetNetwork("cachenet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached.NodeLimit != 42 {
+			t.Fatalf("expected cache to see write, got node limit %d", cached.NodeLimit)
+		}
+	})
+	t.Run("CopiesRecords", func(t *testing.T) {
+		network, err := GetNetwork("cachenet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		network.AccessKeys = append(network.AccessKeys, models.AccessKey{Name: "notsaved"})
+		cached, err := GetNetwork("cachenet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cached.AccessKeys) != 0 {
+			t.Fatal("modifying a returned network changed the cache")
+		}
+		cached.SecondaryRanges = []string{"10.21.0.0/24"}
+		if err = SaveNetwork(&cached); err != nil {
+			t.Fatal(err)
+		}
+		network, _ = GetNetwork("cachenet")
+		network.SecondaryRanges[0] = "10.22.0.0/24"
+		if cached, _ = GetNetwork("cachenet"); cached.SecondaryRanges[0] != "10.21.0.0/24" {
+			t.Fatal("modifying the secondary ranges of a returned network changed the cache")
+		}
+		node := models.Node{ID: "labeled", Name: "labeled", Network: "cachenet", Labels: models.Labels{"role": "db"}}
+		data, _ := json.Marshal(&node)
+		if err = database.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		node, _ = GetNodeByID("labeled")
+		node.Labels["role"] = "web"
+		if node, _ = GetNodeByID("labeled"); node.Labels["role"] != "db" {
+			t.Fatal("modifying the labels of a returned node changed the cache")
+		}
+	})
+	t.Run("Consistent", func(t *testing.T) {
+		check, err := CheckCache()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !check.Consistent {
+			t.Fatalf("unexpected inconsistencies %+v", check.Inconsistencies)
+		}
+	})
+	t.Run("DeleteAll", func(t *testing.T) {
+		if err := database.DeleteAllRecords(database.NETWORKS_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := GetNetwork("cachenet"); err == nil {
+			t.Fatal("expected deleted network to be gone from the cache")
+		}
+		if _, err := GetNetworks(); !database.IsEmptyRecord(err) {
+			t.Fatalf("expected empty record error, got %v", err)
+		}
+	})
+}
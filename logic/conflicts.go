@@ -0,0 +1,227 @@
+package logic
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// GetAddressConflicts - every pair of ranges used on the server that overlap in a way that routes the same addresses two ways
+func GetAddressConflicts() ([]models.AddressConflict, error) {
+	var conflicts = []models.AddressConflict{}
+	uses, err := addressRangeUses()
+	if err != nil {
+		return conflicts, err
+	}
+	for i := range uses {
+		for j := i + 1; j < len(uses); j++ {
+			if rangeUsesConflict(uses[i], uses[j]) {
+				conflicts = append(conflicts, models.AddressConflict{Use: uses[i], With: uses[j]})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// checkRangeConflicts - refuses new range uses that conflict with each other or with those already on the server,
+// leaving out the ranges stored for a network being replaced by the new ones
+func checkRangeConflicts(uses []models.AddressRangeUse, replacedNetwork string) error {
+	if len(uses) == 0 {
+		return nil
+	}
+	existing, err := addressRangeUses()
+	if err != nil {
+		return err
+	}
+	for i, use := range uses {
+		for _, other := range uses[i+1:] {
+			if rangeUsesConflict(use, other) {
+				return fmt.Errorf("%s overlaps %s", describeRangeUse(use), describeRangeUse(other))
+			}
+		}
+		for _, other := range existing {
+			if other.NodeID == "" && other.Network == replacedNetwork {
+				continue
+			}
+			if rangeUsesConflict(use, other) {
+				return fmt.Errorf("%s overlaps %s", describeRangeUse(use), describeRangeUse(other))
+			}
+		}
+	}
+	return nil
+}
+
+// addressRangeUses - the ranges routed by every network and node on the server
+func addressRangeUses() ([]models.AddressRangeUse, error) {
+	var uses []models.AddressRangeUse
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	for i := range networks {
+		uses = append(uses, networkRangeUses(&networks[i])...)
+	}
+	nodes, err := GetAllNodes()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	for i := range nodes {
+		uses = append(uses, nodeRangeUses(&nodes[i])...)
+	}
+	return uses, nil
+}
+
+// networkRangeUses - the address ranges and local range of a network
+func networkRangeUses(network *models.Network) []models.AddressRangeUse {
+	var uses []models.AddressRangeUse
+	for _, addressRange := range append(network.AddressRanges(), network.AddressRanges6()...) {
+		uses = append(uses, models.AddressRangeUse{Range: addressRange, Kind: models.RANGE_NETWORK, Network: network.NetID})
+	}
+	if network.LocalRange != "" {
+		uses = append(uses, models.AddressRangeUse{Range: network.LocalRange, Kind: models.RANGE_LOCAL, Network: network.NetID})
+	}
+	return uses
+}
+
+// nodeRangeUses - the egress ranges, relayed addresses and local range of a node
+func nodeRangeUses(node *models.Node) []models.AddressRangeUse {
+	var uses []models.AddressRangeUse
+	use := func(addressRange, kind string) {
+		uses = append(uses, models.AddressRangeUse{Range: addressRange, Kind: kind, Network: node.Network, NodeID: node.ID, NodeName: node.Name})
+	}
+	if node.IsEgressGateway == "yes" {
+		for _, addressRange := range node.EgressGatewayRanges {
+			use(addressRange, models.RANGE_EGRESS)
+		}
+	}
+	if node.IsRelay == "yes" {
+		for _, address := range node.RelayAddrs {
+			use(address, models.RANGE_RELAY)
+		}
+	}
+	if node.LocalRange != "" {
+		use(node.LocalRange, models.RANGE_LOCAL)
+	}
+	return uses
+}
+
+// changedRangeUses - the egress ranges and relayed addresses of a node that it did not have before an update,
+// local ranges are reported by nodes themselves so they are left to GetAddressConflicts rather than refused
+func changedRangeUses(currentNode, newNode *models.Node) []models.AddressRangeUse {
+	var changed []models.AddressRangeUse
+	for _, use := range addedRangeUses(nodeRangeUses(currentNode), nodeRangeUses(newNode)) {
+		if use.Kind != models.RANGE_LOCAL {
+			changed = append(changed, use)
+		}
+	}
+	return changed
+}
+
+// checkNetworkUpdateConflicts - refuses the ranges a network update adds when they conflict with the other ranges
+// of the network or with those already on the server, conflicts the network had before are left to GetAddressConflicts
+// so they do not block unrelated changes
+func checkNetworkUpdateConflicts(currentNetwork, newNetwork *models.Network) error {
+	uses := networkRangeUses(newNetwork)
+	added := addedRangeUses(networkRangeUses(currentNetwork), uses)
+	for _, use := range added {
+		for _, other := range uses {
+			if rangeUsesConflict(use, other) {
+				return fmt.Errorf("%s overlaps %s", describeRangeUse(use), describeRangeUse(other))
+			}
+		}
+	}
+	return checkRangeConflicts(added, newNetwork.NetID)
+}
+
+// addedRangeUses - the uses of next that are not in current
+func addedRangeUses(current, next []models.AddressRangeUse) []models.AddressRangeUse {
+	var added []models.AddressRangeUse
+	for _, use := range next {
+		isNew := true
+		for _, old := range current {
+			if old.Kind == use.Kind && old.Range == use.Range {
+				isNew = false
+			}
+		}
+		if isNew {
+			added = append(added, use)
+		}
+	}
+	return added
+}
+
+// rangeUsesConflict - checks if two range uses overlap where clients would route the same addresses two ways,
+// such as networks sharing addresses, an egress range inside a network or a node's LAN, and ranges
+// two nodes of a network both route, the ranges of a single node never conflict with each other
+// egress ranges covering a whole network or LAN, such as a default route, leave the more specific route in place
+func rangeUsesConflict(a, b models.AddressRangeUse) bool {
+	if a.NodeID != "" && a.NodeID == b.NodeID {
+		return false
+	}
+	if !rangesOverlap(asCIDR(a.Range), asCIDR(b.Range)) {
+		return false
+	}
+	pair := func(x, y string) bool {
+		return (a.Kind == x && b.Kind == y) || (a.Kind == y && b.Kind == x)
+	}
+	sameNetwork := a.Network == b.Network
+	egressInside := func() bool {
+		if a.Kind == models.RANGE_EGRESS {
+			return rangeContains(asCIDR(b.Range), asCIDR(a.Range))
+		}
+		return rangeContains(asCIDR(a.Range), asCIDR(b.Range))
+	}
+	switch {
+	case pair(models.RANGE_NETWORK, models.RANGE_NETWORK):
+		return !sameNetwork
+	case pair(models.RANGE_NETWORK, models.RANGE_LOCAL):
+		return a.NodeID == "" && b.NodeID == ""
+	case pair(models.RANGE_NETWORK, models.RANGE_EGRESS):
+		return egressInside()
+	case pair(models.RANGE_EGRESS, models.RANGE_LOCAL):
+		return sameNetwork && a.NodeID != "" && b.NodeID != "" && egressInside()
+	case pair(models.RANGE_EGRESS, models.RANGE_EGRESS), pair(models.RANGE_EGRESS, models.RANGE_RELAY), pair(models.RANGE_RELAY, models.RANGE_RELAY):
+		return sameNetwork
+	}
+	return false
+}
+
+// describeRangeUse - names a range use in an error
+func describeRangeUse(use models.AddressRangeUse) string {
+	var kind = map[string]string{
+		models.RANGE_NETWORK: "address range",
+		models.RANGE_LOCAL:   "local range",
+		models.RANGE_EGRESS:  "egress range",
+		models.RANGE_RELAY:   "relayed address",
+	}[use.Kind]
+	if use.NodeID != "" {
+		return fmt.Sprintf("%s %s of node %s on network %s", kind, use.Range, use.NodeName, use.Network)
+	}
+	return fmt.Sprintf("%s %s of network %s", kind, use.Range, use.Network)
+}
+
+// rangeContains - checks if every address of inner is in outer
+func rangeContains(outer, inner string) bool {
+	_, outerCIDR, errOuter := net.ParseCIDR(outer)
+	_, innerCIDR, errInner := net.ParseCIDR(inner)
+	if errOuter != nil || errInner != nil {
+		return false
+	}
+	outerOnes, outerBits := outerCIDR.Mask.Size()
+	innerOnes, innerBits := innerCIDR.Mask.Size()
+	return outerBits == innerBits && innerOnes >= outerOnes && outerCIDR.Contains(innerCIDR.IP)
+}
+
+// asCIDR - a range, or a single address as a range of its own
+func asCIDR(addressRange string) string {
+	if strings.Contains(addressRange, "/") {
+		return addressRange
+	}
+	if ip := net.ParseIP(addressRange); ip != nil && ip.To4() != nil {
+		return addressRange + "/32"
+	}
+	return addressRange + "/128"
+}
@@ -0,0 +1,135 @@
+package logic
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestAddressConflicts(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	if _, err := CreateNetwork(models.Network{NetID: "overlapa", AddressRange: "10.80.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []models.Node{
+		{ID: "gateway", Name: "gateway", Network: "overlapa", Address: "10.80.0.1", IsEgressGateway: "yes", EgressGatewayRanges: []string{"172.16.0.0/24"}},
+		{ID: "lan", Name: "lan", Network: "overlapa", Address: "10.80.0.2", LocalRange: "192.168.10.0/24"},
+	} {
+		data, _ := json.Marshal(&node)
+		if err := database.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Networks", func(t *testing.T) {
+		if _, err := CreateNetwork(models.Network{NetID: "overlapb", AddressRange: "10.80.0.0/16"}); err == nil {
+			t.Fatal("expected a network overlapping another to be refused")
+		}
+		if _, err := CreateNetwork(models.Network{NetID: "overlapb", AddressRange: "172.16.0.0/16"}); err == nil {
+			t.Fatal("expected a network overlapping an egress range to be refused")
+		}
+		if _, err := CreateNetwork(models.Network{NetID: "overlapb", AddressRange: "10.81.0.0/24", LocalRange: "10.81.0.0/16"}); err == nil {
+			t.Fatal("expected a local range overlapping the network to be refused")
+		}
+		network, _ := GetParentNetwork("overlapa")
+		network.AddressRange = "10.80.0.0/23"
+		if err := ValidateNetwork(&network, true); err != nil {
+			t.Fatalf("expected a network to be compared with others only, got %v", err)
+		}
+	})
+	t.Run("Egress", func(t *testing.T) {
+		for name, gateway := range map[string]models.EgressGatewayRequest{
+			"the network":           {NodeID: "lan", Ranges: []string{"10.80.0.128/25"}},
+			"another node's egress": {NodeID: "lan", Ranges: []string{"172.16.0.0/16"}},
+			"another node's LAN":    {NodeID: "gateway", Ranges: []string{"192.168.10.0/25"}},
+		} {
+			gateway.Interface = "eth0"
+			if err := ValidateEgressGateway(gateway); err == nil {
+				t.Fatalf("expected an egress range overlapping %s to be refused", name)
+			}
+		}
+		if err := ValidateEgressGateway(models.EgressGatewayRequest{NodeID: "lan", Interface: "eth0", Ranges: []string{"192.168.10.0/24"}}); err != nil {
+			t.Fatalf("expected a node to route its own LAN, got %v", err)
+		}
+		if err := ValidateEgressGateway(models.EgressGatewayRequest{NodeID: "gateway", Interface: "eth0", Ranges: []string{"172.16.0.0/16"}}); err != nil {
+			t.Fatalf("expected a gateway to replace its own ranges, got %v", err)
+		}
+		for _, supernet := range []string{"0.0.0.0/0", "10.0.0.0/8", "192.168.0.0/16"} {
+			if err := ValidateEgressGateway(models.EgressGatewayRequest{NodeID: "gateway", Interface: "eth0", Ranges: []string{supernet}}); err != nil {
+				t.Fatalf("expected an egress range covering the network and LANs to be allowed, got %v", err)
+			}
+		}
+	})
+	t.Run("Relay", func(t *testing.T) {
+		if err := ValidateRelay(models.RelayRequest{NodeID: "lan", RelayAddrs: []string{"172.16.0.5"}}); err == nil {
+			t.Fatal("expected a relayed address routed by an egress gateway to be refused")
+		}
+		if err := ValidateRelay(models.RelayRequest{NodeID: "lan", RelayAddrs: []string{"10.80.0.1"}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("NetworkUpdate", func(t *testing.T) {
+		legacy := models.Node{ID: "legacygateway", Name: "legacygateway", Network: "overlapa", Address: "10.80.0.3", IsEgressGateway: "yes", EgressGatewayRanges: []string{"10.80.0.128/25"}}
+		data, _ := json.Marshal(&legacy)
+		if err := database.Insert(legacy.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		defer database.DeleteRecord(database.NODES_TABLE_NAME, legacy.ID)
+		network, _ := GetParentNetwork("overlapa")
+		network.DefaultMTU = 1400
+		if err := ValidateNetwork(&network, true); err != nil {
+			t.Fatalf("expected a conflict the network already had not to block other changes, got %v", err)
+		}
+		network.LocalRange = "10.80.0.0/16"
+		if err := ValidateNetwork(&network, true); err == nil {
+			t.Fatal("expected a new local range overlapping the network to be refused")
+		}
+	})
+	t.Run("NodeUpdate", func(t *testing.T) {
+		current, _ := GetNodeByID("lan")
+		updated := current
+		updated.LocalRange = "172.16.0.0/16"
+		if uses := changedRangeUses(&current, &updated); len(uses) != 0 {
+			t.Fatalf("expected a local range reported by a node to be left to the report, got %+v", uses)
+		}
+		reported := updated
+		data, _ := json.Marshal(&reported)
+		if err := database.Insert(reported.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		if conflicts, err := GetAddressConflicts(); err != nil || len(conflicts) != 1 || conflicts[0].Use.NodeID == conflicts[0].With.NodeID {
+			t.Fatalf("expected the egress range inside the reported local range to be reported, got %+v %v", conflicts, err)
+		}
+		data, _ = json.Marshal(&current)
+		if err := database.Insert(current.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		updated.IsEgressGateway = "yes"
+		updated.EgressGatewayRanges = []string{"172.16.0.0/28"}
+		if err := checkRangeConflicts(changedRangeUses(&current, &updated), ""); err == nil {
+			t.Fatal("expected an egress range routed by another egress gateway to be refused")
+		}
+	})
+	t.Run("Report", func(t *testing.T) {
+		legacy := models.Network{NetID: "legacy", AddressRange: "10.80.0.0/16"}
+		data, _ := json.Marshal(&legacy)
+		if err := database.Insert(legacy.NetID, string(data), database.NETWORKS_TABLE_NAME); err != nil {
+			t.Fatal(err)
+		}
+		conflicts, err := GetAddressConflicts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(conflicts) != 1 || conflicts[0].Use.Network == conflicts[0].With.Network {
+			t.Fatalf("expected the networks sharing addresses to be reported, got %+v", conflicts)
+		}
+	})
+}
@@ -0,0 +1,901 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"gopkg.in/yaml.v3"
+)
+
+// declarationMutex - keeps a declaration from being planned while another one is applied
+var declarationMutex sync.Mutex
+
+// undeclaredNetworkFields - network fields kept by the server or managed through their own lists
+var undeclaredNetworkFields = map[string]bool{
+	"NetID":               true,
+	"NodesLastModified":   true,
+	"NetworkLastModified": true,
+	"Revision":            true,
+	"AccessKeys":          true,
+}
+
+// declarationStep - a change needed by a declaration, with what it changes for the audit log and how to make it
+type declarationStep struct {
+	change models.DeclarationChange
+	before interface{}
+	after  interface{}
+	apply  func() error
+}
+
+// ParseDeclaration - parses a declaration written as YAML or JSON, unknown fields are refused
+func ParseDeclaration(data []byte) (models.Declaration, error) {
+	var declaration models.Declaration
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return declaration, err
+	}
+	// YAML is decoded through JSON so both use the json names of the models
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return declaration, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&declaration); err != nil {
+		return declaration, err
+	}
+	return declaration, nil
+}
+
+// ApplyDeclaration - brings the server to a declaration, or with dryRun only works out the changes needed
+// with prune, networks and the objects of given lists that are not declared are removed
+// changes stop at the first failure, the result holds every change and how many were applied
+// each applied change is recorded in the audit log as made by actor
+func ApplyDeclaration(declaration models.Declaration, dryRun, prune bool, actor models.AuditEntry) (models.DeclarationResult, error) {
+	declarationMutex.Lock()
+	defer declarationMutex.Unlock()
+	result := models.DeclarationResult{DryRun: dryRun, Prune: prune, Changes: []models.DeclarationChange{}}
+	steps, err := planDeclaration(declaration, prune)
+	if err != nil {
+		return result, err
+	}
+	for _, step := range steps {
+		result.Changes = append(result.Changes, step.change)
+	}
+	if dryRun {
+		return result, nil
+	}
+	var dnsChanged bool
+	for _, step := range steps {
+		if err = step.apply(); err != nil {
+			err = fmt.Errorf("failed to %s %s %s on network %s: %w", step.change.Action, step.change.Kind, step.change.Name, step.change.Network, err)
+			result.Error = err.Error()
+			break
+		}
+		result.Applied++
+		entry := actor
+		entry.Action = step.change.Action
+		entry.TargetType = step.change.Kind
+		entry.Target = step.change.Name
+		entry.Network = step.change.Network
+		Audit(entry, step.before, step.after)
+		dnsChanged = dnsChanged || step.change.Kind == models.DECLARED_DNS
+	}
+	if dnsChanged && servercfg.IsDNSMode() {
+		if dnsErr := SetDNS(); dnsErr != nil {
+			logger.Log(0, "failed to write DNS after applying declaration:", dnsErr.Error())
+		}
+	}
+	return result, err
+}
+
+// planDeclaration - works out the changes a declaration needs, in the order they are applied
+func planDeclaration(declaration models.Declaration, prune bool) ([]declarationStep, error) {
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	var current = make(map[string]models.Network, len(networks))
+	for _, network := range networks {
+		current[network.NetID] = network
+	}
+	var steps []declarationStep
+	var declared = make(map[string]bool, len(declaration.Networks))
+	for i := range declaration.Networks {
+		netid := declaration.Networks[i].NetID
+		if netid == "" {
+			return nil, errors.New("every declared network needs a netid")
+		}
+		if declared[netid] {
+			return nil, errors.New("network " + netid + " is declared more than once")
+		}
+		declared[netid] = true
+		network, exists := current[netid]
+		networkSteps, err := planNetwork(&declaration.Networks[i], network, exists, prune)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %w", netid, err)
+		}
+		steps = append(steps, networkSteps...)
+	}
+	if prune {
+		sort.Slice(networks, func(i, j int) bool { return networks[i].NetID < networks[j].NetID })
+		for _, network := range networks {
+			if declared[network.NetID] {
+				continue
+			}
+			netid := network.NetID
+			steps = append(steps, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_NETWORK, netid, netid, networkSettings(network), nil, func() error {
+				return DeleteNetwork(netid)
+			}))
+		}
+	}
+	return steps, nil
+}
+
+// planNetwork - works out the changes to a network and its objects, creates and updates come before removals
+func planNetwork(declared *models.DeclaredNetwork, current models.Network, exists, prune bool) ([]declarationStep, error) {
+	netid := declared.NetID
+	settings := declared.Network
+	settings.AccessKeys = nil
+	var steps, removals []declarationStep
+	var defaultACL string
+	if !exists {
+		if settings.AddressRange == "" && settings.AddressRange6 == "" {
+			return nil, errors.New("IPv4 or IPv6 CIDR required")
+		}
+		created := settings
+		created.SetDefaults()
+		if err := ValidateNetwork(&created, false); err != nil {
+			return nil, err
+		}
+		steps = append(steps, newDeclarationStep(models.AUDIT_CREATE, models.DECLARED_NETWORK, netid, netid, nil, networkSettings(created), func() error {
+			_, err := CreateNetwork(settings)
+			return err
+		}))
+		defaultACL = created.DefaultACL
+	} else {
+		if !servercfg.GetRce() {
+			settings.DefaultPostUp, settings.DefaultPostDown = "", ""
+		}
+		merged := mergeNetworkSettings(current, settings)
+		if before, after := networkSettings(current), networkSettings(merged); !reflect.DeepEqual(before, after) {
+			if err := ValidateNetwork(&merged, true); err != nil {
+				return nil, err
+			}
+			steps = append(steps, newDeclarationStep(models.AUDIT_UPDATE, models.DECLARED_NETWORK, netid, netid, before, after, func() error {
+				return applyNetworkSettings(settings)
+			}))
+		}
+		defaultACL = merged.DefaultACL
+	}
+
+	keySteps, keyRemovals, err := planAccessKeys(netid, declared.AccessKeys, current.AccessKeys, prune)
+	if err != nil {
+		return nil, err
+	}
+	steps, removals = append(steps, keySteps...), append(removals, keyRemovals...)
+
+	var customDNS []models.DNSEntry
+	var nodes []models.Node
+	var extClients []models.ExtClient
+	if exists {
+		if customDNS, err = GetCustomDNS(netid); err != nil && !database.IsEmptyRecord(err) {
+			return nil, err
+		}
+		if nodes, err = GetNetworkNodes(netid); err != nil && !database.IsEmptyRecord(err) {
+			return nil, err
+		}
+		if extClients, err = GetNetworkExtClients(netid); err != nil && !database.IsEmptyRecord(err) {
+			return nil, err
+		}
+	}
+	dnsSteps, dnsRemovals := planDNS(netid, declared.DNS, customDNS, prune)
+	steps, removals = append(steps, dnsSteps...), append(dnsRemovals, removals...)
+
+	var nodesByID = make(map[string]models.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByID[node.ID] = node
+	}
+	if declared, err = resolveSelectors(declared, nodes); err != nil {
+		return nil, err
+	}
+	gatewaySteps, gatewayRemovals, err := planGateways(netid, declared, nodes, nodesByID, prune)
+	if err != nil {
+		return nil, err
+	}
+	steps, removals = append(steps, gatewaySteps...), append(gatewayRemovals, removals...)
+
+	clientSteps, clientRemovals, err := planExtClients(netid, declared, extClients, nodesByID, prune)
+	if err != nil {
+		return nil, err
+	}
+	steps, removals = append(steps, clientSteps...), append(clientRemovals, removals...)
+
+	aclSteps, err := planACLs(netid, declared.ACLs, nodes, nodesByID, defaultACL, prune)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, aclSteps...)
+	return append(steps, removals...), nil
+}
+
+// planAccessKeys - keys are matched by name, a key whose uses or value are declared differently is recreated
+func planAccessKeys(netid string, declared, current []models.AccessKey, prune bool) ([]declarationStep, []declarationStep, error) {
+	var steps, removals []declarationStep
+	var currentKeys = make(map[string]models.AccessKey, len(current))
+	for _, key := range current {
+		currentKeys[key.Name] = key
+	}
+	var declaredKeys = make(map[string]bool, len(declared))
+	for _, key := range declared {
+		if key.Name == "" {
+			return nil, nil, errors.New("every declared access key needs a name")
+		}
+		declaredKeys[key.Name] = true
+		key := models.AccessKey{Name: key.Name, Value: key.Value, Uses: key.Uses}
+		existing, ok := currentKeys[key.Name]
+		if !ok {
+			steps = append(steps, newDeclarationStep(models.AUDIT_CREATE, models.DECLARED_ACCESSKEY, netid, key.Name, nil, &key, func() error {
+				network, err := GetParentNetwork(netid)
+				if err != nil {
+					return err
+				}
+				_, err = CreateAccessKey(key, network)
+				return err
+			}))
+			continue
+		}
+		if key.Value == "" {
+			key.Value = existing.Value
+		}
+		if key.Uses == 0 {
+			key.Uses = existing.Uses
+		}
+		if key.Value == existing.Value && key.Uses == existing.Uses {
+			continue
+		}
+		before := models.AccessKey{Name: existing.Name, Value: existing.Value, Uses: existing.Uses}
+		steps = append(steps, newDeclarationStep(models.AUDIT_UPDATE, models.DECLARED_ACCESSKEY, netid, key.Name, &before, &key, func() error {
+			if err := DeleteKey(key.Name, netid); err != nil {
+				return err
+			}
+			network, err := GetParentNetwork(netid)
+			if err != nil {
+				return err
+			}
+			_, err = CreateAccessKey(key, network)
+			return err
+		}))
+	}
+	if prune && declared != nil {
+		for _, key := range current {
+			if declaredKeys[key.Name] {
+				continue
+			}
+			name := key.Name
+			before := models.AccessKey{Name: key.Name, Value: key.Value, Uses: key.Uses}
+			removals = append(removals, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_ACCESSKEY, netid, name, &before, nil, func() error {
+				return DeleteKey(name, netid)
+			}))
+		}
+	}
+	return steps, removals, nil
+}
+
+// planDNS - custom entries are matched by name, an entry with other addresses is recreated
+func planDNS(netid string, declared, current []models.DNSEntry, prune bool) ([]declarationStep, []declarationStep) {
+	var steps, removals []declarationStep
+	var currentEntries = make(map[string]models.DNSEntry, len(current))
+	for _, entry := range current {
+		currentEntries[entry.Name] = entry
+	}
+	var declaredEntries = make(map[string]bool, len(declared))
+	for _, entry := range declared {
+		entry := entry
+		entry.Network = netid
+		declaredEntries[entry.Name] = true
+		existing, ok := currentEntries[entry.Name]
+		if ok && existing.Address == entry.Address && existing.Address6 == entry.Address6 {
+			continue
+		}
+		action, before := models.AUDIT_CREATE, (*models.DNSEntry)(nil)
+		if ok {
+			action, before = models.AUDIT_UPDATE, &existing
+		}
+		steps = append(steps, newDeclarationStep(action, models.DECLARED_DNS, netid, entry.Name, before, &entry, func() error {
+			if ok {
+				if err := DeleteDNS(entry.Name, netid); err != nil {
+					return err
+				}
+			}
+			if err := ValidateDNSCreate(entry); err != nil {
+				return err
+			}
+			_, err := CreateDNS(entry)
+			return err
+		}))
+	}
+	if prune && declared != nil {
+		for _, entry := range current {
+			if declaredEntries[entry.Name] {
+				continue
+			}
+			entry := entry
+			removals = append(removals, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_DNS, netid, entry.Name, &entry, nil, func() error {
+				return DeleteDNS(entry.Name, netid)
+			}))
+		}
+	}
+	return steps, removals
+}
+
+// planGateways - ingress, egress and relay assignments of the nodes of a network,
+// an egress gateway or relay declared with other ranges or addresses is recreated
+func planGateways(netid string, declared *models.DeclaredNetwork, nodes []models.Node, nodesByID map[string]models.Node, prune bool) ([]declarationStep, []declarationStep, error) {
+	var steps, removals []declarationStep
+	var ingress = make(map[string]bool, len(declared.Ingress))
+	for _, nodeid := range declared.Ingress {
+		node, err := declaredNode(netid, nodeid, nodesByID)
+		if err != nil {
+			return nil, nil, err
+		}
+		ingress[nodeid] = true
+		if node.IsIngressGateway == "yes" {
+			continue
+		}
+		nodeid := nodeid
+		steps = append(steps, newDeclarationStep(models.AUDIT_CREATE, models.DECLARED_INGRESS, netid, nodeid, nil, ingressSettings(node), func() error {
+			_, err := CreateIngressGateway(netid, nodeid)
+			return err
+		}))
+	}
+
+	var egress = make(map[string]bool, len(declared.Egress))
+	for _, gateway := range declared.Egress {
+		node, err := declaredNode(netid, gateway.NodeID, nodesByID)
+		if err != nil {
+			return nil, nil, err
+		}
+		egress[gateway.NodeID] = true
+		gateway := gateway
+		gateway.NetID = netid
+		after := models.EgressGatewayRequest{NodeID: gateway.NodeID, NetID: netid, Ranges: gateway.Ranges}
+		if node.IsEgressGateway != "yes" {
+			steps = append(steps, newDeclarationStep(models.AUDIT_CREATE, models.DECLARED_EGRESS, netid, node.ID, nil, &after, func() error {
+				_, err := CreateEgressGateway(gateway.EgressGatewayRequest)
+				return err
+			}))
+		} else if !sameStrings(node.EgressGatewayRanges, gateway.Ranges) {
+			steps = append(steps, newDeclarationStep(models.AUDIT_UPDATE, models.DECLARED_EGRESS, netid, node.ID, egressSettings(node), &after, func() error {
+				if _, err := DeleteEgressGateway(netid, gateway.NodeID); err != nil {
+					return err
+				}
+				_, err := CreateEgressGateway(gateway.EgressGatewayRequest)
+				return err
+			}))
+		}
+	}
+
+	var relays = make(map[string]bool, len(declared.Relays))
+	for _, relay := range declared.Relays {
+		node, err := declaredNode(netid, relay.NodeID, nodesByID)
+		if err != nil {
+			return nil, nil, err
+		}
+		relays[relay.NodeID] = true
+		relay := relay.RelayRequest
+		relay.NetID = netid
+		if node.IsRelay != "yes" {
+			steps = append(steps, newDeclarationStep(models.AUDIT_CREATE, models.DECLARED_RELAY, netid, node.ID, nil, &relay, func() error {
+				_, _, err := CreateRelay(relay)
+				return err
+			}))
+		} else if !sameStrings(node.RelayAddrs, relay.RelayAddrs) {
+			steps = append(steps, newDeclarationStep(models.AUDIT_UPDATE, models.DECLARED_RELAY, netid, node.ID, relaySettings(node), &relay, func() error {
+				if _, _, err := DeleteRelay(netid, relay.NodeID); err != nil {
+					return err
+				}
+				_, _, err := CreateRelay(relay)
+				return err
+			}))
+		}
+	}
+
+	if !prune {
+		return steps, removals, nil
+	}
+	for _, node := range nodes {
+		nodeid := node.ID
+		if declared.Relays != nil && node.IsRelay == "yes" && !relays[nodeid] {
+			removals = append(removals, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_RELAY, netid, nodeid, relaySettings(node), nil, func() error {
+				_, _, err := DeleteRelay(netid, nodeid)
+				return err
+			}))
+		}
+		if declared.Egress != nil && node.IsEgressGateway == "yes" && !egress[nodeid] {
+			removals = append(removals, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_EGRESS, netid, nodeid, egressSettings(node), nil, func() error {
+				_, err := DeleteEgressGateway(netid, nodeid)
+				return err
+			}))
+		}
+	}
+	for _, node := range nodes {
+		nodeid := node.ID
+		if declared.Ingress != nil && node.IsIngressGateway == "yes" && !ingress[nodeid] {
+			removals = append(removals, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_INGRESS, netid, nodeid, ingressSettings(node), nil, func() error {
+				_, err := DeleteIngressGateway(netid, nodeid)
+				return err
+			}))
+		}
+	}
+	return steps, removals, nil
+}
+
+// planExtClients - ext clients are matched by client id, one moved to another gateway is recreated with new keys
+func planExtClients(netid string, declared *models.DeclaredNetwork, current []models.ExtClient, nodesByID map[string]models.Node, prune bool) ([]declarationStep, []declarationStep, error) {
+	var steps, removals []declarationStep
+	var currentClients = make(map[string]models.ExtClient, len(current))
+	for _, client := range current {
+		currentClients[client.ClientID] = client
+	}
+	var declaredClients = make(map[string]bool, len(declared.ExtClients))
+	for _, client := range declared.ExtClients {
+		if client.ClientID == "" {
+			return nil, nil, errors.New("every declared ext client needs a clientid")
+		}
+		gateway, err := declaredNode(netid, client.IngressGatewayID, nodesByID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if gateway.IsIngressGateway != "yes" && !StringSliceContains(declared.Ingress, gateway.ID) {
+			return nil, nil, fmt.Errorf("node %s of ext client %s is not an ingress gateway", gateway.ID, client.ClientID)
+		}
+		declaredClients[client.ClientID] = true
+		client := client
+		existing, ok := currentClients[client.ClientID]
+		switch {
+		case !ok || existing.IngressGatewayID != client.IngressGatewayID:
+			action, before := models.AUDIT_CREATE, (*models.ExtClient)(nil)
+			if ok {
+				action, before = models.AUDIT_UPDATE, &existing
+			}
+			steps = append(steps, newDeclarationStep(action, models.DECLARED_EXTCLIENT, netid, client.ClientID, before, &client, func() error {
+				if ok {
+					if err := DeleteExtClient(netid, client.ClientID); err != nil {
+						return err
+					}
+				}
+				return createDeclaredExtClient(netid, client)
+			}))
+		case client.Enabled != nil && *client.Enabled != existing.Enabled:
+			after := existing
+			after.Enabled = *client.Enabled
+			steps = append(steps, newDeclarationStep(models.AUDIT_UPDATE, models.DECLARED_EXTCLIENT, netid, client.ClientID, &existing, &after, func() error {
+				_, err := UpdateExtClient(existing.ClientID, netid, after.Enabled, &existing)
+				return err
+			}))
+		}
+	}
+	if prune && declared.ExtClients != nil {
+		for _, client := range current {
+			if declaredClients[client.ClientID] {
+				continue
+			}
+			client := client
+			removals = append(removals, newDeclarationStep(models.AUDIT_DELETE, models.DECLARED_EXTCLIENT, netid, client.ClientID, &client, nil, func() error {
+				return DeleteExtClient(netid, client.ClientID)
+			}))
+		}
+	}
+	return steps, removals, nil
+}
+
+// planACLs - declared pairs of nodes are set as declared, with pruning every other pair is set to the network's default
+func planACLs(netid string, declared []models.DeclaredACL, nodes []models.Node, nodesByID map[string]models.Node, defaultACL string, prune bool) ([]declarationStep, error) {
+	if declared == nil {
+		return nil, nil
+	}
+	desired, err := declaredACLValues(netid, declared, nodesByID)
+	if err != nil {
+		return nil, err
+	}
+	if prune {
+		value := acls.NotAllowed
+		if defaultACL == "yes" {
+			value = acls.Allowed
+		}
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				if pair := aclPair(nodes[i].ID, nodes[j].ID); desired[pair] == acls.NotPresent {
+					desired[pair] = value
+				}
+			}
+		}
+	}
+	if len(desired) == 0 {
+		return nil, nil
+	}
+	var container acls.ACLContainer
+	container, err = container.Get(acls.ContainerID(netid))
+	if err != nil {
+		return nil, err
+	}
+	var pairs = make([][2]string, 0, len(desired))
+	for pair := range desired {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0] || (pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1])
+	})
+	var steps []declarationStep
+	for _, pair := range pairs {
+		pair, value := pair, desired[pair]
+		if container.IsAllowed(acls.AclID(pair[0]), acls.AclID(pair[1])) == (value == acls.Allowed) {
+			continue
+		}
+		before := map[string]bool{"allowed": value != acls.Allowed}
+		after := map[string]bool{"allowed": value == acls.Allowed}
+		steps = append(steps, newDeclarationStep(models.AUDIT_UPDATE, models.DECLARED_ACL, netid, pair[0]+":"+pair[1], before, after, func() error {
+			var container acls.ACLContainer
+			container, err := container.Get(acls.ContainerID(netid))
+			if err != nil {
+				return err
+			}
+			if container[acls.AclID(pair[0])] == nil || container[acls.AclID(pair[1])] == nil {
+				return errors.New("nodes " + pair[0] + " and " + pair[1] + " have no ACL")
+			}
+			container.ChangeAccess(acls.AclID(pair[0]), acls.AclID(pair[1]), value)
+			_, err = container.Save(acls.ContainerID(netid))
+			return err
+		}))
+	}
+	return steps, nil
+}
+
+// SetACLRules - allows or denies nodes of a network reaching each other, with each side of a rule given
+// as a node id or a label selector as in a declaration, later rules override earlier ones
+func SetACLRules(netid string, rules []models.DeclaredACL) (acls.ACLContainer, error) {
+	nodes, err := GetNetworkNodes(netid)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	var nodesByID = make(map[string]models.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByID[node.ID] = node
+	}
+	resolved, err := resolveSelectors(&models.DeclaredNetwork{ACLs: rules}, nodes)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := declaredACLValues(netid, resolved.ACLs, nodesByID)
+	if err != nil {
+		return nil, err
+	}
+	var container acls.ACLContainer
+	if container, err = container.Get(acls.ContainerID(netid)); err != nil {
+		return nil, err
+	}
+	for pair, value := range desired {
+		if container[acls.AclID(pair[0])] == nil || container[acls.AclID(pair[1])] == nil {
+			return nil, errors.New("nodes " + pair[0] + " and " + pair[1] + " have no ACL")
+		}
+		container.ChangeAccess(acls.AclID(pair[0]), acls.AclID(pair[1]), value)
+	}
+	return container.Save(acls.ContainerID(netid))
+}
+
+// declaredACLValues - the access declared ACL rules give each pair of nodes, rules must name two different nodes of the network
+func declaredACLValues(netid string, declared []models.DeclaredACL, nodesByID map[string]models.Node) (map[[2]string]byte, error) {
+	var desired = make(map[[2]string]byte)
+	for _, rule := range declared {
+		if len(rule.Nodes) != 2 || rule.Nodes[0] == rule.Nodes[1] {
+			return nil, errors.New("every declared acl needs two different nodes")
+		}
+		for _, nodeid := range rule.Nodes {
+			if _, err := declaredNode(netid, nodeid, nodesByID); err != nil {
+				return nil, err
+			}
+		}
+		value := acls.NotAllowed
+		if rule.Allowed {
+			value = acls.Allowed
+		}
+		desired[aclPair(rule.Nodes[0], rule.Nodes[1])] = value
+	}
+	return desired, nil
+}
+
+// applyNetworkSettings - updates a network with declared settings as the network update endpoint does
+func applyNetworkSettings(settings models.Network) error {
+	current, err := GetParentNetwork(settings.NetID)
+	if err != nil {
+		return err
+	}
+	merged := mergeNetworkSettings(current, settings)
+	rangeupdate, localrangeupdate, holepunchupdate, err := UpdateNetwork(&current, &merged)
+	if err != nil {
+		return err
+	}
+	if rangeupdate {
+		if err = UpdateNetworkNodeAddresses(merged.NetID); err != nil {
+			return err
+		}
+	}
+	if localrangeupdate {
+		if err = UpdateNetworkLocalAddresses(merged.NetID); err != nil {
+			return err
+		}
+	}
+	if holepunchupdate {
+		return UpdateNetworkHolePunching(merged.NetID, merged.DefaultUDPHolePunch)
+	}
+	return nil
+}
+
+// createDeclaredExtClient - creates an ext client as the ext client endpoint does
+func createDeclaredExtClient(netid string, declared models.DeclaredExtClient) error {
+	gateway, err := GetNodeByID(declared.IngressGatewayID)
+	if err != nil {
+		return err
+	}
+	network, err := GetNetwork(netid)
+	if err != nil {
+		return err
+	}
+	client := models.ExtClient{
+		ClientID:               declared.ClientID,
+		Network:                netid,
+		IngressGatewayID:       gateway.ID,
+		IngressGatewayEndpoint: gateway.Endpoint + ":" + strconv.FormatInt(int64(gateway.ListenPort), 10),
+		Enabled:                network.DefaultACL == "yes",
+	}
+	if declared.Enabled != nil {
+		client.Enabled = *declared.Enabled
+	}
+	return CreateExtClient(&client)
+}
+
+// mergeNetworkSettings - a network with the settings that are declared, those left out keep their current value
+func mergeNetworkSettings(current, declared models.Network) models.Network {
+	merged := current
+	from := reflect.ValueOf(declared)
+	to := reflect.ValueOf(&merged).Elem()
+	for i := 0; i < from.NumField(); i++ {
+		if undeclaredNetworkFields[from.Type().Field(i).Name] || from.Field(i).IsZero() {
+			continue
+		}
+		to.Field(i).Set(from.Field(i))
+	}
+	return merged
+}
+
+// networkSettings - the declarable settings of a network, for diffs
+func networkSettings(network models.Network) *models.Network {
+	network.NodesLastModified = 0
+	network.NetworkLastModified = 0
+	network.Revision = 0
+	network.AccessKeys = nil
+	return &network
+}
+
+func ingressSettings(node models.Node) map[string]string {
+	return map[string]string{"nodeid": node.ID, "name": node.Name}
+}
+
+func egressSettings(node models.Node) *models.EgressGatewayRequest {
+	return &models.EgressGatewayRequest{NodeID: node.ID, NetID: node.Network, Ranges: node.EgressGatewayRanges}
+}
+
+func relaySettings(node models.Node) *models.RelayRequest {
+	return &models.RelayRequest{NodeID: node.ID, NetID: node.Network, RelayAddrs: node.RelayAddrs}
+}
+
+// resolveSelectors - a copy of a declared network with the nodes matching its label selectors listed by id,
+// as ingress gateways, egress gateways, relays and relayed nodes, and as the pairs of nodes of its ACL rules
+func resolveSelectors(declared *models.DeclaredNetwork, nodes []models.Node) (*models.DeclaredNetwork, error) {
+	resolved := *declared
+	var err error
+	if resolved.Egress, err = resolveEgressSelectors(declared.Egress, nodes); err != nil {
+		return nil, err
+	}
+	if resolved.Relays, err = resolveRelaySelectors(declared.Relays, nodes); err != nil {
+		return nil, err
+	}
+	if declared.IngressSelectors != nil {
+		resolved.Ingress = append([]string{}, declared.Ingress...)
+		for _, selector := range declared.IngressSelectors {
+			matched, err := selectNodes(selector, nodes)
+			if err != nil {
+				return nil, err
+			}
+			for _, nodeid := range matched {
+				if !StringSliceContains(resolved.Ingress, nodeid) {
+					resolved.Ingress = append(resolved.Ingress, nodeid)
+				}
+			}
+		}
+	}
+	if declared.ACLs == nil {
+		return &resolved, nil
+	}
+	resolved.ACLs = make([]models.DeclaredACL, 0, len(declared.ACLs))
+	for _, rule := range declared.ACLs {
+		if len(rule.Selectors) == 0 {
+			resolved.ACLs = append(resolved.ACLs, rule)
+			continue
+		}
+		if len(rule.Nodes)+len(rule.Selectors) != 2 {
+			return nil, errors.New("every declared acl needs two sides, given as nodes or label selectors")
+		}
+		var sides [2][]string
+		for i, nodeid := range rule.Nodes {
+			sides[i] = []string{nodeid}
+		}
+		for i, selector := range rule.Selectors {
+			matched, err := selectNodes(selector, nodes)
+			if err != nil {
+				return nil, err
+			}
+			sides[len(rule.Nodes)+i] = matched
+		}
+		for _, a := range sides[0] {
+			for _, b := range sides[1] {
+				if a != b {
+					resolved.ACLs = append(resolved.ACLs, models.DeclaredACL{Nodes: []string{a, b}, Allowed: rule.Allowed})
+				}
+			}
+		}
+	}
+	return &resolved, nil
+}
+
+// resolveEgressSelectors - the declared egress gateways with one gateway for every node matching a selector
+func resolveEgressSelectors(declared []models.DeclaredEgress, nodes []models.Node) ([]models.DeclaredEgress, error) {
+	if declared == nil {
+		return nil, nil
+	}
+	var resolved = []models.DeclaredEgress{}
+	var declaredNodes = make(map[string]bool)
+	for _, gateway := range declared {
+		nodeids, err := declaredNodeIDs(gateway.NodeID, gateway.Selector, nodes)
+		if err != nil {
+			return nil, fmt.Errorf("egress gateway: %w", err)
+		}
+		for _, nodeid := range nodeids {
+			if declaredNodes[nodeid] {
+				return nil, fmt.Errorf("node %s is declared as an egress gateway twice", nodeid)
+			}
+			declaredNodes[nodeid] = true
+			gateway := gateway
+			gateway.NodeID, gateway.Selector = nodeid, ""
+			gateway.Ranges = append([]string{}, gateway.Ranges...)
+			resolved = append(resolved, gateway)
+		}
+	}
+	return resolved, nil
+}
+
+// resolveRelaySelectors - the declared relays with one relay for every node matching a selector,
+// relaying the addresses of the nodes matching its relayed selector other than itself
+func resolveRelaySelectors(declared []models.DeclaredRelay, nodes []models.Node) ([]models.DeclaredRelay, error) {
+	if declared == nil {
+		return nil, nil
+	}
+	var resolved = []models.DeclaredRelay{}
+	var declaredNodes = make(map[string]bool)
+	for _, relay := range declared {
+		nodeids, err := declaredNodeIDs(relay.NodeID, relay.Selector, nodes)
+		if err != nil {
+			return nil, fmt.Errorf("relay: %w", err)
+		}
+		var relayed []string
+		if relay.RelayedSelector != "" {
+			if relayed, err = selectNodes(relay.RelayedSelector, nodes); err != nil {
+				return nil, err
+			}
+		}
+		for _, nodeid := range nodeids {
+			if declaredNodes[nodeid] {
+				return nil, fmt.Errorf("node %s is declared as a relay twice", nodeid)
+			}
+			declaredNodes[nodeid] = true
+			relay := relay
+			relay.NodeID, relay.Selector, relay.RelayedSelector = nodeid, "", ""
+			relay.RelayAddrs = append([]string{}, relay.RelayAddrs...)
+			for i := range nodes {
+				if nodes[i].ID == nodeid || !StringSliceContains(relayed, nodes[i].ID) {
+					continue
+				}
+				for _, address := range []string{nodes[i].Address, nodes[i].Address6} {
+					if address != "" && !StringSliceContains(relay.RelayAddrs, address) {
+						relay.RelayAddrs = append(relay.RelayAddrs, address)
+					}
+				}
+			}
+			resolved = append(resolved, relay)
+		}
+	}
+	return resolved, nil
+}
+
+// declaredNodeIDs - the node given by id, or the nodes matching a label selector, exactly one of which must be given
+func declaredNodeIDs(nodeid, selector string, nodes []models.Node) ([]string, error) {
+	if (nodeid == "") == (selector == "") {
+		return nil, errors.New("give either a node id or a label selector")
+	}
+	if nodeid != "" {
+		return []string{nodeid}, nil
+	}
+	return selectNodes(selector, nodes)
+}
+
+// selectNodes - the ids of the nodes matching a label selector in id order, the selector cannot be empty
+func selectNodes(selector string, nodes []models.Node) ([]string, error) {
+	parsed, err := models.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("a label selector cannot be empty")
+	}
+	var matched []string
+	for i := range nodes {
+		if parsed.Matches(nodes[i].Labels) {
+			matched = append(matched, nodes[i].ID)
+		}
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+func declaredNode(netid, nodeid string, nodesByID map[string]models.Node) (models.Node, error) {
+	node, ok := nodesByID[nodeid]
+	if !ok {
+		return node, fmt.Errorf("node %s is not on network %s", nodeid, netid)
+	}
+	return node, nil
+}
+
+// aclPair - the ids of two nodes in a fixed order
+func aclPair(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// sameStrings - checks two lists hold the same values in any order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	return reflect.DeepEqual(sortedA, sortedB)
+}
+
+func newDeclarationStep(action, kind, network, name string, before, after interface{}, apply func() error) declarationStep {
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		logger.Log(1, "failed to diff", kind, name, "of declaration:", err.Error())
+	}
+	return declarationStep{
+		change: models.DeclarationChange{Action: action, Kind: kind, Network: network, Name: name, Changes: changes},
+		before: before,
+		after:  after,
+		apply:  apply,
+	}
+}
@@ -0,0 +1,193 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+)
+
+const testDeclaration = `
+networks:
+  - netid: declared
+    addressrange: 10.99.0.0/24
+    defaultkeepalive: 25
+    accesskeys:
+      - name: ci
+        uses: 5
+    dns:
+      - name: db
+        address: 10.99.0.50
+`
+
+func applyTestDeclaration(t *testing.T, document string, dryRun, prune bool) models.DeclarationResult {
+	declaration, err := ParseDeclaration([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ApplyDeclaration(declaration, dryRun, prune, models.AuditEntry{ActorType: models.AUDIT_ACTOR_SERVER, Actor: "test", Source: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestApplyDeclaration(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+
+	t.Run("Parse", func(t *testing.T) {
+		if _, err := ParseDeclaration([]byte("networks:\n  - netid: x\n    adressrange: 10.0.0.0/24\n")); err == nil {
+			t.Fatal("expected a misspelled field to be refused")
+		}
+		declaration, err := ParseDeclaration([]byte(`{"networks": [{"netid": "json", "defaultacl": "no", "acls": []}]}`))
+		if err != nil || declaration.Networks[0].DefaultACL != "no" || declaration.Networks[0].ACLs == nil || declaration.Networks[0].DNS != nil {
+			t.Fatalf("expected JSON to parse keeping given and left out lists apart, got %+v %v", declaration, err)
+		}
+	})
+	t.Run("DryRun", func(t *testing.T) {
+		result := applyTestDeclaration(t, testDeclaration, true, false)
+		if len(result.Changes) != 3 || result.Applied != 0 {
+			t.Fatalf("expected a network, key and dns entry to be planned, got %+v", result)
+		}
+		if _, err := GetNetwork("declared"); err == nil {
+			t.Fatal("expected a dry run to change nothing")
+		}
+	})
+	t.Run("Apply", func(t *testing.T) {
+		result := applyTestDeclaration(t, testDeclaration, false, false)
+		if result.Applied != 3 {
+			t.Fatalf("expected 3 changes to be applied, got %+v", result)
+		}
+		network, err := GetNetwork("declared")
+		if err != nil || network.DefaultKeepalive != 25 || len(network.AccessKeys) != 1 || network.AccessKeys[0].Uses != 5 {
+			t.Fatalf("expected the declared network and key, got %+v %v", network, err)
+		}
+		if entries, _ := GetCustomDNS("declared"); len(entries) != 1 || entries[0].Address != "10.99.0.50" {
+			t.Fatalf("expected the declared dns entry, got %+v", entries)
+		}
+		if again := applyTestDeclaration(t, testDeclaration, true, false); len(again.Changes) != 0 {
+			t.Fatalf("expected an applied declaration to need no changes, got %+v", again.Changes)
+		}
+	})
+	t.Run("Update", func(t *testing.T) {
+		result := applyTestDeclaration(t, "networks:\n  - netid: declared\n    defaultkeepalive: 30\n", false, false)
+		if len(result.Changes) != 1 || result.Changes[0].Action != models.AUDIT_UPDATE || len(result.Changes[0].Changes) != 1 {
+			t.Fatalf("expected only the keepalive to change, got %+v", result.Changes)
+		}
+		if network, _ := GetNetwork("declared"); network.DefaultKeepalive != 30 || network.AddressRange != "10.99.0.0/24" {
+			t.Fatalf("expected settings left out to be kept, got %+v", network)
+		}
+	})
+	t.Run("GatewaysAndACLs", func(t *testing.T) {
+		for _, node := range []models.Node{
+			{ID: "gateway", Name: "gateway", Network: "declared", Address: "10.99.0.1", Endpoint: "192.0.2.1", ListenPort: 51821, IsIngressGateway: "yes"},
+			{ID: "peer", Name: "peer", Network: "declared", Address: "10.99.0.2"},
+		} {
+			data, _ := json.Marshal(&node)
+			database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+			if _, err := nodeacls.CreateNodeACL(nodeacls.NetworkID("declared"), nodeacls.NodeID(node.ID), acls.Allowed); err != nil {
+				t.Fatal(err)
+			}
+		}
+		document := "networks:\n  - netid: declared\n    extclients:\n      - clientid: phone\n        ingressgatewayid: gateway\n" +
+			"    acls:\n      - nodes: [peer, gateway]\n        allowed: false\n"
+		result := applyTestDeclaration(t, document, false, false)
+		if result.Applied != 2 {
+			t.Fatalf("expected an ext client and an acl change, got %+v", result)
+		}
+		if client, err := GetExtClient("phone", "declared"); err != nil || client.IngressGatewayEndpoint != "192.0.2.1:51821" || client.Address == "" {
+			t.Fatalf("expected the declared ext client, got %+v %v", client, err)
+		}
+		if nodeacls.AreNodesAllowed(nodeacls.NetworkID("declared"), nodeacls.NodeID("peer"), nodeacls.NodeID("gateway")) {
+			t.Fatal("expected the declared nodes to be kept apart")
+		}
+		declaration, _ := ParseDeclaration([]byte("networks:\n  - netid: declared\n    ingress: [missing]\n"))
+		if _, err := ApplyDeclaration(declaration, true, false, models.AuditEntry{}); err == nil {
+			t.Fatal("expected a node that is not on the network to be refused")
+		}
+	})
+	t.Run("Selectors", func(t *testing.T) {
+		for _, node := range []models.Node{
+			{ID: "db", Name: "db", Network: "declared", Address: "10.99.0.3", Labels: models.Labels{"role": "db"}},
+			{ID: "web", Name: "web", Network: "declared", Address: "10.99.0.4", Labels: models.Labels{"role": "web", "site": "office-a"}},
+		} {
+			data, _ := json.Marshal(&node)
+			database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+			if _, err := nodeacls.CreateNodeACL(nodeacls.NetworkID("declared"), nodeacls.NodeID(node.ID), acls.Allowed); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result := applyTestDeclaration(t, "networks:\n  - netid: declared\n    ingressselectors: [site=office-a]\n", true, false)
+		if len(result.Changes) != 1 || result.Changes[0].Kind != models.DECLARED_INGRESS || result.Changes[0].Name != "web" {
+			t.Fatalf("expected the node matching the selector to be made an ingress gateway, got %+v", result.Changes)
+		}
+		result = applyTestDeclaration(t, "networks:\n  - netid: declared\n    acls:\n      - nodes: [peer]\n        selectors: [role]\n        allowed: false\n", false, false)
+		if result.Applied != 2 || nodeacls.AreNodesAllowed(nodeacls.NetworkID("declared"), nodeacls.NodeID("peer"), nodeacls.NodeID("web")) {
+			t.Fatalf("expected the node to be kept apart from every node with a role, got %+v", result)
+		}
+		result = applyTestDeclaration(t, "networks:\n  - netid: declared\n    egress:\n      - selector: role\n        interface: eth0\n        ranges: [172.20.0.0/24]\n"+
+			"    relays:\n      - selector: site=office-a\n        relayedselector: role\n", true, false)
+		var planned = map[string]models.DeclarationChange{}
+		for _, change := range result.Changes {
+			planned[change.Kind+" "+change.Name] = change
+		}
+		if len(result.Changes) != 3 || planned["egress db"].Kind == "" || planned["egress web"].Kind == "" || planned["relay web"].Kind == "" {
+			t.Fatalf("expected the nodes matching the selectors to be made egress gateways and a relay, got %+v", result.Changes)
+		}
+		if relayed := fmt.Sprint(planned["relay web"].Changes); !strings.Contains(relayed, "10.99.0.3") || strings.Contains(relayed, "10.99.0.4") {
+			t.Fatalf("expected the relay to relay the other nodes matching its relayed selector, got %s", relayed)
+		}
+		if _, err := SetACLRules("declared", []models.DeclaredACL{{Selectors: []string{"role=db", "role=web"}, Allowed: false}}); err != nil {
+			t.Fatal(err)
+		}
+		if nodeacls.AreNodesAllowed(nodeacls.NetworkID("declared"), nodeacls.NodeID("db"), nodeacls.NodeID("web")) {
+			t.Fatal("expected the ACL rule given by selectors to keep the nodes apart")
+		}
+		for _, document := range []string{
+			"networks:\n  - netid: declared\n    ingressselectors: [\"\"]\n",
+			"networks:\n  - netid: declared\n    acls:\n      - nodes: [peer, web]\n        selectors: [role=db]\n",
+			"networks:\n  - netid: declared\n    egress:\n      - nodeid: db\n        selector: role\n",
+			"networks:\n  - netid: declared\n    egress:\n      - nodeid: db\n      - selector: role=db\n",
+		} {
+			declaration, _ := ParseDeclaration([]byte(document))
+			if _, err := ApplyDeclaration(declaration, true, false, models.AuditEntry{}); err == nil {
+				t.Fatalf("expected %q to be refused", document)
+			}
+		}
+	})
+	t.Run("Prune", func(t *testing.T) {
+		if _, err := CreateNetwork(models.Network{NetID: "unmanaged", AddressRange: "10.98.0.0/24"}); err != nil {
+			t.Fatal(err)
+		}
+		result := applyTestDeclaration(t, "networks:\n  - netid: declared\n    accesskeys: []\n", false, true)
+		var deleted = map[string]bool{}
+		for _, change := range result.Changes {
+			if change.Action == models.AUDIT_DELETE {
+				deleted[change.Kind+" "+change.Name] = true
+			}
+		}
+		if len(deleted) != 2 || !deleted["accesskey ci"] || !deleted["network unmanaged"] {
+			t.Fatalf("expected only the key and the undeclared network to be removed, got %+v", result.Changes)
+		}
+		if _, err := GetNetwork("unmanaged"); err == nil {
+			t.Fatal("expected the undeclared network to be removed")
+		}
+		if entries, _ := GetCustomDNS("declared"); len(entries) != 1 {
+			t.Fatalf("expected dns left out of the declaration to be kept, got %+v", entries)
+		}
+		if audit, _, _ := GetAuditEntries(AuditQuery{Actor: "test", TargetType: models.DECLARED_NETWORK, Action: models.AUDIT_DELETE}); len(audit) != 1 {
+			t.Fatalf("expected the removal to be audited, got %+v", audit)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// DELETED_NODE_REAP_INTERVAL - how often expired deleted nodes are purged
+const DELETED_NODE_REAP_INTERVAL = time.Hour
+
+// deletedNode - tombstone of a deleted node, keeping its ACL so it can be restored
+type deletedNode struct {
+	models.Node
+	ACL acls.ACL `json:"acl,omitempty"`
+}
+
+// GetDeletedNodes - gets the deleted nodes kept for restoring, of every network if network is empty
+func GetDeletedNodes(network string) ([]models.Node, error) {
+	var nodes = []models.Node{}
+	tombstones, err := fetchDeletedNodes()
+	if err != nil {
+		return nodes, err
+	}
+	for _, tombstone := range tombstones {
+		if network == "" || tombstone.Network == network {
+			nodes = append(nodes, tombstone.Node)
+		}
+	}
+	return nodes, nil
+}
+
+// PurgeDeletedNodes - removes deleted nodes that were deleted longer than retention ago
+func PurgeDeletedNodes(retention time.Duration) (int, error) {
+	tombstones, err := fetchDeletedNodes()
+	if err != nil {
+		return 0, err
+	}
+	expiry := time.Now().Add(-retention).Unix()
+	var purged int
+	for key, tombstone := range tombstones {
+		if tombstone.LastModified >= expiry {
+			continue
+		}
+		if err = database.DeleteRecord(database.DELETED_NODES_TABLE_NAME, key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// ReapDeletedNodes - purges expired deleted nodes every DELETED_NODE_REAP_INTERVAL until ctx is done
+func ReapDeletedNodes(ctx context.Context) {
+	for {
+		retention := time.Duration(servercfg.GetDeletedNodeRetention()) * time.Hour
+		if purged, err := PurgeDeletedNodes(retention); err != nil {
+			logger.Log(1, "error purging deleted nodes:", err.Error())
+		} else if purged > 0 {
+			logger.Log(1, "purged", fmt.Sprint(purged), "deleted nodes older than", retention.String())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(DELETED_NODE_REAP_INTERVAL):
+		}
+	}
+}
+
+// RestoreDeletedNode - re-inserts a deleted node with its previous addresses and ACL
+// fails if its network is gone or its addresses were taken since it was deleted
+func RestoreDeletedNode(nodeid string) (models.Node, error) {
+	record, err := database.FetchRecord(database.DELETED_NODES_TABLE_NAME, nodeid)
+	if err != nil {
+		return models.Node{}, err
+	}
+	var tombstone deletedNode
+	if err = json.Unmarshal([]byte(record), &tombstone); err != nil {
+		return models.Node{}, err
+	}
+	node := tombstone.Node
+	if node.IsServer == "yes" {
+		return models.Node{}, fmt.Errorf("node %s is a server node and cannot be restored", node.ID)
+	}
+	if _, err = GetNodeByID(node.ID); err == nil {
+		return models.Node{}, fmt.Errorf("node %s already exists", node.ID)
+	}
+	network, err := GetParentNetwork(node.Network)
+	if err != nil {
+		return models.Node{}, fmt.Errorf("network %s of node %s no longer exists", node.Network, node.ID)
+	}
+	held, err := holdRestoredAddresses(&network, &node)
+	if err != nil {
+		return models.Node{}, err
+	}
+	defer func() {
+		if err != nil {
+			for _, address := range held {
+				addresses.unhold(network.NetID, address)
+			}
+		}
+	}()
+	defaultACLVal := acls.Allowed
+	if network.DefaultACL != "yes" {
+		defaultACLVal = acls.NotAllowed
+	}
+	node.Action = models.NODE_NOOP
+	node.SetLastModified()
+	node.Revision++
+	data, err := json.Marshal(&node)
+	if err != nil {
+		return models.Node{}, err
+	}
+	err = database.Transaction(func(tx *database.Tx) error {
+		if err := tx.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			return err
+		}
+		if err := tx.DeleteRecord(database.DELETED_NODES_TABLE_NAME, node.ID); err != nil {
+			return err
+		}
+		if _, err := nodeacls.RestoreNodeACLTx(tx, nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID), tombstone.ACL, defaultACLVal); err != nil {
+			return err
+		}
+		return setNetworkNodesLastModified(tx, node.Network)
+	})
+	if err != nil {
+		return models.Node{}, err
+	}
+	if servercfg.IsDNSMode() {
+		SetDNS()
+	}
+	return node, nil
+}
+
+// holdRestoredAddresses - ensures the addresses of a deleted node are still in range, unused and not reserved
+// for another node, and holds them back until it is restored, returns the addresses held
+func holdRestoredAddresses(network *models.Network, node *models.Node) ([]string, error) {
+	var held []string
+	for _, address := range []string{node.Address, node.Address6} {
+		if address == "" {
+			continue
+		}
+		err := checkRestoredAddress(network, node, address)
+		if err == nil {
+			var free bool
+			if free, err = addresses.hold(network.NetID, address); err == nil && !free {
+				err = fmt.Errorf("address %s of node %s is already in use", address, node.ID)
+			}
+		}
+		if err != nil {
+			for _, address := range held {
+				addresses.unhold(network.NetID, address)
+			}
+			return nil, err
+		}
+		held = append(held, address)
+	}
+	return held, nil
+}
+
+// checkRestoredAddress - ensures an address of a deleted node is still in range and not reserved for another node
+func checkRestoredAddress(network *models.Network, node *models.Node, address string) error {
+	if !IsAddressInNetwork(address, network) {
+		return fmt.Errorf("address %s of node %s is no longer in the range of network %s", address, node.ID, network.NetID)
+	}
+	return checkReservedAddress(network.NetID, address, node.Name, node.MacAddress, node.ID)
+}
+
+// fetchDeletedNodes - fetches every tombstone, keyed by record key
+func fetchDeletedNodes() (map[string]deletedNode, error) {
+	records, err := database.FetchRecords(database.DELETED_NODES_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return map[string]deletedNode{}, nil
+		}
+		return nil, err
+	}
+	tombstones := make(map[string]deletedNode, len(records))
+	for key, value := range records {
+		var tombstone deletedNode
+		if err = json.Unmarshal([]byte(value), &tombstone); err != nil {
+			logger.Log(2, "skipping unreadable deleted node", key)
+			continue
+		}
+		tombstones[key] = tombstone
+	}
+	return tombstones, nil
+}
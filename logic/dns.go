@@ -3,6 +3,7 @@ package logic
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gravitl/netmaker/database"
@@ -47,6 +48,32 @@ func SetDNS() error {
 	return err
 }
 
+// CheckDNSConfigWritable - checks the CoreDNS config directory and its Corefile and hosts file can be written
+func CheckDNSConfigWritable() error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	dir = filepath.Join(dir, "config", "dnsconfig")
+	probe, err := os.CreateTemp(dir, ".writecheck")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	for _, name := range []string{"Corefile", "netmaker.hosts"} {
+		file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY, 0)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		file.Close()
+	}
+	return nil
+}
+
 // GetDNS - gets the DNS of a current network
 func GetDNS(network string) ([]models.DNSEntry, error) {
 
@@ -236,6 +263,22 @@ func ValidateDNSUpdate(change models.DNSEntry, entry models.DNSEntry) error {
 	return err
 }
 
+// CreateDNS - creates a DNS entry
+func CreateDNS(entry models.DNSEntry) (models.DNSEntry, error) {
+
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return models.DNSEntry{}, err
+	}
+	key, err := GetRecordKey(entry.Name, entry.Network)
+	if err != nil {
+		return models.DNSEntry{}, err
+	}
+	err = database.Insert(key, string(data), database.DNS_TABLE_NAME)
+
+	return entry, err
+}
+
 // DeleteDNS - deletes a DNS entry
 func DeleteDNS(domain string, network string) error {
 	key, err := GetRecordKey(domain, network)
@@ -0,0 +1,320 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// event types
+const (
+	NODE_CREATED      = "node.created"
+	NODE_UPDATED      = "node.updated"
+	NODE_DELETED      = "node.deleted"
+	NODE_CHECKIN      = "node.checkin"
+	EXTCLIENT_CREATED = "extclient.created"
+	EXTCLIENT_UPDATED = "extclient.updated"
+	EXTCLIENT_DELETED = "extclient.deleted"
+	ACL_CREATED       = "acl.created"
+	ACL_UPDATED       = "acl.updated"
+	ACL_DELETED       = "acl.deleted"
+	DNS_CREATED       = "dns.created"
+	DNS_UPDATED       = "dns.updated"
+	DNS_DELETED       = "dns.deleted"
+	// STREAM_RESET - sent when a stream cannot be resumed, clients should reload their state
+	STREAM_RESET = "stream.reset"
+)
+
+// HISTORY_SIZE - events kept for clients resuming a stream
+const HISTORY_SIZE = 1024
+
+// SUBSCRIBER_BUFFER_SIZE - events queued for a client before it is disconnected as too slow
+const SUBSCRIBER_BUFFER_SIZE = 256
+
+// tableKinds - prefix of the events of each streamed table
+var tableKinds = map[string]string{
+	database.NODES_TABLE_NAME:      "node",
+	database.EXT_CLIENT_TABLE_NAME: "extclient",
+	database.NODE_ACLS_TABLE_NAME:  "acl",
+	database.DNS_TABLE_NAME:        "dns",
+}
+
+// volatileNodeFields - node fields changed by every check in, which is streamed as its own event
+var volatileNodeFields = []string{"lastcheckin", "lastmodified", "revision"}
+
+// record - last streamed state of a record, to tell creates from updates and skip unchanged writes
+type record struct {
+	hash    [sha256.Size]byte
+	network string
+}
+
+// Subscription - stream of the events of a set of networks
+type Subscription struct {
+	Events   chan models.Event
+	all      bool
+	networks map[string]bool
+	closed   bool
+}
+
+var (
+	mutex       sync.Mutex
+	hookOnce    sync.Once
+	started     bool
+	epoch       = strconv.FormatInt(time.Now().Unix(), 36)
+	sequence    uint64
+	history     []models.Event
+	subscribers = map[*Subscription]bool{}
+	records     = map[string]record{}
+)
+
+// Start - loads the records of the streamed tables and starts turning writes into events
+func Start() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	loaded := map[string]record{}
+	for table, kind := range tableKinds {
+		values, err := database.FetchRecords(table)
+		if err != nil && !database.IsEmptyRecord(err) {
+			return err
+		}
+		for key, value := range values {
+			if _, rec, ok := decode(table, kind, key, value); ok {
+				loaded[table+"/"+key] = rec
+			}
+		}
+	}
+	records = loaded
+	started = true
+	hookOnce.Do(func() {
+		database.AddWriteHook(applyWrites)
+	})
+	return nil
+}
+
+// Publish - streams an event not caused by a write to a streamed table
+func Publish(eventType string, network string, data interface{}) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if started {
+		publish(eventType, network, data)
+	}
+}
+
+// Subscribe - subscribes to the events of networks, or every network if all is set
+// events after lastEventID are replayed, resumed is false if they are no longer kept
+func Subscribe(all bool, networks []string, lastEventID string) (subscription *Subscription, replay []models.Event, resumed bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	subscription = &Subscription{
+		Events:   make(chan models.Event, SUBSCRIBER_BUFFER_SIZE),
+		all:      all,
+		networks: make(map[string]bool, len(networks)),
+	}
+	for _, network := range networks {
+		subscription.networks[network] = true
+	}
+	subscribers[subscription] = true
+	resumed = true
+	if lastEventID != "" {
+		resumed = false
+		if last, ok := parseID(lastEventID); ok && last <= sequence && (len(history) == 0 || last+1 >= eventSequence(history[0])) {
+			resumed = true
+			for _, event := range history {
+				if eventSequence(event) > last && subscription.matches(&event) {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+	return subscription, replay, resumed
+}
+
+// Subscription.Close - stops streaming events to the subscription
+func (s *Subscription) Close() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	s.close()
+}
+
+func (s *Subscription) close() {
+	if !s.closed {
+		s.closed = true
+		delete(subscribers, s)
+		close(s.Events)
+	}
+}
+
+// Subscription.String - describes the subscription for logging
+func (s *Subscription) String() string {
+	if s.all {
+		return "all networks"
+	}
+	networks := make([]string, 0, len(s.networks))
+	for network := range s.networks {
+		networks = append(networks, network)
+	}
+	return fmt.Sprint(networks)
+}
+
+func (s *Subscription) matches(event *models.Event) bool {
+	return s.all || s.networks[event.Network]
+}
+
+// publish - records an event and sends it to the matching subscribers, caller must hold mutex
+// a subscriber that is not keeping up is disconnected and can resume from the history
+func publish(eventType string, network string, data interface{}) {
+	sequence++
+	event := models.Event{
+		ID:        epoch + "-" + strconv.FormatUint(sequence, 10),
+		Type:      eventType,
+		Network:   network,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}
+	if len(history) >= HISTORY_SIZE {
+		history = append(history[:0], history[1:]...)
+	}
+	history = append(history, event)
+	for subscription := range subscribers {
+		if !subscription.matches(&event) {
+			continue
+		}
+		select {
+		case subscription.Events <- event:
+		default:
+			logger.Log(1, "disconnecting event stream that fell behind")
+			subscription.close()
+		}
+	}
+}
+
+// applyWrites - turns writes to the streamed tables into created, updated and deleted events
+func applyWrites(ops []database.TxOp) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !started {
+		return
+	}
+	for _, op := range ops {
+		kind, ok := tableKinds[op.Table]
+		if !ok {
+			continue
+		}
+		switch {
+		case op.Delete && op.Key == "":
+			for key, rec := range records {
+				if strings.HasPrefix(key, op.Table+"/") {
+					delete(records, key)
+					publish(kind+".deleted", rec.network, deletedData(kind, strings.TrimPrefix(key, op.Table+"/"), rec.network))
+				}
+			}
+		case op.Delete:
+			key := op.Table + "/" + op.Key
+			if rec, ok := records[key]; ok {
+				delete(records, key)
+				publish(kind+".deleted", rec.network, deletedData(kind, op.Key, rec.network))
+			}
+		default:
+			data, rec, ok := decode(op.Table, kind, op.Key, op.Value)
+			if !ok {
+				continue
+			}
+			key := op.Table + "/" + op.Key
+			previous, exists := records[key]
+			records[key] = rec
+			if !exists {
+				publish(kind+".created", rec.network, data)
+			} else if previous.hash != rec.hash {
+				publish(kind+".updated", rec.network, data)
+			}
+		}
+	}
+}
+
+// decode - decodes a record into the data streamed for it, leaving out secrets
+func decode(table string, kind string, key string, value string) (interface{}, record, bool) {
+	var rec record
+	var data interface{}
+	switch table {
+	case database.NODES_TABLE_NAME:
+		var node models.Node
+		if err := json.Unmarshal([]byte(value), &node); err != nil {
+			return nil, rec, false
+		}
+		node.Password = ""
+		rec.network, data = node.Network, node
+	case database.EXT_CLIENT_TABLE_NAME:
+		var extclient models.ExtClient
+		if err := json.Unmarshal([]byte(value), &extclient); err != nil {
+			return nil, rec, false
+		}
+		extclient.PrivateKey = ""
+		rec.network, data = extclient.Network, extclient
+	case database.DNS_TABLE_NAME:
+		var entry models.DNSEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, rec, false
+		}
+		rec.network, data = entry.Network, entry
+	case database.NODE_ACLS_TABLE_NAME:
+		var acl map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &acl); err != nil {
+			return nil, rec, false
+		}
+		rec.network, data = key, acl
+	}
+	rec.hash = fingerprint(kind, value)
+	return data, rec, true
+}
+
+// fingerprint - hashes a record, ignoring the node fields every check in changes
+func fingerprint(kind string, value string) [sha256.Size]byte {
+	if kind == "node" {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err == nil {
+			for _, field := range volatileNodeFields {
+				delete(fields, field)
+			}
+			if stable, err := json.Marshal(fields); err == nil {
+				value = string(stable)
+			}
+		}
+	}
+	return sha256.Sum256([]byte(value))
+}
+
+// deletedData - identifies a deleted record
+func deletedData(kind string, key string, network string) interface{} {
+	switch kind {
+	case "node":
+		return map[string]string{"id": key, "network": network}
+	case "extclient":
+		return map[string]string{"clientid": strings.TrimSuffix(key, "###"+network), "network": network}
+	case "dns":
+		return map[string]string{"name": strings.TrimSuffix(key, "###"+network), "network": network}
+	}
+	return map[string]string{"network": network}
+}
+
+// parseID - parses the sequence of an event id issued since this server started
+func parseID(id string) (uint64, bool) {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 || parts[0] != epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	return seq, err == nil
+}
+
+func eventSequence(event models.Event) uint64 {
+	seq, _ := parseID(event.ID)
+	return seq
+}
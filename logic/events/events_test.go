@@ -0,0 +1,77 @@
+package events
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func insertNode(t *testing.T, node models.Node) {
+	data, err := json.Marshal(&node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = database.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func nextEvent(t *testing.T, subscription *Subscription) models.Event {
+	select {
+	case event := <-subscription.Events:
+		return event
+	default:
+		t.Fatal("expected an event")
+	}
+	return models.Event{}
+}
+
+func TestEvents(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	insertNode(t, models.Node{ID: "existing", Network: "net1"})
+	if err := Start(); err != nil {
+		t.Fatal(err)
+	}
+	subscription, _, _ := Subscribe(false, []string{"net1"}, "")
+	defer subscription.Close()
+
+	insertNode(t, models.Node{ID: "node1", Network: "net1", Password: "secret"})
+	created := nextEvent(t, subscription)
+	if created.Type != NODE_CREATED || created.Data.(models.Node).Password != "" {
+		t.Fatalf("expected node created without its password, got %+v", created)
+	}
+	insertNode(t, models.Node{ID: "node1", Network: "net1", Password: "secret", LastCheckIn: 100})
+	insertNode(t, models.Node{ID: "existing", Network: "net1", Name: "renamed"})
+	if updated := nextEvent(t, subscription); updated.Type != NODE_UPDATED || updated.Data.(models.Node).ID != "existing" {
+		t.Fatalf("expected check in to be skipped and rename streamed, got %+v", updated)
+	}
+	insertNode(t, models.Node{ID: "other", Network: "net2"})
+	if err := database.DeleteRecord(database.NODES_TABLE_NAME, "node1"); err != nil {
+		t.Fatal(err)
+	}
+	deleted := nextEvent(t, subscription)
+	if deleted.Type != NODE_DELETED || deleted.Network != "net1" {
+		t.Fatalf("expected node1 deleted and net2 filtered out, got %+v", deleted)
+	}
+
+	t.Run("Resume", func(t *testing.T) {
+		resumedSubscription, replay, resumed := Subscribe(false, []string{"net1"}, created.ID)
+		defer resumedSubscription.Close()
+		if !resumed || len(replay) != 2 || replay[1].ID != deleted.ID {
+			t.Fatalf("expected the 2 net1 events after %s, got %v %+v", created.ID, resumed, replay)
+		}
+		staleSubscription, replay, resumed := Subscribe(true, nil, "stale-1")
+		defer staleSubscription.Close()
+		if resumed || len(replay) != 0 {
+			t.Fatal("expected an id from another server run not to resume")
+		}
+	})
+}
@@ -80,6 +80,14 @@ func DeleteExtClient(network string, clientid string) error {
 // GetNetworkExtClients - gets the ext clients of given network
 func GetNetworkExtClients(network string) ([]models.ExtClient, error) {
 	var extclients []models.ExtClient
+	if cached, ok := cachedExtClients(); ok {
+		for _, extclient := range cached {
+			if extclient.Network == network {
+				extclients = append(extclients, extclient)
+			}
+		}
+		return extclients, nil
+	}
 
 	records, err := database.FetchRecords(database.EXT_CLIENT_TABLE_NAME)
 	if err != nil {
@@ -105,6 +113,9 @@ func GetExtClient(clientid string, network string) (models.ExtClient, error) {
 	if err != nil {
 		return extclient, err
 	}
+	if extclient, ok := cachedExtClient(key); ok {
+		return extclient, nil
+	}
 	data, err := database.FetchRecord(database.EXT_CLIENT_TABLE_NAME, key)
 	if err != nil {
 		return extclient, err
@@ -133,22 +144,26 @@ func CreateExtClient(extclient *models.ExtClient) error {
 
 	if extclient.Address == "" {
 		if parentNetwork.IsIPv4 == "yes" {
-			newAddress, err := UniqueAddress(extclient.Network, false)
+			newAddress, err := allocateAddress(extclient.Network, extclient.ClientID, "", "", false, false)
 			if err != nil {
 				return err
 			}
 			extclient.Address = newAddress
 		}
+	} else if err = checkReservedAddress(extclient.Network, extclient.Address, extclient.ClientID, "", ""); err != nil {
+		return err
 	}
 
 	if extclient.Address6 == "" {
 		if parentNetwork.IsIPv6 == "yes" {
-			addr6, err := UniqueAddress6(extclient.Network, false)
+			addr6, err := allocateAddress(extclient.Network, extclient.ClientID, "", "", false, true)
 			if err != nil {
 				return err
 			}
 			extclient.Address6 = addr6
 		}
+	} else if err = checkReservedAddress(extclient.Network, extclient.Address6, extclient.ClientID, "", ""); err != nil {
+		return err
 	}
 
 	if extclient.ClientID == "" {
@@ -48,6 +48,7 @@ func CreateEgressGateway(gateway models.EgressGatewayRequest) (models.Node, erro
 	node.PostUp = postUpCmd
 	node.PostDown = postDownCmd
 	node.SetLastModified()
+	node.Revision++
 	nodeData, err := json.Marshal(&node)
 	if err != nil {
 		return node, err
@@ -73,7 +74,14 @@ func ValidateEgressGateway(gateway models.EgressGatewayRequest) error {
 	if empty {
 		err = errors.New("interface cannot be empty")
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	node := models.Node{ID: gateway.NodeID, Network: gateway.NetID, IsEgressGateway: "yes", EgressGatewayRanges: gateway.Ranges}
+	if current, err := GetNodeByID(gateway.NodeID); err == nil {
+		node.Name, node.Network = current.Name, current.Network
+	}
+	return checkRangeConflicts(nodeRangeUses(&node), "")
 }
 
 // DeleteEgressGateway - deletes egress from node
@@ -94,6 +102,7 @@ func DeleteEgressGateway(network, nodeid string) (models.Node, error) {
 	}
 	node.SetLastModified()
 
+	node.Revision++
 	data, err := json.Marshal(&node)
 	if err != nil {
 		return models.Node{}, err
@@ -142,16 +151,21 @@ func CreateIngressGateway(netid string, nodeid string) (models.Node, error) {
 	node.PostDown = postDownCmd
 	node.UDPHolePunch = "no"
 
+	node.Revision++
 	data, err := json.Marshal(&node)
 	if err != nil {
 		return models.Node{}, err
 	}
-	err = database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+	err = database.Transaction(func(tx *database.Tx) error {
+		if err := tx.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			return err
+		}
+		return setNetworkNodesLastModified(tx, netid)
+	})
 	if err != nil {
 		return models.Node{}, err
 	}
-	err = SetNetworkNodesLastModified(netid)
-	return node, err
+	return node, nil
 }
 
 // DeleteIngressGateway - deletes an ingress gateway
@@ -165,37 +179,51 @@ func DeleteIngressGateway(networkName string, nodeid string) (models.Node, error
 	if err != nil {
 		return models.Node{}, err
 	}
-	// delete ext clients belonging to ingress gateway
-	if err = DeleteGatewayExtClients(node.ID, networkName); err != nil {
-		return models.Node{}, err
-	}
 
 	node.UDPHolePunch = network.DefaultUDPHolePunch
 	node.LastModified = time.Now().Unix()
 	node.IsIngressGateway = "no"
 	node.IngressGatewayRange = ""
 
+	node.Revision++
 	data, err := json.Marshal(&node)
 	if err != nil {
 		return models.Node{}, err
 	}
-	err = database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+	err = database.Transaction(func(tx *database.Tx) error {
+		// delete ext clients belonging to ingress gateway
+		if err := deleteGatewayExtClients(tx, node.ID, networkName); err != nil {
+			return err
+		}
+		if err := tx.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+			return err
+		}
+		return setNetworkNodesLastModified(tx, networkName)
+	})
 	if err != nil {
 		return models.Node{}, err
 	}
-	err = SetNetworkNodesLastModified(networkName)
-	return node, err
+	return node, nil
 }
 
 // DeleteGatewayExtClients - deletes ext clients based on gateway (mac) of ingress node and network
 func DeleteGatewayExtClients(gatewayID string, networkName string) error {
+	return deleteGatewayExtClients(nil, gatewayID, networkName)
+}
+
+// deleteGatewayExtClients - deletes ext clients of an ingress node as part of a transaction
+func deleteGatewayExtClients(tx *database.Tx, gatewayID string, networkName string) error {
 	currentExtClients, err := GetNetworkExtClients(networkName)
 	if err != nil && !database.IsEmptyRecord(err) {
 		return err
 	}
 	for _, extClient := range currentExtClients {
 		if extClient.IngressGatewayID == gatewayID {
-			if err = DeleteExtClient(networkName, extClient.ClientID); err != nil {
+			key, err := GetRecordKey(extClient.ClientID, networkName)
+			if err == nil {
+				err = tx.DeleteRecord(database.EXT_CLIENT_TABLE_NAME, key)
+			}
+			if err != nil {
 				logger.Log(1, "failed to remove ext client", extClient.ClientID)
 				continue
 			}
@@ -0,0 +1,327 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// addressHolder - a node or ext client holding an address, with what reservations are matched against
+type addressHolder struct {
+	models.IPAMAddress
+	macaddress string
+}
+
+// GetReservations - the IP reservations of a network, ordered by address
+func GetReservations(netid string) ([]models.IPReservation, error) {
+	var reservations = []models.IPReservation{}
+	records, err := database.FetchRecords(database.RESERVATIONS_TABLE_NAME)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return reservations, nil
+		}
+		return reservations, err
+	}
+	for _, value := range records {
+		var reservation models.IPReservation
+		if err := json.Unmarshal([]byte(value), &reservation); err != nil {
+			continue
+		}
+		if reservation.Network == netid {
+			reservations = append(reservations, reservation)
+		}
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return compareAddresses(reservations[i].Address, reservations[j].Address) < 0
+	})
+	return reservations, nil
+}
+
+// CreateReservation - reserves an address, or a range of them, of a network
+// an address held by a node or ext client can only be reserved for it
+func CreateReservation(reservation models.IPReservation) (models.IPReservation, error) {
+	network, err := GetParentNetwork(reservation.Network)
+	if err != nil {
+		return reservation, err
+	}
+	if err = validator.New().Struct(reservation); err != nil {
+		return reservation, err
+	}
+	if reservation.EndAddress == reservation.Address {
+		reservation.EndAddress = ""
+	}
+	if reservation.HasOwner() && reservation.EndAddress != "" {
+		return reservation, errors.New("an address reserved for a hostname, MAC address or node ID cannot be a range")
+	}
+	addressRange := addressRangeOf(reservation.Address, append(network.AddressRanges(), network.AddressRanges6()...))
+	if addressRange == "" {
+		return reservation, fmt.Errorf("address %s is not in the ranges of network %s", reservation.Address, network.NetID)
+	}
+	if reservation.EndAddress != "" {
+		if addressRangeOf(reservation.EndAddress, []string{addressRange}) == "" {
+			return reservation, fmt.Errorf("address %s is not in the range %s of %s", reservation.EndAddress, addressRange, reservation.Address)
+		}
+		if compareAddresses(reservation.Address, reservation.EndAddress) > 0 {
+			return reservation, errors.New("the end of a reserved range cannot come before its start")
+		}
+	}
+	reservations, err := GetReservations(network.NetID)
+	if err != nil {
+		return reservation, err
+	}
+	for i := range reservations {
+		if reservationsOverlap(&reservation, &reservations[i]) {
+			return reservation, fmt.Errorf("address %s is already reserved", reservations[i].Address)
+		}
+	}
+	holders, err := networkAddressHolders(network.NetID)
+	if err != nil {
+		return reservation, err
+	}
+	for _, holder := range holders {
+		if isReservedAddress(holder.Address, &reservation) && !reservation.IsFor(holder.Name, holder.macaddress, holder.ID) {
+			return reservation, fmt.Errorf("address %s is held by %s %s", holder.Address, holder.Kind, holder.Name)
+		}
+	}
+	reservation.Created = time.Now().Unix()
+	key, err := GetRecordKey(reservation.Address, reservation.Network)
+	if err != nil {
+		return reservation, err
+	}
+	data, err := json.Marshal(&reservation)
+	if err != nil {
+		return reservation, err
+	}
+	return reservation, database.Insert(key, string(data), database.RESERVATIONS_TABLE_NAME)
+}
+
+// DeleteReservation - removes the reservation starting at an address of a network
+func DeleteReservation(netid, address string) error {
+	key, err := GetRecordKey(address, netid)
+	if err != nil {
+		return err
+	}
+	if _, err = database.FetchRecord(database.RESERVATIONS_TABLE_NAME, key); err != nil {
+		return fmt.Errorf("no reservation of %s on network %s", address, netid)
+	}
+	return database.DeleteRecord(database.RESERVATIONS_TABLE_NAME, key)
+}
+
+// GetIPAMReport - the allocated, reserved and free addresses of each range of a network, and what holds each address
+func GetIPAMReport(netid string) (models.IPAMReport, error) {
+	report := models.IPAMReport{Network: netid, Ranges: []models.IPAMRange{}, Addresses: []models.IPAMAddress{}}
+	network, err := GetParentNetwork(netid)
+	if err != nil {
+		return report, err
+	}
+	holders, err := networkAddressHolders(netid)
+	if err != nil {
+		return report, err
+	}
+	reservations, err := GetReservations(netid)
+	if err != nil {
+		return report, err
+	}
+
+	for _, addressRange := range append(network.AddressRanges(), network.AddressRanges6()...) {
+		usage := models.IPAMRange{Range: addressRange, Total: rangeSize(addressRange), Reserved: big.NewInt(0)}
+		for _, holder := range holders {
+			if addressRangeOf(holder.Address, []string{addressRange}) != "" {
+				usage.Allocated++
+			}
+		}
+		for i := range reservations {
+			if addressRangeOf(reservations[i].Address, []string{addressRange}) == "" {
+				continue
+			}
+			unheld := reservationSize(&reservations[i])
+			for _, holder := range holders {
+				if isReservedAddress(holder.Address, &reservations[i]) {
+					unheld.Sub(unheld, big.NewInt(1))
+				}
+			}
+			usage.Reserved.Add(usage.Reserved, unheld)
+		}
+		usage.Free = new(big.Int).Sub(usage.Total, usage.Reserved)
+		usage.Free.Sub(usage.Free, big.NewInt(int64(usage.Allocated)))
+		if usage.Free.Sign() < 0 {
+			usage.Free.SetInt64(0)
+		}
+		report.Ranges = append(report.Ranges, usage)
+	}
+
+	for _, holder := range holders {
+		for i := range reservations {
+			if isReservedAddress(holder.Address, &reservations[i]) {
+				holder.Reserved = true
+			}
+		}
+		report.Addresses = append(report.Addresses, holder.IPAMAddress)
+	}
+	for i := range reservations {
+		reservation := &reservations[i]
+		held := false
+		for _, holder := range holders {
+			held = held || (reservation.EndAddress == "" && holder.Address == reservation.Address)
+		}
+		if held {
+			continue
+		}
+		address := reservation.Address
+		if reservation.EndAddress != "" {
+			address += "-" + reservation.EndAddress
+		}
+		name := reservation.Description
+		for _, owner := range []string{reservation.NodeID, reservation.MacAddress, reservation.Hostname} {
+			if owner != "" {
+				name = owner
+			}
+		}
+		report.Addresses = append(report.Addresses, models.IPAMAddress{Address: address, Kind: models.IPAM_RESERVATION, Name: name, Reserved: true})
+	}
+	sort.SliceStable(report.Addresses, func(i, j int) bool {
+		first := strings.Split(report.Addresses[i].Address, "-")[0]
+		return compareAddresses(first, strings.Split(report.Addresses[j].Address, "-")[0]) < 0
+	})
+	return report, nil
+}
+
+// allocateAddress - the address reserved for a node or ext client, or else the first one of its network free to hand out
+func allocateAddress(netid, name, macaddress, id string, reverse, isIpv6 bool) (string, error) {
+	if reservations, err := GetReservations(netid); err == nil {
+		for i := range reservations {
+			address := reservations[i].Address
+			if !reservations[i].IsFor(name, macaddress, id) || isIPv6Address(address) != isIpv6 {
+				continue
+			}
+			if held, err := addresses.hold(netid, address); err != nil {
+				return "", err
+			} else if held {
+				return address, nil
+			}
+		}
+	}
+	if isIpv6 {
+		return UniqueAddress6(netid, reverse)
+	}
+	return UniqueAddress(netid, reverse)
+}
+
+// checkReservedAddress - refuses an address reserved for anything but a node or ext client with a name, MAC address and ID
+func checkReservedAddress(netid, address, name, macaddress, id string) error {
+	reservations, err := GetReservations(netid)
+	if err != nil {
+		return err
+	}
+	for i := range reservations {
+		if isReservedAddress(address, &reservations[i]) && !reservations[i].IsFor(name, macaddress, id) {
+			return fmt.Errorf("address %s is reserved", address)
+		}
+	}
+	return nil
+}
+
+// networkAddressHolders - the addresses held by the nodes and ext clients of a network
+func networkAddressHolders(netid string) ([]addressHolder, error) {
+	var holders []addressHolder
+	nodes, err := GetNetworkNodes(netid)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	for _, node := range nodes {
+		for _, address := range []string{node.Address, node.Address6} {
+			if address != "" {
+				holders = append(holders, addressHolder{models.IPAMAddress{Address: address, Kind: models.IPAM_NODE, ID: node.ID, Name: node.Name}, node.MacAddress})
+			}
+		}
+	}
+	clients, err := GetNetworkExtClients(netid)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	for _, client := range clients {
+		for _, address := range []string{client.Address, client.Address6} {
+			if address != "" {
+				holders = append(holders, addressHolder{models.IPAMAddress{Address: address, Kind: models.IPAM_EXTCLIENT, ID: client.ClientID, Name: client.ClientID}, ""})
+			}
+		}
+	}
+	return holders, nil
+}
+
+// isReservedAddress - checks if an address is the one of a reservation, or in its range
+func isReservedAddress(address string, reservation *models.IPReservation) bool {
+	end := reservation.EndAddress
+	if end == "" {
+		end = reservation.Address
+	}
+	return isIPv6Address(address) == isIPv6Address(reservation.Address) &&
+		compareAddresses(address, reservation.Address) >= 0 && compareAddresses(address, end) <= 0
+}
+
+// isAddressReserved - checks if any of a list of reservations holds an address
+func isAddressReserved(address string, reservations []models.IPReservation) bool {
+	for i := range reservations {
+		if isReservedAddress(address, &reservations[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func reservationsOverlap(a, b *models.IPReservation) bool {
+	return isReservedAddress(a.Address, b) || isReservedAddress(b.Address, a)
+}
+
+// reservationSize - how many addresses a reservation holds
+func reservationSize(reservation *models.IPReservation) *big.Int {
+	if reservation.EndAddress == "" {
+		return big.NewInt(1)
+	}
+	size := new(big.Int).Sub(addressInt(reservation.EndAddress), addressInt(reservation.Address))
+	return size.Add(size, big.NewInt(1))
+}
+
+// rangeSize - how many addresses of a range can be handed out, leaving out network and broadcast addresses of IPv4
+// ranges and the addresses ending in .0 or .255 of the wider ones
+func rangeSize(addressRange string) *big.Int {
+	_, cidr, err := net.ParseCIDR(addressRange)
+	if err != nil {
+		return big.NewInt(0)
+	}
+	ones, bits := cidr.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	switch {
+	case bits == 32 && ones <= 24:
+		blocks := new(big.Int).Rsh(size, 8)
+		return size.Sub(size, blocks.Lsh(blocks, 1))
+	case bits == 32 && ones <= 30:
+		return size.Sub(size, big.NewInt(2))
+	case bits == 128 && ones < 128:
+		return size.Sub(size, big.NewInt(1)) // the first address of an IPv6 range is not handed out
+	}
+	return size
+}
+
+func compareAddresses(a, b string) int {
+	return bytes.Compare(net.ParseIP(a).To16(), net.ParseIP(b).To16())
+}
+
+func addressInt(address string) *big.Int {
+	return new(big.Int).SetBytes(net.ParseIP(address).To16())
+}
+
+func isIPv6Address(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestIPAM(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+
+	if _, err := CreateNetwork(models.Network{NetID: "ipam", AddressRange: "10.92.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Reserve", func(t *testing.T) {
+		if _, err := CreateReservation(models.IPReservation{Network: "ipam", Address: "10.92.0.1", EndAddress: "10.92.0.10"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CreateReservation(models.IPReservation{Network: "ipam", Address: "10.92.0.20", Hostname: "db"}); err != nil {
+			t.Fatal(err)
+		}
+		for name, reservation := range map[string]models.IPReservation{
+			"overlapping":  {Network: "ipam", Address: "10.92.0.5"},
+			"owned range":  {Network: "ipam", Address: "10.92.0.30", EndAddress: "10.92.0.40", Hostname: "web"},
+			"out of range": {Network: "ipam", Address: "10.93.0.5"},
+			"backwards":    {Network: "ipam", Address: "10.92.0.60", EndAddress: "10.92.0.50"},
+		} {
+			if _, err := CreateReservation(reservation); err == nil {
+				t.Fatalf("expected a reservation %s to be refused", name)
+			}
+		}
+	})
+	t.Run("Allocate", func(t *testing.T) {
+		if address, err := UniqueAddress("ipam", false); err != nil || address != "10.92.0.11" {
+			t.Fatalf("expected reserved addresses to be skipped, got %s %v", address, err)
+		}
+		node := models.Node{ID: "web", Name: "web", Network: "ipam", Address: "10.92.0.11"}
+		data, _ := json.Marshal(&node)
+		database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+		if _, err := CreateReservation(models.IPReservation{Network: "ipam", Address: "10.92.0.11", Hostname: "other"}); err == nil {
+			t.Fatal("expected an address held by a node to be reserved only for it")
+		}
+		if _, err := CreateReservation(models.IPReservation{Network: "ipam", Address: "10.92.0.11", NodeID: "web"}); err != nil {
+			t.Fatal(err)
+		}
+		client := models.ExtClient{ClientID: "db", Network: "ipam"}
+		if err := CreateExtClient(&client); err != nil || client.Address != "10.92.0.20" {
+			t.Fatalf("expected the ext client to get its reserved address, got %s %v", client.Address, err)
+		}
+		if err := checkReservedAddress("ipam", "10.92.0.5", "other", "", ""); err == nil {
+			t.Fatal("expected a reserved address to be refused to others")
+		}
+	})
+	t.Run("Report", func(t *testing.T) {
+		report, err := GetIPAMReport("ipam")
+		if err != nil {
+			t.Fatal(err)
+		}
+		usage := report.Ranges[0]
+		if usage.Total.Int64() != 254 || usage.Allocated != 2 || usage.Reserved.Int64() != 10 || usage.Free.Int64() != 242 {
+			t.Fatalf("expected 254 addresses, 2 allocated, 10 reserved and 242 free, got %+v", usage)
+		}
+		if len(report.Addresses) != 3 || report.Addresses[0].Address != "10.92.0.1-10.92.0.10" ||
+			report.Addresses[1].Kind != models.IPAM_NODE || !report.Addresses[1].Reserved || report.Addresses[2].Kind != models.IPAM_EXTCLIENT {
+			t.Fatalf("expected the blocked range, the node and the ext client, got %+v", report.Addresses)
+		}
+	})
+	t.Run("Join", func(t *testing.T) {
+		os.Setenv("DNS_MODE", "off")
+		defer os.Unsetenv("DNS_MODE")
+		if _, err := CreateReservation(models.IPReservation{Network: "ipam", Address: "10.92.0.50", NodeID: "existing"}); err != nil {
+			t.Fatal(err)
+		}
+		defer DeleteReservation("ipam", "10.92.0.50")
+		joining := models.Node{ID: "existing", Name: "joining", Network: "ipam", Address: "10.92.0.50", PublicKey: "DM5qhLAE20PG9BbfBCger+Ac9D2NDOwCtY1rbYDLf34=", Endpoint: "10.0.0.1", MacAddress: "01:02:03:04:05:07", Password: "password", OS: "linux"}
+		if err := CreateNode(&joining); err == nil {
+			t.Fatal("expected a joining node claiming a reserved node ID to be refused the address")
+		}
+		joining.Address = ""
+		if err := CreateNode(&joining); err != nil || joining.Address == "10.92.0.50" {
+			t.Fatalf("expected the address reserved for another node to be skipped, got %s %v", joining.Address, err)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		if err := DeleteReservation("ipam", "10.92.0.1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := DeleteReservation("ipam", "10.92.0.1"); err == nil {
+			t.Fatal("expected a missing reservation to be refused")
+		}
+		if address, err := UniqueAddress("ipam", false); err != nil || address != "10.92.0.1" {
+			t.Fatalf("expected a released address to be handed out, got %s %v", address, err)
+		}
+	})
+}
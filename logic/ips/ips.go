@@ -2,6 +2,8 @@ package ips
 
 import (
 	"fmt"
+	"math/big"
+	"net"
 	"strings"
 
 	"github.com/seancfoley/ipaddress-go/ipaddr"
@@ -60,3 +62,32 @@ func GetLastAddr6(cidr6 string) (*ipaddr.IPAddress, error) {
 	upper := currentCidr.GetUpper()
 	return upper, nil
 }
+
+// RemapAddr - moves an address in one CIDR to the same offset in another CIDR of the same family
+func RemapAddr(addr, fromCidr, toCidr string) (string, error) {
+	ip := net.ParseIP(addr)
+	_, from, err := net.ParseCIDR(fromCidr)
+	if err != nil {
+		return "", err
+	}
+	_, to, err := net.ParseCIDR(toCidr)
+	if err != nil {
+		return "", err
+	}
+	if ip == nil || !from.Contains(ip) {
+		return "", fmt.Errorf("%s is not in %s", addr, fromCidr)
+	}
+	if len(from.IP) != len(to.IP) {
+		return "", fmt.Errorf("%s and %s are not of the same IP version", fromCidr, toCidr)
+	}
+	if ip4 := ip.To4(); ip4 != nil && len(from.IP) == net.IPv4len {
+		ip = ip4
+	}
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(ip), new(big.Int).SetBytes(from.IP))
+	ones, bits := to.Mask.Size()
+	if offset.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))) >= 0 {
+		return "", fmt.Errorf("%s does not fit in %s", addr, toCidr)
+	}
+	remapped := new(big.Int).Add(new(big.Int).SetBytes(to.IP), offset)
+	return net.IP(remapped.FillBytes(make([]byte, len(to.IP)))).String(), nil
+}
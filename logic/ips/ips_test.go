@@ -48,3 +48,24 @@ func TestIp4(t *testing.T) {
 		assert.Equal(t, first.GetNetIPAddr().IP.String(), "fde6:be04:fa5e:d076::1")
 	})
 }
+
+func TestRemapAddr(t *testing.T) {
+	t.Run("IPv4", func(t *testing.T) {
+		addr, err := ips.RemapAddr("10.0.1.20", "10.0.0.0/16", "10.20.0.0/16")
+		assert.Nil(t, err)
+		assert.Equal(t, "10.20.1.20", addr)
+	})
+	t.Run("IPv6", func(t *testing.T) {
+		addr, err := ips.RemapAddr("fde6:be04:fa5e:d076::20", "fde6:be04:fa5e:d076::/64", "fd00:1::/64")
+		assert.Nil(t, err)
+		assert.Equal(t, "fd00:1::20", addr)
+	})
+	t.Run("Outside", func(t *testing.T) {
+		_, err := ips.RemapAddr("192.168.1.1", "10.0.0.0/16", "10.20.0.0/16")
+		assert.NotNil(t, err)
+	})
+	t.Run("Too Small", func(t *testing.T) {
+		_, err := ips.RemapAddr("10.0.1.20", "10.0.0.0/16", "10.20.0.0/24")
+		assert.NotNil(t, err)
+	})
+}
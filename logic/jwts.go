@@ -83,6 +83,14 @@ func VerifyUserToken(tokenString string) (username string, networks []string, is
 	if tokenString == servercfg.GetMasterKey() && servercfg.GetMasterKey() != "" {
 		return "masteradministrator", nil, true, nil
 	}
+	// API tokens act as their user, the auth middleware also checks the request is within their scope
+	if IsAPIToken(tokenString) {
+		apiToken, networks, isadmin, err := VerifyAPIToken(tokenString)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return apiToken.UserName, networks, isadmin, nil
+	}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return jwtSecretKey, nil
@@ -0,0 +1,176 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// kinds of subjects failed logins are tracked for
+const (
+	LOGIN_KIND_USER = "user"
+	LOGIN_KIND_NODE = "node"
+	LOGIN_KIND_IP   = "ip"
+)
+
+// ErrLoginLocked - returned when a login is attempted before the backoff or lockout has passed
+var ErrLoginLocked = errors.New("too many failed login attempts, try again later")
+
+// loginAttempts - failed logins of a subject, kept in memory by each server
+// loginPending - logins of a subject reserved by CheckLogin and not yet settled
+var (
+	loginMutex    sync.Mutex
+	loginAttempts = map[string]*models.LoginLockout{}
+	loginPending  = map[string]int64{}
+)
+
+// LoginAttempt - a login reserved by CheckLogin, settled with Succeeded or Failed once its credentials are checked
+type LoginAttempt struct {
+	kind    string
+	subject string
+	ip      string
+	settled bool
+}
+
+// CheckLogin - checks a login may be attempted for the subject from the IP address and reserves it, so concurrent
+// logins cannot all pass before the first failure is counted, returning ErrLoginLocked and how long to wait
+// if either is backing off or locked out, or the subject already has a login being checked
+func CheckLogin(kind string, subject string, ip string) (*LoginAttempt, time.Duration, error) {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	now := time.Now().Unix()
+	subjectKey, ipKey := loginKey(kind, subject), loginKey(LOGIN_KIND_IP, ip)
+	var retryAfter int64
+	for _, key := range []string{subjectKey, ipKey} {
+		if attempts, ok := loginAttempts[key]; ok && attempts.RetryAfter > retryAfter {
+			retryAfter = attempts.RetryAfter
+		}
+	}
+	if retryAfter > now {
+		return nil, time.Duration(retryAfter-now) * time.Second, ErrLoginLocked
+	}
+	var ipFailures int64
+	if attempts, ok := loginAttempts[ipKey]; ok {
+		ipFailures = attempts.Failures
+	}
+	if loginPending[subjectKey] > 0 || ipFailures+loginPending[ipKey] >= servercfg.GetLoginMaxIPAttempts() {
+		return nil, time.Duration(servercfg.GetLoginBackoff()) * time.Second, ErrLoginLocked
+	}
+	loginPending[subjectKey]++
+	loginPending[ipKey]++
+	return &LoginAttempt{kind: kind, subject: subject, ip: ip}, 0, nil
+}
+
+// LoginAttempt.Failed - counts the login as failed against the subject and IP address, each failure
+// doubles the wait before the next attempt until the lockout is reached, does nothing once settled
+func (attempt *LoginAttempt) Failed() {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	if !attempt.settle() {
+		return
+	}
+	now := time.Now().Unix()
+	pruneLoginAttempts(now)
+	recordLoginFailure(attempt.kind, attempt.subject, servercfg.GetLoginMaxAttempts(), now)
+	recordLoginFailure(LOGIN_KIND_IP, attempt.ip, servercfg.GetLoginMaxIPAttempts(), now)
+}
+
+// LoginAttempt.Succeeded - clears the failed logins of the subject, failures of its IP address are kept
+func (attempt *LoginAttempt) Succeeded() {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	if attempt.settle() {
+		delete(loginAttempts, loginKey(attempt.kind, attempt.subject))
+	}
+}
+
+// LoginAttempt.settle - releases the reservation of a login, false if it was already settled, caller must hold loginMutex
+func (attempt *LoginAttempt) settle() bool {
+	if attempt.settled {
+		return false
+	}
+	attempt.settled = true
+	for _, key := range []string{loginKey(attempt.kind, attempt.subject), loginKey(LOGIN_KIND_IP, attempt.ip)} {
+		if loginPending[key]--; loginPending[key] <= 0 {
+			delete(loginPending, key)
+		}
+	}
+	return true
+}
+
+// GetLoginLockouts - gets every user, node and IP address with recent failed logins
+func GetLoginLockouts() []models.LoginLockout {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	now := time.Now().Unix()
+	pruneLoginAttempts(now)
+	var lockouts = []models.LoginLockout{}
+	for _, attempts := range loginAttempts {
+		lockout := *attempts
+		lockout.Locked = lockout.Locked && lockout.RetryAfter > now
+		lockouts = append(lockouts, lockout)
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return loginKey(lockouts[i].Kind, lockouts[i].Subject) < loginKey(lockouts[j].Kind, lockouts[j].Subject)
+	})
+	return lockouts
+}
+
+// ClearLoginLockout - clears the failed logins of a user, node or IP address, returns false if there were none
+func ClearLoginLockout(kind string, subject string) bool {
+	loginMutex.Lock()
+	defer loginMutex.Unlock()
+	key := loginKey(kind, subject)
+	if _, ok := loginAttempts[key]; !ok {
+		return false
+	}
+	delete(loginAttempts, key)
+	return true
+}
+
+// recordLoginFailure - counts a failure against a single subject, caller must hold loginMutex
+func recordLoginFailure(kind string, subject string, maxAttempts int64, now int64) {
+	lockout := servercfg.GetLoginLockout()
+	key := loginKey(kind, subject)
+	attempts, ok := loginAttempts[key]
+	if !ok {
+		attempts = &models.LoginLockout{Kind: kind, Subject: subject}
+		loginAttempts[key] = attempts
+	}
+	attempts.Failures++
+	attempts.LastFailure = now
+	if attempts.Failures >= maxAttempts {
+		attempts.Locked = true
+		attempts.RetryAfter = now + lockout
+		logger.Log(0, "locked out", kind, subject, "for", time.Duration(lockout*int64(time.Second)).String(), "after", fmt.Sprint(attempts.Failures), "failed logins")
+		return
+	}
+	backoff := servercfg.GetLoginBackoff()
+	for i := int64(1); i < attempts.Failures && backoff < lockout; i++ {
+		backoff *= 2
+	}
+	if backoff > lockout {
+		backoff = lockout
+	}
+	attempts.RetryAfter = now + backoff
+}
+
+// pruneLoginAttempts - forgets subjects without a failed login for a lockout period, caller must hold loginMutex
+func pruneLoginAttempts(now int64) {
+	lockout := servercfg.GetLoginLockout()
+	for key, attempts := range loginAttempts {
+		if attempts.RetryAfter <= now && now-attempts.LastFailure >= lockout {
+			delete(loginAttempts, key)
+		}
+	}
+}
+
+func loginKey(kind string, subject string) string {
+	return kind + ":" + subject
+}
@@ -0,0 +1,119 @@
+package logic
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestLoginLockout(t *testing.T) {
+	os.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+	os.Setenv("LOGIN_MAX_IP_ATTEMPTS", "100")
+	defer os.Unsetenv("LOGIN_MAX_ATTEMPTS")
+	defer os.Unsetenv("LOGIN_MAX_IP_ATTEMPTS")
+	defer ClearLoginLockout(LOGIN_KIND_IP, "192.0.2.1")
+	defer ClearLoginLockout(LOGIN_KIND_IP, "192.0.2.2")
+
+	failLogin := func(subject, ip string) {
+		attempt, _, err := CheckLogin(LOGIN_KIND_USER, subject, ip)
+		if err != nil {
+			t.Fatalf("expected a login of %s from %s to be allowed, got %v", subject, ip, err)
+		}
+		attempt.Failed()
+	}
+	// expire - ends the backoff of a subject and IP address, keeping their failures
+	expire := func(subject, ip string) {
+		loginMutex.Lock()
+		defer loginMutex.Unlock()
+		for _, key := range []string{loginKey(LOGIN_KIND_USER, subject), loginKey(LOGIN_KIND_IP, ip)} {
+			if attempts, ok := loginAttempts[key]; ok {
+				attempts.RetryAfter = 0
+			}
+		}
+	}
+
+	failLogin("mallory", "192.0.2.1")
+	_, wait, err := CheckLogin(LOGIN_KIND_USER, "mallory", "192.0.2.1")
+	if !errors.Is(err, ErrLoginLocked) || wait <= 0 {
+		t.Fatalf("expected backoff after a failure, got %v %v", wait, err)
+	}
+	expire("mallory", "192.0.2.1")
+	failLogin("mallory", "192.0.2.1")
+	_, second, _ := CheckLogin(LOGIN_KIND_USER, "mallory", "192.0.2.2")
+	if second < wait {
+		t.Fatalf("expected backoff to grow, got %v after %v", second, wait)
+	}
+	expire("mallory", "192.0.2.2")
+	failLogin("mallory", "192.0.2.2")
+	var locked bool
+	for _, lockout := range GetLoginLockouts() {
+		if lockout.Kind == LOGIN_KIND_USER && lockout.Subject == "mallory" {
+			locked = lockout.Locked && lockout.Failures == 3
+		}
+	}
+	if !locked {
+		t.Fatalf("expected mallory to be locked out, got %+v", GetLoginLockouts())
+	}
+	if !ClearLoginLockout(LOGIN_KIND_USER, "mallory") {
+		t.Fatal("expected lockout to be cleared")
+	}
+	attempt, _, err := CheckLogin(LOGIN_KIND_USER, "mallory", "192.0.2.3")
+	if err != nil {
+		t.Fatalf("expected login to be allowed after clearing, got %v", err)
+	}
+	attempt.Succeeded()
+
+	failLogin("alice", "192.0.2.4")
+	expire("alice", "192.0.2.4")
+	attempt, _, _ = CheckLogin(LOGIN_KIND_USER, "alice", "198.51.100.1")
+	attempt.Succeeded()
+	attempt.Failed()
+	if attempt, _, err = CheckLogin(LOGIN_KIND_USER, "alice", "198.51.100.1"); err != nil {
+		t.Fatalf("expected success to clear failures and settle the login once, got %v", err)
+	}
+	attempt.Succeeded()
+	failLogin("bob", "192.0.2.3")
+	ClearLoginLockout(LOGIN_KIND_USER, "bob")
+	if _, _, err = CheckLogin(LOGIN_KIND_USER, "bob", "192.0.2.3"); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("expected the IP address to keep backing off, got %v", err)
+	}
+	for _, ip := range []string{"192.0.2.3", "192.0.2.4", "198.51.100.1"} {
+		ClearLoginLockout(LOGIN_KIND_IP, ip)
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		attempt, _, err := CheckLogin(LOGIN_KIND_USER, "carol", "192.0.2.5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = CheckLogin(LOGIN_KIND_USER, "carol", "192.0.2.6"); !errors.Is(err, ErrLoginLocked) {
+			t.Fatalf("expected a second login while the first is checked to be refused, got %v", err)
+		}
+		attempt.Failed()
+		if _, _, err = CheckLogin(LOGIN_KIND_USER, "carol", "192.0.2.6"); !errors.Is(err, ErrLoginLocked) {
+			t.Fatalf("expected the settled failure to back off, got %v", err)
+		}
+		ClearLoginLockout(LOGIN_KIND_USER, "carol")
+		ClearLoginLockout(LOGIN_KIND_IP, "192.0.2.5")
+
+		os.Setenv("LOGIN_MAX_IP_ATTEMPTS", "2")
+		var attempts []*LoginAttempt
+		for _, subject := range []string{"dave", "erin"} {
+			attempt, _, err := CheckLogin(LOGIN_KIND_USER, subject, "192.0.2.7")
+			if err != nil {
+				t.Fatal(err)
+			}
+			attempts = append(attempts, attempt)
+		}
+		if _, _, err = CheckLogin(LOGIN_KIND_USER, "frank", "192.0.2.7"); !errors.Is(err, ErrLoginLocked) {
+			t.Fatalf("expected logins past the IP limit to be refused while others are checked, got %v", err)
+		}
+		for _, attempt := range attempts {
+			attempt.Succeeded()
+		}
+		if attempt, _, err = CheckLogin(LOGIN_KIND_USER, "frank", "192.0.2.7"); err != nil {
+			t.Fatalf("expected settled logins to be released, got %v", err)
+		}
+		attempt.Succeeded()
+	})
+}
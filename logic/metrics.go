@@ -0,0 +1,84 @@
+package logic
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/metrics"
+	"github.com/gravitl/netmaker/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// node statuses reported by the nodes gauge
+const (
+	NODE_STATUS_PENDING = "pending"
+	NODE_STATUS_ACTIVE  = "active"
+	NODE_STATUS_STALE   = "stale"
+)
+
+// NODE_STALE_AFTER - time without a check in after which a node is reported as stale
+const NODE_STALE_AFTER = 5 * time.Minute
+
+var (
+	networksDesc   = prometheus.NewDesc("netmaker_networks", "Networks on the server.", nil, nil)
+	usersDesc      = prometheus.NewDesc("netmaker_users", "Users on the server.", nil, nil)
+	extClientsDesc = prometheus.NewDesc("netmaker_ext_clients", "External clients on the server.", nil, nil)
+	nodesDesc      = prometheus.NewDesc("netmaker_nodes", "Nodes by status, OS and version.", []string{"status", "os", "version"}, nil)
+)
+
+func init() {
+	metrics.Registry.MustRegister(serverCollector{})
+}
+
+// serverCollector - reports the network, user, ext client and node counts from the telemetry data on every scrape
+type serverCollector struct{}
+
+func (serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- networksDesc
+	ch <- usersDesc
+	ch <- extClientsDesc
+	ch <- nodesDesc
+}
+
+func (serverCollector) Collect(ch chan<- prometheus.Metric) {
+	data, err := fetchTelemetryData()
+	if err != nil {
+		logger.Log(2, "could not collect node metrics", err.Error())
+	}
+	ch <- prometheus.MustNewConstMetric(networksDesc, prometheus.GaugeValue, float64(data.Networks))
+	ch <- prometheus.MustNewConstMetric(usersDesc, prometheus.GaugeValue, float64(data.Users))
+	ch <- prometheus.MustNewConstMetric(extClientsDesc, prometheus.GaugeValue, float64(data.ExtClients))
+	nodes, err := GetAllNodes()
+	if err != nil {
+		return
+	}
+	for status, count := range countNodes(nodes, time.Now()) {
+		ch <- prometheus.MustNewConstMetric(nodesDesc, prometheus.GaugeValue, float64(count), status.status, status.os, status.version)
+	}
+}
+
+type nodeMetricKey struct {
+	status  string
+	os      string
+	version string
+}
+
+// countNodes - counts nodes by status, OS and version
+func countNodes(nodes []models.Node, now time.Time) map[nodeMetricKey]int {
+	counts := make(map[nodeMetricKey]int)
+	for i := range nodes {
+		counts[nodeMetricKey{status: nodeStatus(&nodes[i], now), os: nodes[i].OS, version: nodes[i].Version}]++
+	}
+	return counts
+}
+
+// nodeStatus - pending until approved, then stale once it stops checking in
+func nodeStatus(node *models.Node, now time.Time) string {
+	if node.IsPending == "yes" {
+		return NODE_STATUS_PENDING
+	}
+	if node.LastCheckIn < now.Add(-NODE_STALE_AFTER).Unix() {
+		return NODE_STATUS_STALE
+	}
+	return NODE_STATUS_ACTIVE
+}
@@ -0,0 +1,197 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic/acls"
+	"github.com/gravitl/netmaker/logic/ips"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// ExportNetwork - a network as a declaration that manages all of it, access keys are exported without their secrets
+// node ACLs are exported where they differ from the network's default
+func ExportNetwork(netid string) (models.NetworkExport, error) {
+	network, err := GetParentNetwork(netid)
+	if err != nil {
+		return models.NetworkExport{}, err
+	}
+	declared := models.DeclaredNetwork{
+		Network:    *networkSettings(network),
+		DNS:        []models.DNSEntry{},
+		ACLs:       []models.DeclaredACL{},
+		Ingress:    []string{},
+		Egress:     []models.DeclaredEgress{},
+		Relays:     []models.DeclaredRelay{},
+		ExtClients: []models.DeclaredExtClient{},
+	}
+	declared.AccessKeys = []models.AccessKey{}
+	for _, key := range network.AccessKeys {
+		declared.AccessKeys = append(declared.AccessKeys, models.AccessKey{Name: key.Name, Uses: key.Uses})
+	}
+	entries, err := GetCustomDNS(netid)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.NetworkExport{}, err
+	}
+	for _, entry := range entries {
+		entry.Network = ""
+		declared.DNS = append(declared.DNS, entry)
+	}
+	sort.Slice(declared.DNS, func(i, j int) bool { return declared.DNS[i].Name < declared.DNS[j].Name })
+
+	nodes, err := GetNetworkNodes(netid)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.NetworkExport{}, err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	for _, node := range nodes {
+		if node.IsIngressGateway == "yes" {
+			declared.Ingress = append(declared.Ingress, node.ID)
+		}
+		if node.IsEgressGateway == "yes" {
+			declared.Egress = append(declared.Egress, models.DeclaredEgress{EgressGatewayRequest: models.EgressGatewayRequest{NodeID: node.ID, Ranges: node.EgressGatewayRanges}})
+		}
+		if node.IsRelay == "yes" {
+			declared.Relays = append(declared.Relays, models.DeclaredRelay{RelayRequest: models.RelayRequest{NodeID: node.ID, RelayAddrs: node.RelayAddrs}})
+		}
+	}
+	if len(nodes) > 1 {
+		var container acls.ACLContainer
+		if container, err = container.Get(acls.ContainerID(netid)); err != nil {
+			return models.NetworkExport{}, err
+		}
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				allowed := container.IsAllowed(acls.AclID(nodes[i].ID), acls.AclID(nodes[j].ID))
+				if allowed != (network.DefaultACL == "yes") {
+					declared.ACLs = append(declared.ACLs, models.DeclaredACL{Nodes: []string{nodes[i].ID, nodes[j].ID}, Allowed: allowed})
+				}
+			}
+		}
+	}
+
+	clients, err := GetNetworkExtClients(netid)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return models.NetworkExport{}, err
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ClientID < clients[j].ClientID })
+	for _, client := range clients {
+		enabled := client.Enabled
+		declared.ExtClients = append(declared.ExtClients, models.DeclaredExtClient{ClientID: client.ClientID, IngressGatewayID: client.IngressGatewayID, Enabled: &enabled})
+	}
+	return models.NetworkExport{Version: servercfg.GetVersion(), Exported: time.Now().Unix(), Network: declared}, nil
+}
+
+// ImportNetwork - creates a network from the export of another one, under its own netid and address ranges
+// settings, access keys with new secrets and custom DNS are carried over, DNS addresses in the exported ranges
+// are moved to the same place in the new ones, and what is bound to the nodes of the exported network is reported,
+// as are the secondary ranges of an address range that is replaced, which are left out
+func ImportNetwork(netid string, request models.NetworkImport, actor models.AuditEntry) (models.NetworkImportResult, error) {
+	result := models.NetworkImportResult{Changes: []models.DeclarationChange{}, NotCarried: []models.NotCarried{}}
+	exported := request.Export.Network
+	if exported.NetID == "" {
+		return result, errors.New("the export holds no network")
+	}
+	if _, err := GetParentNetwork(netid); err == nil {
+		return result, errors.New("network " + netid + " already exists")
+	}
+	settings := exported.Network
+	settings.NetID = netid
+	settings.AccessKeys = nil
+	if request.AddressRange != "" && request.AddressRange != exported.AddressRange {
+		settings.AddressRange = request.AddressRange
+		settings.SecondaryRanges = nil
+		result.NotCarried = append(result.NotCarried, secondaryRangeImports(exported.SecondaryRanges, exported.AddressRange)...)
+	}
+	if request.AddressRange6 != "" && request.AddressRange6 != exported.AddressRange6 {
+		settings.AddressRange6 = request.AddressRange6
+		settings.SecondaryRanges6 = nil
+		result.NotCarried = append(result.NotCarried, secondaryRangeImports(exported.SecondaryRanges6, exported.AddressRange6)...)
+	}
+	// an interface named after the exported network is named after the new one instead
+	exportedDefaults := models.Network{NetID: exported.NetID}
+	exportedDefaults.SetDefaults()
+	if settings.DefaultInterface == exportedDefaults.DefaultInterface {
+		settings.DefaultInterface = ""
+	}
+
+	imported := models.DeclaredNetwork{Network: settings}
+	for _, key := range exported.AccessKeys {
+		imported.AccessKeys = append(imported.AccessKeys, models.AccessKey{Name: key.Name, Uses: key.Uses})
+	}
+	for _, entry := range exported.DNS {
+		entry.Network = netid
+		var err error
+		if entry.Address, err = remapImportAddr(entry.Address, exported.AddressRange, settings.AddressRange); err == nil {
+			entry.Address6, err = remapImportAddr(entry.Address6, exported.AddressRange6, settings.AddressRange6)
+		}
+		if err != nil {
+			result.NotCarried = append(result.NotCarried, models.NotCarried{Kind: models.DECLARED_DNS, Name: entry.Name, Reason: err.Error()})
+			continue
+		}
+		imported.DNS = append(imported.DNS, entry)
+	}
+	result.NotCarried = append(result.NotCarried, nodeBoundImports(&exported)...)
+
+	applied, err := ApplyDeclaration(models.Declaration{Networks: []models.DeclaredNetwork{imported}}, false, false, actor)
+	result.Changes = applied.Changes[:applied.Applied]
+	if err != nil {
+		return result, err
+	}
+	result.Network, err = GetNetwork(netid)
+	return result, err
+}
+
+// remapImportAddr - moves an address in the exported range into the imported one, other addresses are kept
+func remapImportAddr(addr, exportedRange, importedRange string) (string, error) {
+	if addr == "" || exportedRange == "" || exportedRange == importedRange {
+		return addr, nil
+	}
+	if _, exportedNet, err := net.ParseCIDR(exportedRange); err != nil || !exportedNet.Contains(net.ParseIP(addr)) {
+		return addr, nil // outside the network, such as a public address
+	}
+	if importedRange == "" {
+		return "", fmt.Errorf("%s is in the exported range %s, which the new network does not have", addr, exportedRange)
+	}
+	return ips.RemapAddr(addr, exportedRange, importedRange)
+}
+
+// secondaryRangeImports - the secondary ranges of an exported address range the new network does not keep
+func secondaryRangeImports(secondaryRanges []string, exportedRange string) []models.NotCarried {
+	var notCarried []models.NotCarried
+	for _, secondaryRange := range secondaryRanges {
+		notCarried = append(notCarried, models.NotCarried{Kind: models.DECLARED_NETWORK, Name: secondaryRange,
+			Reason: "secondary range of the exported address range " + exportedRange + ", which the new network replaces, add new secondary ranges once needed"})
+	}
+	return notCarried
+}
+
+// nodeBoundImports - what an export holds about nodes, which the new network does not have
+func nodeBoundImports(exported *models.DeclaredNetwork) []models.NotCarried {
+	var notCarried []models.NotCarried
+	reason := func(nodeid string) string {
+		return "bound to node " + nodeid + " of network " + exported.NetID + ", set it up once nodes join"
+	}
+	for _, nodeid := range exported.Ingress {
+		notCarried = append(notCarried, models.NotCarried{Kind: models.DECLARED_INGRESS, Name: nodeid, Reason: reason(nodeid)})
+	}
+	for _, gateway := range exported.Egress {
+		notCarried = append(notCarried, models.NotCarried{Kind: models.DECLARED_EGRESS, Name: gateway.NodeID, Reason: reason(gateway.NodeID) + fmt.Sprintf(", ranges %v", gateway.Ranges)})
+	}
+	for _, relay := range exported.Relays {
+		notCarried = append(notCarried, models.NotCarried{Kind: models.DECLARED_RELAY, Name: relay.NodeID, Reason: reason(relay.NodeID)})
+	}
+	for _, client := range exported.ExtClients {
+		notCarried = append(notCarried, models.NotCarried{Kind: models.DECLARED_EXTCLIENT, Name: client.ClientID, Reason: reason(client.IngressGatewayID)})
+	}
+	for _, rule := range exported.ACLs {
+		name := fmt.Sprint(rule.Nodes)
+		notCarried = append(notCarried, models.NotCarried{Kind: models.DECLARED_ACL, Name: name, Reason: "node ACLs are bound to nodes of network " + exported.NetID + ", the default ACL is carried over"})
+	}
+	return notCarried
+}
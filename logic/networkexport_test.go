@@ -0,0 +1,112 @@
+package logic
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestNetworkExport(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	actor := models.AuditEntry{ActorType: models.AUDIT_ACTOR_SERVER, Actor: "test", Source: "test"}
+
+	network, err := CreateNetwork(models.Network{NetID: "source", AddressRange: "10.97.0.0/24", DefaultKeepalive: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := CreateAccessKey(models.AccessKey{Name: "ci", Uses: 5}, network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []models.DNSEntry{
+		{Name: "db", Address: "10.97.0.50", Network: "source"},
+		{Name: "public", Address: "192.0.2.10", Network: "source"},
+	} {
+		if _, err = CreateDNS(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node := models.Node{ID: "gateway", Name: "gateway", Network: "source", Address: "10.97.0.1", IsIngressGateway: "yes"}
+	data, _ := json.Marshal(&node)
+	database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+
+	var export models.NetworkExport
+	t.Run("Export", func(t *testing.T) {
+		if export, err = ExportNetwork("source"); err != nil {
+			t.Fatal(err)
+		}
+		exported := export.Network
+		if exported.DefaultKeepalive != 25 || len(exported.AccessKeys) != 1 || exported.AccessKeys[0].Value != "" {
+			t.Fatalf("expected the settings and key without its secret, got %+v", exported)
+		}
+		if len(exported.DNS) != 2 || exported.DNS[0].Network != "" || len(exported.Ingress) != 1 || exported.Ingress[0] != "gateway" {
+			t.Fatalf("expected the dns entries and the ingress gateway, got %+v", exported)
+		}
+		if _, err = ExportNetwork("missing"); err == nil {
+			t.Fatal("expected a missing network to be refused")
+		}
+	})
+	t.Run("Import", func(t *testing.T) {
+		result, err := ImportNetwork("clone", models.NetworkImport{Export: export, AddressRange: "10.96.0.0/24"}, actor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Network.AddressRange != "10.96.0.0/24" || result.Network.DefaultKeepalive != 25 || result.Network.DefaultInterface != "nm-clone" {
+			t.Fatalf("expected the settings under the new range and interface, got %+v", result.Network)
+		}
+		if len(result.Network.AccessKeys) != 1 || result.Network.AccessKeys[0].Value == key.Value {
+			t.Fatalf("expected the key with a new secret, got %+v", result.Network.AccessKeys)
+		}
+		var addresses = map[string]string{}
+		entries, _ := GetCustomDNS("clone")
+		for _, entry := range entries {
+			addresses[entry.Name] = entry.Address
+		}
+		if addresses["db"] != "10.96.0.50" || addresses["public"] != "192.0.2.10" {
+			t.Fatalf("expected addresses in the range to be moved and others kept, got %+v", addresses)
+		}
+		if len(result.NotCarried) != 1 || result.NotCarried[0].Kind != models.DECLARED_INGRESS {
+			t.Fatalf("expected the ingress gateway to be reported, got %+v", result.NotCarried)
+		}
+		if len(result.Changes) != 4 {
+			t.Fatalf("expected a network, key and two dns changes, got %+v", result.Changes)
+		}
+	})
+	t.Run("SecondaryRanges", func(t *testing.T) {
+		staged := export
+		staged.Network.SecondaryRanges = []string{"10.97.1.0/24"}
+		result, err := ImportNetwork("staged", models.NetworkImport{Export: staged, AddressRange: "10.94.0.0/24"}, actor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Network.SecondaryRanges) != 0 {
+			t.Fatalf("expected the secondary ranges of the replaced range to be left out, got %v", result.Network.SecondaryRanges)
+		}
+		var reported bool
+		for _, notCarried := range result.NotCarried {
+			reported = reported || (notCarried.Kind == models.DECLARED_NETWORK && notCarried.Name == "10.97.1.0/24")
+		}
+		if !reported {
+			t.Fatalf("expected the secondary range to be reported, got %+v", result.NotCarried)
+		}
+	})
+	t.Run("Refused", func(t *testing.T) {
+		if _, err := ImportNetwork("clone", models.NetworkImport{Export: export, AddressRange: "10.95.0.0/24"}, actor); err == nil {
+			t.Fatal("expected an existing network to be refused")
+		}
+		if _, err := ImportNetwork("empty", models.NetworkImport{}, actor); err == nil {
+			t.Fatal("expected an empty export to be refused")
+		}
+		if _, err := ImportNetwork("samerange", models.NetworkImport{Export: export}, actor); err == nil {
+			t.Fatal("expected the exported range, kept when none is given, to be refused while the source network holds it")
+		}
+	})
+}
@@ -12,15 +12,18 @@ import (
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/acls/nodeacls"
-	"github.com/gravitl/netmaker/logic/ips"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/servercfg"
 	"github.com/gravitl/netmaker/validation"
 )
 
 // GetNetworks - returns all networks from database
 func GetNetworks() ([]models.Network, error) {
 	var networks []models.Network
+	if networks, ok := cachedNetworks(); ok {
+		return networks, nil
+	}
 
 	collection, err := database.FetchRecords(database.NETWORKS_TABLE_NAME)
 
@@ -42,27 +45,50 @@ func GetNetworks() ([]models.Network, error) {
 
 // DeleteNetwork - deletes a network
 func DeleteNetwork(network string) error {
-	// remove ACL for network
-	err := nodeacls.DeleteACLContainer(nodeacls.NetworkID(network))
-	if err != nil {
-		logger.Log(1, "failed to remove the node acls during network delete for network,", network)
-	}
 	nodeCount, err := GetNetworkNonServerNodeCount(network)
 	if nodeCount == 0 || database.IsEmptyRecord(err) {
 		// delete server nodes first then db records
+		var removed []models.Node
 		servers, err := GetSortedNetworkServerNodes(network)
-		if err == nil {
-			for _, s := range servers {
-				if err = DeleteNodeByID(&s, true); err != nil {
-					logger.Log(2, "could not removed server", s.Name, "before deleting network", network)
-				} else {
-					logger.Log(2, "removed server", s.Name, "before deleting network", network)
+		if err != nil {
+			logger.Log(1, "could not remove servers before deleting network", network)
+		}
+		reservations, err := GetReservations(network)
+		if err != nil {
+			logger.Log(1, "could not read the reservations of network", network)
+		}
+		err = database.Transaction(func(tx *database.Tx) error {
+			for i := range servers {
+				if err := deleteNodeRecords(tx, &servers[i], true); err != nil {
+					logger.Log(2, "could not removed server", servers[i].Name, "before deleting network", network)
+					continue
 				}
+				removed = append(removed, servers[i])
 			}
-		} else {
-			logger.Log(1, "could not remove servers before deleting network", network)
+			for _, reservation := range reservations {
+				if err := tx.DeleteRecord(database.RESERVATIONS_TABLE_NAME, reservation.Address+"###"+network); err != nil {
+					logger.Log(1, "failed to remove reservation", reservation.Address, "during network delete for network,", network)
+				}
+			}
+			// remove ACL for network
+			if err := nodeacls.DeleteACLContainerTx(tx, nodeacls.NetworkID(network)); err != nil {
+				logger.Log(1, "failed to remove the node acls during network delete for network,", network)
+			}
+			return tx.DeleteRecord(database.NETWORKS_TABLE_NAME, network)
+		})
+		if err != nil {
+			return err
+		}
+		for i := range removed {
+			if err := removeLocalServer(&removed[i]); err != nil {
+				logger.Log(1, "failed to clean up server", removed[i].Name, "after deleting network", network)
+			}
+			logger.Log(2, "removed server", removed[i].Name, "before deleting network", network)
+		}
+		if len(removed) > 0 && servercfg.IsDNSMode() {
+			SetDNS()
 		}
-		return database.DeleteRecord(database.NETWORKS_TABLE_NAME, network)
+		return nil
 	}
 	return errors.New("node check failed. All nodes must be deleted before deleting network")
 }
@@ -110,6 +136,7 @@ func NetworkNodesUpdatePullChanges(networkName string) error {
 			return err
 		}
 		if node.Network == networkName {
+			node.Revision++
 			data, err := json.Marshal(&node)
 			if err != nil {
 				return err
@@ -145,9 +172,19 @@ func GetNetworkNonServerNodeCount(networkName string) (int, error) {
 
 // GetParentNetwork - get parent network
 func GetParentNetwork(networkname string) (models.Network, error) {
+	return getParentNetwork(nil, networkname)
+}
+
+// getParentNetwork - get parent network, including writes queued in a transaction
+func getParentNetwork(tx *database.Tx, networkname string) (models.Network, error) {
 
 	var network models.Network
-	networkData, err := database.FetchRecord(database.NETWORKS_TABLE_NAME, networkname)
+	if tx == nil {
+		if network, ok := cachedNetwork(networkname); ok {
+			return network, nil
+		}
+	}
+	networkData, err := tx.FetchRecord(database.NETWORKS_TABLE_NAME, networkname)
 	if err != nil {
 		return network, err
 	}
@@ -172,7 +209,7 @@ func GetNetworkSettings(networkname string) (models.Network, error) {
 	return network, nil
 }
 
-// UniqueAddress - see if address is unique
+// UniqueAddress - hands out a free IPv4 address of a network, held back until the node or ext client given it is saved
 func UniqueAddress(networkName string, reverse bool) (string, error) {
 
 	var network models.Network
@@ -186,29 +223,12 @@ func UniqueAddress(networkName string, reverse bool) (string, error) {
 		return "", fmt.Errorf("IPv4 not active on network " + networkName)
 	}
 
-	newAddr, err := ips.GetFirstAddr(network.AddressRange)
+	reservations, err := GetReservations(networkName)
 	if err != nil {
-		logger.Log(0, "UniqueAddress encountered  an error")
-		return "666", err
+		return "", err
 	}
-
-	incVal := 1
-	if reverse {
-		incVal = -1
-		newAddr, err = ips.GetLastAddr(network.AddressRange)
-		if err != nil {
-			if err != nil {
-				logger.Log(0, "UniqueAddressServer encountered  an error")
-				return "666", err
-			}
-		}
-	}
-
-	for ; newAddr.ToAddressString().IsValid(); newAddr = newAddr.Increment(int64(incVal)) {
-		if IsIPUnique(networkName, newAddr.GetNetIPAddr().IP.String(), database.NODES_TABLE_NAME, false) &&
-			IsIPUnique(networkName, newAddr.GetNetIPAddr().IP.String(), database.EXT_CLIENT_TABLE_NAME, false) {
-			return newAddr.GetNetIPAddr().IP.String(), nil
-		}
+	if newAddr, err := addresses.allocate(networkName, network.AddressRanges(), reservations, reverse, false); err == nil {
+		return newAddr, nil
 	}
 
 	return "W1R3: NO UNIQUE ADDRESSES AVAILABLE", errors.New("ERROR: No unique addresses available. Check network subnet")
@@ -243,7 +263,7 @@ func IsIPUnique(network string, ip string, tableName string, isIpv6 bool) bool {
 	return isunique
 }
 
-// UniqueAddress6 - see if ipv6 address is unique
+// UniqueAddress6 - hands out a free IPv6 address of a network, held back until the node or ext client given it is saved
 func UniqueAddress6(networkName string, reverse bool) (string, error) {
 
 	var network models.Network
@@ -256,28 +276,12 @@ func UniqueAddress6(networkName string, reverse bool) (string, error) {
 		return "", fmt.Errorf("IPv6 not active on network " + networkName)
 	}
 
-	newAddr6, err := ips.GetFirstAddr6(network.AddressRange6)
+	reservations, err := GetReservations(networkName)
 	if err != nil {
-		return "666", err
+		return "", err
 	}
-
-	incVal := 1
-	if reverse {
-		incVal = -1
-		newAddr6, err = ips.GetLastAddr6(network.AddressRange6)
-		if err != nil {
-			if err != nil {
-				logger.Log(0, "UniqueAddress6Server encountered  an error")
-				return "666", err
-			}
-		}
-	}
-
-	for ; newAddr6.ToAddressString().IsValid(); newAddr6 = newAddr6.Increment(int64(incVal)) {
-		if IsIPUnique(networkName, newAddr6.GetNetIPAddr().IP.String(), database.NODES_TABLE_NAME, true) &&
-			IsIPUnique(networkName, newAddr6.GetNetIPAddr().IP.String(), database.EXT_CLIENT_TABLE_NAME, true) {
-			return newAddr6.GetNetIPAddr().IP.String(), nil
-		}
+	if newAddr6, err := addresses.allocate(networkName, network.AddressRanges6(), reservations, reverse, true); err == nil {
+		return newAddr6, nil
 	}
 
 	return "W1R3: NO UNIQUE ADDRESSES AVAILABLE", errors.New("ERROR: No unique IPv6 addresses available. Check network subnet")
@@ -371,6 +375,7 @@ func UpdateNetworkLocalAddresses(networkName string) error {
 			}
 
 			node.Address = ipaddr
+			node.Revision++
 			newNodeData, err := json.Marshal(&node)
 			if err != nil {
 				logger.Log(1, "error in node  address assignment!")
@@ -394,6 +399,7 @@ func UpdateNetworkHolePunching(networkName string, holepunch string) error {
 	for _, node := range nodes {
 		if node.IsServer != "yes" {
 			node.UDPHolePunch = holepunch
+			node.Revision++
 			newNodeData, err := json.Marshal(&node)
 			if err != nil {
 				logger.Log(1, "error in node hole punch assignment")
@@ -423,6 +429,7 @@ func RemoveNetworkNodeIPv6Addresses(networkName string) error {
 		}
 		if node.Network == networkName {
 			node.Address6 = ""
+			node.Revision++
 			data, err := json.Marshal(&node)
 			if err != nil {
 				return err
@@ -434,9 +441,14 @@ func RemoveNetworkNodeIPv6Addresses(networkName string) error {
 	return nil
 }
 
-// UpdateNetworkNodeAddresses - updates network node addresses
+// UpdateNetworkNodeAddresses - gives nodes whose addresses are out of the ranges of their network new ones,
+// nodes whose addresses still fit, such as after a range is expanded, keep them
 func UpdateNetworkNodeAddresses(networkName string) error {
 
+	network, err := GetParentNetwork(networkName)
+	if err != nil {
+		return err
+	}
 	collections, err := database.FetchRecords(database.NODES_TABLE_NAME)
 	if err != nil {
 		return err
@@ -450,26 +462,33 @@ func UpdateNetworkNodeAddresses(networkName string) error {
 			fmt.Println("error in node address assignment!")
 			return err
 		}
-		if node.Network == networkName {
-			var ipaddr string
-			var iperr error
-			if node.IsServer == "yes" {
-				ipaddr, iperr = UniqueAddress(networkName, true)
-			} else {
-				ipaddr, iperr = UniqueAddress(networkName, false)
-			}
-			if iperr != nil {
+		if node.Network != networkName {
+			continue
+		}
+		var changed bool
+		if node.Address != "" && len(network.AddressRanges()) > 0 && !IsAddressInNetwork(node.Address, &network) {
+			if node.Address, err = allocateAddress(networkName, node.Name, node.MacAddress, node.ID, node.IsServer == "yes", false); err != nil {
 				fmt.Println("error in node  address assignment!")
-				return iperr
+				return err
 			}
-
-			node.Address = ipaddr
-			data, err := json.Marshal(&node)
-			if err != nil {
+			changed = true
+		}
+		if node.Address6 != "" && len(network.AddressRanges6()) > 0 && !IsAddressInNetwork(node.Address6, &network) {
+			if node.Address6, err = allocateAddress(networkName, node.Name, node.MacAddress, node.ID, node.IsServer == "yes", true); err != nil {
+				fmt.Println("error in node  address assignment!")
 				return err
 			}
-			database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+			changed = true
+		}
+		if !changed {
+			continue
 		}
+		node.Revision++
+		data, err := json.Marshal(&node)
+		if err != nil {
+			return err
+		}
+		database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
 	}
 
 	return nil
@@ -497,14 +516,25 @@ func IsNetworkNameUnique(network *models.Network) (bool, error) {
 }
 
 // UpdateNetwork - updates a network with another network's fields
+// fails with ErrRevisionConflict when currentNetwork is no longer the stored revision
 func UpdateNetwork(currentNetwork *models.Network, newNetwork *models.Network) (bool, bool, bool, error) {
+	networkUpdateMutex.Lock()
+	defer networkUpdateMutex.Unlock()
+	if err := checkNetworkRevision(currentNetwork); err != nil {
+		return false, false, false, err
+	}
 	if err := ValidateNetwork(newNetwork, true); err != nil {
 		return false, false, false, err
 	}
 	if newNetwork.NetID == currentNetwork.NetID {
-		hasrangeupdate := newNetwork.AddressRange != currentNetwork.AddressRange
+		if err := checkRangeUpdate(currentNetwork, newNetwork); err != nil {
+			return false, false, false, err
+		}
+		hasrangeupdate := !sameStrings(newNetwork.AddressRanges(), currentNetwork.AddressRanges()) ||
+			!sameStrings(newNetwork.AddressRanges6(), currentNetwork.AddressRanges6())
 		localrangeupdate := newNetwork.LocalRange != currentNetwork.LocalRange
 		hasholepunchupdate := newNetwork.DefaultUDPHolePunch != currentNetwork.DefaultUDPHolePunch
+		newNetwork.Revision = currentNetwork.Revision + 1
 		data, err := json.Marshal(newNetwork)
 		if err != nil {
 			return false, false, false, err
@@ -521,6 +551,9 @@ func UpdateNetwork(currentNetwork *models.Network, newNetwork *models.Network) (
 func GetNetwork(networkname string) (models.Network, error) {
 
 	var network models.Network
+	if network, ok := cachedNetwork(networkname); ok {
+		return network, nil
+	}
 	networkData, err := database.FetchRecord(database.NETWORKS_TABLE_NAME, networkname)
 	if err != nil {
 		return network, err
@@ -564,9 +597,18 @@ func ValidateNetwork(network *models.Network, isUpdate bool) error {
 		for _, e := range err.(validator.ValidationErrors) {
 			fmt.Println(e)
 		}
+		return err
 	}
 
-	return err
+	if err = validateAddressRanges(network); err != nil {
+		return err
+	}
+	if isUpdate {
+		if current, err := GetParentNetwork(network.NetID); err == nil {
+			return checkNetworkUpdateConflicts(&current, network)
+		}
+	}
+	return checkRangeConflicts(networkRangeUses(network), network.NetID)
 }
 
 // ParseNetwork - parses a network into a model
@@ -609,6 +651,7 @@ func KeyUpdate(netname string) (models.Network, error) {
 
 // SaveNetwork - save network struct to database
 func SaveNetwork(network *models.Network) error {
+	network.Revision++
 	data, err := json.Marshal(network)
 	if err != nil {
 		return err
@@ -643,6 +686,7 @@ func networkNodesUpdateAction(networkName string, action string) error {
 		}
 		if node.Network == networkName {
 			node.Action = action
+			node.Revision++
 			data, err := json.Marshal(&node)
 			if err != nil {
 				return err
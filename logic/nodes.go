@@ -87,6 +87,7 @@ func UncordonNode(nodeid string) (models.Node, error) {
 	}
 	node.SetLastModified()
 	node.IsPending = "no"
+	node.Revision++
 	data, err := json.Marshal(&node)
 	if err != nil {
 		return node, err
@@ -140,7 +141,13 @@ func IsLeader(node *models.Node) bool {
 // == DB related functions ==
 
 // UpdateNode - takes a node and updates another node with it's values
+// fails with ErrRevisionConflict when currentNode is no longer the stored revision
 func UpdateNode(currentNode *models.Node, newNode *models.Node) error {
+	nodeUpdateMutex.Lock()
+	defer nodeUpdateMutex.Unlock()
+	if err := checkNodeRevision(currentNode); err != nil {
+		return err
+	}
 	var err error
 	if newNode.IsHub == "yes" && currentNode.IsHub != "yes" {
 		if err = unsetHub(newNode.Network); err != nil {
@@ -150,9 +157,12 @@ func UpdateNode(currentNode *models.Node, newNode *models.Node) error {
 
 	if newNode.Address != currentNode.Address {
 		if network, err := GetParentNetwork(newNode.Network); err == nil {
-			if !IsAddressInCIDR(newNode.Address, network.AddressRange) {
+			if !IsAddressInNetwork(newNode.Address, &network) {
 				return fmt.Errorf("invalid address provided; out of network range for node %s", newNode.ID)
 			}
+			if err := checkReservedAddress(network.NetID, newNode.Address, newNode.Name, newNode.MacAddress, newNode.ID); err != nil {
+				return err
+			}
 		}
 	}
 	newNode.Fill(currentNode)
@@ -165,8 +175,12 @@ func UpdateNode(currentNode *models.Node, newNode *models.Node) error {
 	if err := ValidateNode(newNode, true); err != nil {
 		return err
 	}
+	if err := checkRangeConflicts(changedRangeUses(currentNode, newNode), ""); err != nil {
+		return err
+	}
 	if newNode.ID == currentNode.ID {
 		newNode.SetLastModified()
+		newNode.Revision = currentNode.Revision + 1
 		if data, err := json.Marshal(newNode); err != nil {
 			return err
 		} else {
@@ -178,37 +192,51 @@ func UpdateNode(currentNode *models.Node, newNode *models.Node) error {
 
 // DeleteNodeByID - deletes a node from database or moves into delete nodes table
 func DeleteNodeByID(node *models.Node, exterminate bool) error {
+	if err := database.Transaction(func(tx *database.Tx) error {
+		return deleteNodeRecords(tx, node, exterminate)
+	}); err != nil {
+		return err
+	}
+	if servercfg.IsDNSMode() {
+		SetDNS()
+	}
+	return removeLocalServer(node)
+}
+
+// deleteNodeRecords - removes a node and its ACL as part of a transaction, keeping a tombstone unless exterminated
+func deleteNodeRecords(tx *database.Tx, node *models.Node, exterminate bool) error {
 	var err error
 	var key = node.ID
 	if !exterminate {
 		node.Action = models.NODE_DELETE
-		nodedata, err := json.Marshal(&node)
+		node.SetLastModified() // the reaper expires tombstones by their last modified time
+		tombstone := deletedNode{Node: *node}
+		if acl, err := nodeacls.FetchNodeACLTx(tx, nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID)); err == nil {
+			tombstone.ACL = acl
+		}
+		nodedata, err := json.Marshal(&tombstone)
 		if err != nil {
 			return err
 		}
-		err = database.Insert(key, string(nodedata), database.DELETED_NODES_TABLE_NAME)
+		err = tx.Insert(key, string(nodedata), database.DELETED_NODES_TABLE_NAME)
 		if err != nil {
 			return err
 		}
 	} else {
-		if err := database.DeleteRecord(database.DELETED_NODES_TABLE_NAME, key); err != nil {
+		if err := tx.DeleteRecord(database.DELETED_NODES_TABLE_NAME, key); err != nil {
 			logger.Log(2, err.Error())
 		}
 	}
-	if err = database.DeleteRecord(database.NODES_TABLE_NAME, key); err != nil {
+	if err = tx.DeleteRecord(database.NODES_TABLE_NAME, key); err != nil {
 		return err
 	}
-	if servercfg.IsDNSMode() {
-		SetDNS()
-	}
 
-	_, err = nodeacls.RemoveNodeACL(nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID))
+	_, err = nodeacls.RemoveNodeACLTx(tx, nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID))
 	if err != nil {
 		// ignoring for now, could hit a nil pointer if delete called twice
 		logger.Log(2, "attempted to remove node ACL for node", node.Name, node.ID)
 	}
-
-	return removeLocalServer(node)
+	return nil
 }
 
 // IsNodeIDUnique - checks if node id is unique
@@ -242,9 +270,10 @@ func ValidateNode(node *models.Node, isUpdate bool) error {
 	_ = v.RegisterValidation("checkyesorno", func(fl validator.FieldLevel) bool {
 		return validation.CheckYesOrNo(fl)
 	})
-	err := v.Struct(node)
-
-	return err
+	if err := v.Struct(node); err != nil {
+		return err
+	}
+	return node.Labels.Validate()
 }
 
 // CreateNode - creates a node in database
@@ -278,25 +307,30 @@ func CreateNode(node *models.Node) error {
 		}
 	}
 
+	// the ID of a joining node is replaced below, so reservations for a node ID only match nodes that already exist
 	reverse := node.IsServer == "yes"
 	if node.Address == "" {
 		if parentNetwork.IsIPv4 == "yes" {
-			if node.Address, err = UniqueAddress(node.Network, reverse); err != nil {
+			if node.Address, err = allocateAddress(node.Network, node.Name, node.MacAddress, "", reverse, false); err != nil {
 				return err
 			}
 		}
 	} else if !IsIPUnique(node.Network, node.Address, database.NODES_TABLE_NAME, false) {
 		return fmt.Errorf("invalid address: ipv4 " + node.Address + " is not unique")
+	} else if err = checkReservedAddress(node.Network, node.Address, node.Name, node.MacAddress, ""); err != nil {
+		return err
 	}
 
 	if node.Address6 == "" {
 		if parentNetwork.IsIPv6 == "yes" {
-			if node.Address6, err = UniqueAddress6(node.Network, reverse); err != nil {
+			if node.Address6, err = allocateAddress(node.Network, node.Name, node.MacAddress, "", reverse, true); err != nil {
 				return err
 			}
 		}
 	} else if !IsIPUnique(node.Network, node.Address6, database.NODES_TABLE_NAME, true) {
 		return fmt.Errorf("invalid address: ipv6 " + node.Address6 + " is not unique")
+	} else if err = checkReservedAddress(node.Network, node.Address6, node.Name, node.MacAddress, ""); err != nil {
+		return err
 	}
 
 	node.ID = uuid.NewString()
@@ -316,21 +350,23 @@ func CreateNode(node *models.Node) error {
 	if err != nil {
 		return err
 	}
-	err = database.Insert(node.ID, string(nodebytes), database.NODES_TABLE_NAME)
-	if err != nil {
-		return err
-	}
-
-	_, err = nodeacls.CreateNodeACL(nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID), defaultACLVal)
+	err = database.Transaction(func(tx *database.Tx) error {
+		if err := tx.Insert(node.ID, string(nodebytes), database.NODES_TABLE_NAME); err != nil {
+			return err
+		}
+		if _, err := nodeacls.CreateNodeACLTx(tx, nodeacls.NetworkID(node.Network), nodeacls.NodeID(node.ID), defaultACLVal); err != nil {
+			logger.Log(1, "failed to create node ACL for node,", node.ID, "err:", err.Error())
+			return err
+		}
+		if node.IsPending != "yes" {
+			decrimentKey(tx, node.Network, node.AccessKey)
+		}
+		setNetworkNodesLastModified(tx, node.Network)
+		return nil
+	})
 	if err != nil {
-		logger.Log(1, "failed to create node ACL for node,", node.ID, "err:", err.Error())
 		return err
 	}
-
-	if node.IsPending != "yes" {
-		DecrimentKey(node.Network, node.AccessKey)
-	}
-	SetNetworkNodesLastModified(node.Network)
 	if servercfg.IsDNSMode() {
 		err = SetDNS()
 	}
@@ -340,6 +376,9 @@ func CreateNode(node *models.Node) error {
 // GetAllNodes - returns all nodes in the DB
 func GetAllNodes() ([]models.Node, error) {
 	var nodes []models.Node
+	if nodes, ok := cachedNodes(); ok {
+		return nodes, nil
+	}
 
 	collection, err := database.FetchRecords(database.NODES_TABLE_NAME)
 	if err != nil {
@@ -556,6 +595,9 @@ func GetNodeRelay(network string, relayedNodeAddr string) (models.Node, error) {
 }
 
 func GetNodeByID(uuid string) (models.Node, error) {
+	if node, ok := cachedNode(uuid); ok {
+		return node, nil
+	}
 	var record, err = database.FetchRecord(database.NODES_TABLE_NAME, uuid)
 	if err != nil {
 		return models.Node{}, err
@@ -669,6 +711,7 @@ func unsetHub(networkName string) error {
 	for i := range nodes {
 		if nodes[i].IsHub == "yes" {
 			nodes[i].IsHub = "no"
+			nodes[i].Revision++
 			newNodeData, err := json.Marshal(&nodes[i])
 			if err != nil {
 				logger.Log(1, "error on node during hub update")
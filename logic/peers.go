@@ -84,7 +84,7 @@ func GetNodePeers(network *models.Network, nodeid string, excludeRelayed bool, i
 				peer.ListenPort = node.LocalListenPort
 			}
 			if node.IsRelay == "yes" {
-				peer.AllowedIPs = append(peer.AllowedIPs, network.AddressRange)
+				peer.AllowedIPs = append(peer.AllowedIPs, network.AddressRanges()...)
 				for _, egressNode := range egressNetworkNodes {
 					if egressNode.IsRelayed == "yes" && StringSliceContains(node.RelayAddrs, egressNode.Address) {
 						peer.AllowedIPs = append(peer.AllowedIPs, egressNode.EgressGatewayRanges...)
@@ -140,7 +140,7 @@ func GetPeersList(refnode *models.Node) ([]models.Node, error) {
 			var peerNode = setPeerInfo(&relayNode)
 			network, err := GetNetwork(networkName)
 			if err == nil {
-				peerNode.AllowedIPs = append(peerNode.AllowedIPs, network.AddressRange)
+				peerNode.AllowedIPs = append(peerNode.AllowedIPs, network.AddressRanges()...)
 				var _, egressNetworkNodes, err = getNetworkEgressAndNodes(networkName)
 				if err == nil {
 					for _, egress := range egressNetworkNodes {
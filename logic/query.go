@@ -0,0 +1,346 @@
+package logic
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrInvalidQuery - returned when a list query has an unknown filter, sort key or cursor
+var ErrInvalidQuery = errors.New("invalid query")
+
+// node roles a listing can be filtered by
+const (
+	NODE_ROLE_INGRESS = "ingress"
+	NODE_ROLE_EGRESS  = "egress"
+	NODE_ROLE_RELAY   = "relay"
+	NODE_ROLE_RELAYED = "relayed"
+	NODE_ROLE_SERVER  = "server"
+)
+
+// ListOptions - ordering and cursor pagination of a listing, a zero Limit returns every record
+type ListOptions struct {
+	Sort   string
+	Desc   bool
+	Cursor string
+	Limit  int
+}
+
+// PageInfo - position of a page in a listing, NextCursor is empty on the last page
+type PageInfo struct {
+	Total      int
+	NextCursor string
+}
+
+// NodeQuery - filters, ordering and pagination of a node listing
+// Pending is "yes" or "no", CheckinWithin and CheckinOlder bound the age of the last check in,
+// Selector is a label selector such as role=db,site!=office-a
+type NodeQuery struct {
+	ListOptions
+	OS            string
+	Version       string
+	Pending       string
+	Role          string
+	CheckinWithin time.Duration
+	CheckinOlder  time.Duration
+	Name          string
+	Selector      string
+}
+
+// ExtClientQuery - filters, ordering and pagination of an ext client listing
+type ExtClientQuery struct {
+	ListOptions
+	Name    string
+	Gateway string
+	Enabled string
+}
+
+// DNSQuery - filters, ordering and pagination of a dns listing
+type DNSQuery struct {
+	ListOptions
+	Name string
+}
+
+// sortValue - value of a record's sort key, numbers order before strings
+type sortValue struct {
+	Num int64  `json:"n,omitempty"`
+	Str string `json:"s,omitempty"`
+}
+
+// queryCursor - position after which the next page starts
+type queryCursor struct {
+	Sort  string    `json:"sort"`
+	Value sortValue `json:"value"`
+	ID    string    `json:"id"`
+}
+
+// queryItem - a matching record with its sort key and unique id
+type queryItem struct {
+	index int
+	value sortValue
+	id    string
+}
+
+var nodeSortKeys = map[string]func(*models.Node) sortValue{
+	"name":         func(n *models.Node) sortValue { return sortValue{Str: strings.ToLower(n.Name)} },
+	"id":           func(n *models.Node) sortValue { return sortValue{Str: n.ID} },
+	"network":      func(n *models.Node) sortValue { return sortValue{Str: n.Network} },
+	"os":           func(n *models.Node) sortValue { return sortValue{Str: n.OS} },
+	"version":      func(n *models.Node) sortValue { return sortValue{Str: n.Version} },
+	"address":      func(n *models.Node) sortValue { return addressSortValue(n.Address) },
+	"lastcheckin":  func(n *models.Node) sortValue { return sortValue{Num: n.LastCheckIn} },
+	"lastmodified": func(n *models.Node) sortValue { return sortValue{Num: n.LastModified} },
+}
+
+var extClientSortKeys = map[string]func(*models.ExtClient) sortValue{
+	"clientid":     func(c *models.ExtClient) sortValue { return sortValue{Str: strings.ToLower(c.ClientID)} },
+	"network":      func(c *models.ExtClient) sortValue { return sortValue{Str: c.Network} },
+	"address":      func(c *models.ExtClient) sortValue { return addressSortValue(c.Address) },
+	"gateway":      func(c *models.ExtClient) sortValue { return sortValue{Str: c.IngressGatewayID} },
+	"lastmodified": func(c *models.ExtClient) sortValue { return sortValue{Num: c.LastModified} },
+}
+
+var dnsSortKeys = map[string]func(*models.DNSEntry) sortValue{
+	"name":    func(e *models.DNSEntry) sortValue { return sortValue{Str: strings.ToLower(e.Name)} },
+	"network": func(e *models.DNSEntry) sortValue { return sortValue{Str: e.Network} },
+	"address": func(e *models.DNSEntry) sortValue { return addressSortValue(e.Address) },
+}
+
+// QueryNodes - filters, sorts and pages nodes, sorting by name by default
+func QueryNodes(nodes []models.Node, query NodeQuery) ([]models.Node, PageInfo, error) {
+	if query.Sort == "" {
+		query.Sort = "name"
+	}
+	sortKey, ok := nodeSortKeys[query.Sort]
+	if !ok {
+		return nil, PageInfo{}, fmt.Errorf("%w: unknown node sort key %s", ErrInvalidQuery, query.Sort)
+	}
+	if query.Pending != "" && query.Pending != "yes" && query.Pending != "no" {
+		return nil, PageInfo{}, fmt.Errorf("%w: pending must be yes or no", ErrInvalidQuery)
+	}
+	if query.Role != "" && !isNodeRole(query.Role) {
+		return nil, PageInfo{}, fmt.Errorf("%w: unknown node role %s", ErrInvalidQuery, query.Role)
+	}
+	selector, err := models.ParseLabelSelector(query.Selector)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("%w: %s", ErrInvalidQuery, err.Error())
+	}
+	now := time.Now()
+	var items []queryItem
+	for i := range nodes {
+		if !nodeMatches(&nodes[i], &query, now) || !selector.Matches(nodes[i].Labels) {
+			continue
+		}
+		items = append(items, queryItem{index: i, value: sortKey(&nodes[i]), id: nodes[i].ID})
+	}
+	items, page, err := paginate(items, query.ListOptions)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	var result = make([]models.Node, 0, len(items))
+	for _, item := range items {
+		result = append(result, nodes[item.index])
+	}
+	return result, page, nil
+}
+
+// QueryExtClients - filters, sorts and pages ext clients, sorting by client id by default
+func QueryExtClients(extclients []models.ExtClient, query ExtClientQuery) ([]models.ExtClient, PageInfo, error) {
+	if query.Sort == "" {
+		query.Sort = "clientid"
+	}
+	sortKey, ok := extClientSortKeys[query.Sort]
+	if !ok {
+		return nil, PageInfo{}, fmt.Errorf("%w: unknown ext client sort key %s", ErrInvalidQuery, query.Sort)
+	}
+	if query.Enabled != "" && query.Enabled != "yes" && query.Enabled != "no" {
+		return nil, PageInfo{}, fmt.Errorf("%w: enabled must be yes or no", ErrInvalidQuery)
+	}
+	var items []queryItem
+	for i := range extclients {
+		client := &extclients[i]
+		if query.Name != "" && !containsFold(client.ClientID, query.Name) {
+			continue
+		}
+		if query.Gateway != "" && client.IngressGatewayID != query.Gateway {
+			continue
+		}
+		if query.Enabled != "" && client.Enabled != (query.Enabled == "yes") {
+			continue
+		}
+		items = append(items, queryItem{index: i, value: sortKey(client), id: client.Network + "/" + client.ClientID})
+	}
+	items, page, err := paginate(items, query.ListOptions)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	var result = make([]models.ExtClient, 0, len(items))
+	for _, item := range items {
+		result = append(result, extclients[item.index])
+	}
+	return result, page, nil
+}
+
+// QueryDNS - filters, sorts and pages dns entries, sorting by name by default
+func QueryDNS(entries []models.DNSEntry, query DNSQuery) ([]models.DNSEntry, PageInfo, error) {
+	if query.Sort == "" {
+		query.Sort = "name"
+	}
+	sortKey, ok := dnsSortKeys[query.Sort]
+	if !ok {
+		return nil, PageInfo{}, fmt.Errorf("%w: unknown dns sort key %s", ErrInvalidQuery, query.Sort)
+	}
+	var items []queryItem
+	for i := range entries {
+		if query.Name != "" && !containsFold(entries[i].Name, query.Name) {
+			continue
+		}
+		items = append(items, queryItem{index: i, value: sortKey(&entries[i]), id: entries[i].Name + "." + entries[i].Network})
+	}
+	items, page, err := paginate(items, query.ListOptions)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	var result = make([]models.DNSEntry, 0, len(items))
+	for _, item := range items {
+		result = append(result, entries[item.index])
+	}
+	return result, page, nil
+}
+
+// nodeMatches - checks a node against the filters of a query
+func nodeMatches(node *models.Node, query *NodeQuery, now time.Time) bool {
+	if query.OS != "" && !strings.EqualFold(node.OS, query.OS) {
+		return false
+	}
+	if query.Version != "" && node.Version != query.Version {
+		return false
+	}
+	if query.Pending != "" && (node.IsPending == "yes") != (query.Pending == "yes") {
+		return false
+	}
+	if query.Role != "" && !nodeHasRole(node, query.Role) {
+		return false
+	}
+	if query.CheckinWithin > 0 && node.LastCheckIn < now.Add(-query.CheckinWithin).Unix() {
+		return false
+	}
+	if query.CheckinOlder > 0 && node.LastCheckIn >= now.Add(-query.CheckinOlder).Unix() {
+		return false
+	}
+	if query.Name != "" && !containsFold(node.Name, query.Name) {
+		return false
+	}
+	return true
+}
+
+func isNodeRole(role string) bool {
+	switch role {
+	case NODE_ROLE_INGRESS, NODE_ROLE_EGRESS, NODE_ROLE_RELAY, NODE_ROLE_RELAYED, NODE_ROLE_SERVER:
+		return true
+	}
+	return false
+}
+
+func nodeHasRole(node *models.Node, role string) bool {
+	switch role {
+	case NODE_ROLE_INGRESS:
+		return node.IsIngressGateway == "yes"
+	case NODE_ROLE_EGRESS:
+		return node.IsEgressGateway == "yes"
+	case NODE_ROLE_RELAY:
+		return node.IsRelay == "yes"
+	case NODE_ROLE_RELAYED:
+		return node.IsRelayed == "yes"
+	case NODE_ROLE_SERVER:
+		return node.IsServer == "yes"
+	}
+	return false
+}
+
+// paginate - sorts matching records by their sort key then id and returns the page after the cursor
+func paginate(items []queryItem, options ListOptions) ([]queryItem, PageInfo, error) {
+	if options.Limit < 0 {
+		return nil, PageInfo{}, fmt.Errorf("%w: limit must not be negative", ErrInvalidQuery)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return itemBefore(&items[i], &items[j], options.Desc)
+	})
+	page := PageInfo{Total: len(items)}
+	if options.Cursor != "" {
+		cursor, err := decodeCursor(options.Cursor)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+		if cursor.Sort != options.Sort {
+			return nil, PageInfo{}, fmt.Errorf("%w: cursor was issued for sort key %s", ErrInvalidQuery, cursor.Sort)
+		}
+		after := queryItem{value: cursor.Value, id: cursor.ID}
+		start := sort.Search(len(items), func(i int) bool {
+			return itemBefore(&after, &items[i], options.Desc)
+		})
+		items = items[start:]
+	}
+	if options.Limit > 0 && len(items) > options.Limit {
+		items = items[:options.Limit]
+		last := items[len(items)-1]
+		page.NextCursor = encodeCursor(queryCursor{Sort: options.Sort, Value: last.value, ID: last.id})
+	}
+	return items, page, nil
+}
+
+// itemBefore - orders items by sort key then id, so records with equal keys keep a stable order across pages
+func itemBefore(a, b *queryItem, desc bool) bool {
+	cmp := compareSortValues(a.value, b.value)
+	if cmp == 0 {
+		cmp = strings.Compare(a.id, b.id)
+	}
+	if desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// addressSortValue - an address as the hex of its bytes, so addresses sort in numeric order like compareAddresses
+func addressSortValue(address string) sortValue {
+	return sortValue{Str: hex.EncodeToString(net.ParseIP(address).To16())}
+}
+
+func compareSortValues(a, b sortValue) int {
+	switch {
+	case a.Num < b.Num:
+		return -1
+	case a.Num > b.Num:
+		return 1
+	}
+	return strings.Compare(a.Str, b.Str)
+}
+
+func encodeCursor(cursor queryCursor) string {
+	data, _ := json.Marshal(&cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (queryCursor, error) {
+	var cursor queryCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return cursor, nil
+}
+
+func containsFold(value, substr string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substr))
+}
@@ -0,0 +1,147 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestQueryNodes(t *testing.T) {
+	now := time.Now().Unix()
+	var nodes []models.Node
+	for i := 0; i < 10; i++ {
+		node := models.Node{
+			ID:          fmt.Sprintf("node-%02d", i),
+			Name:        fmt.Sprintf("host%d", i%5),
+			OS:          "linux",
+			Version:     "v0.10.0",
+			IsPending:   "no",
+			LastCheckIn: now - int64(i)*60,
+		}
+		if i%2 == 1 {
+			node.OS = "windows"
+		}
+		if i == 3 {
+			node.IsPending = "yes"
+			node.IsIngressGateway = "yes"
+		}
+		if i < 4 {
+			node.Labels = models.Labels{"role": "db", "site": fmt.Sprintf("office-%d", i%2)}
+		}
+		nodes = append(nodes, node)
+	}
+
+	t.Run("Filters", func(t *testing.T) {
+		result, page, err := QueryNodes(nodes, NodeQuery{OS: "Windows"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 5 || page.Total != 5 || page.NextCursor != "" {
+			t.Fatalf("expected 5 windows nodes on one page, got %d total %d cursor %q", len(result), page.Total, page.NextCursor)
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{Pending: "yes"})
+		if len(result) != 1 || result[0].ID != "node-03" {
+			t.Fatalf("expected pending node-03, got %+v", result)
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{Role: NODE_ROLE_INGRESS})
+		if len(result) != 1 || result[0].ID != "node-03" {
+			t.Fatalf("expected ingress node-03, got %+v", result)
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{CheckinWithin: 150 * time.Second})
+		if len(result) != 3 {
+			t.Fatalf("expected 3 nodes checked in recently, got %d", len(result))
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{CheckinOlder: 330 * time.Second})
+		if len(result) != 4 {
+			t.Fatalf("expected 4 stale nodes, got %d", len(result))
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{Name: "HOST2"})
+		if len(result) != 2 {
+			t.Fatalf("expected 2 nodes named host2, got %d", len(result))
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{Selector: "role=db,site!=office-1"})
+		if len(result) != 2 || result[0].ID != "node-00" || result[1].ID != "node-02" {
+			t.Fatalf("expected the db nodes outside office-1, got %+v", result)
+		}
+		result, _, _ = QueryNodes(nodes, NodeQuery{Selector: "!role"})
+		if len(result) != 6 {
+			t.Fatalf("expected 6 nodes without a role, got %d", len(result))
+		}
+	})
+	t.Run("Pages", func(t *testing.T) {
+		for _, desc := range []bool{false, true} {
+			query := NodeQuery{ListOptions: ListOptions{Sort: "name", Desc: desc, Limit: 3}}
+			var seen []string
+			for {
+				result, page, err := QueryNodes(nodes, query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, node := range result {
+					seen = append(seen, node.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			if len(seen) != len(nodes) {
+				t.Fatalf("expected %d nodes across pages, got %v", len(nodes), seen)
+			}
+			first, last := "node-00", "node-09"
+			if desc {
+				first, last = last, first
+			}
+			if seen[0] != first || seen[len(seen)-1] != last {
+				t.Fatalf("unexpected order %v", seen)
+			}
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		if _, _, err := QueryNodes(nodes, NodeQuery{ListOptions: ListOptions{Sort: "color"}}); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected invalid sort key, got %v", err)
+		}
+		if _, _, err := QueryNodes(nodes, NodeQuery{ListOptions: ListOptions{Cursor: "???"}}); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected malformed cursor, got %v", err)
+		}
+		if _, _, err := QueryNodes(nodes, NodeQuery{Selector: "role=db=web"}); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected malformed selector, got %v", err)
+		}
+		_, page, _ := QueryNodes(nodes, NodeQuery{ListOptions: ListOptions{Sort: "name", Limit: 1}})
+		if _, _, err := QueryNodes(nodes, NodeQuery{ListOptions: ListOptions{Sort: "lastcheckin", Cursor: page.NextCursor}}); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected cursor of another sort key to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestQueryDNS(t *testing.T) {
+	entries := []models.DNSEntry{
+		{Name: "web", Network: "b", Address: "10.0.0.10"},
+		{Name: "db", Network: "a", Address: "10.0.0.1"},
+		{Name: "web", Network: "a", Address: "10.0.0.2"},
+	}
+	result, page, err := QueryDNS(entries, DNSQuery{ListOptions: ListOptions{Limit: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Name != "db" || result[1].Network != "a" || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v %+v", result, page)
+	}
+	result, page, err = QueryDNS(entries, DNSQuery{ListOptions: ListOptions{Limit: 2, Cursor: page.NextCursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Network != "b" || page.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v %+v", result, page)
+	}
+	result, _, err = QueryDNS(entries, DNSQuery{ListOptions: ListOptions{Sort: "address"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 3 || result[0].Address != "10.0.0.1" || result[1].Address != "10.0.0.2" || result[2].Address != "10.0.0.10" {
+		t.Fatalf("expected addresses in numeric order, got %+v", result)
+	}
+}
@@ -30,16 +30,20 @@ func CreateRelay(relay models.RelayRequest) ([]models.Node, models.Node, error)
 	node.RelayAddrs = relay.RelayAddrs
 
 	node.SetLastModified()
+	node.Revision++
 	nodeData, err := json.Marshal(&node)
 	if err != nil {
 		return returnnodes, node, err
 	}
-	if err = database.Insert(node.ID, string(nodeData), database.NODES_TABLE_NAME); err != nil {
-		return returnnodes, models.Node{}, err
-	}
-	returnnodes, err = SetRelayedNodes("yes", node.Network, node.RelayAddrs)
+	err = database.Transaction(func(tx *database.Tx) error {
+		if err := tx.Insert(node.ID, string(nodeData), database.NODES_TABLE_NAME); err != nil {
+			return err
+		}
+		returnnodes, err = setRelayedNodes(tx, "yes", node.Network, node.RelayAddrs)
+		return err
+	})
 	if err != nil {
-		return returnnodes, node, err
+		return returnnodes, models.Node{}, err
 	}
 	if err = NetworkNodesUpdatePullChanges(node.Network); err != nil {
 		return returnnodes, models.Node{}, err
@@ -49,8 +53,13 @@ func CreateRelay(relay models.RelayRequest) ([]models.Node, models.Node, error)
 
 // SetRelayedNodes- set relayed nodes
 func SetRelayedNodes(yesOrno string, networkName string, addrs []string) ([]models.Node, error) {
+	return setRelayedNodes(nil, yesOrno, networkName, addrs)
+}
+
+// setRelayedNodes - set relayed nodes as part of a transaction
+func setRelayedNodes(tx *database.Tx, yesOrno string, networkName string, addrs []string) ([]models.Node, error) {
 	var returnnodes []models.Node
-	collections, err := database.FetchRecords(database.NODES_TABLE_NAME)
+	collections, err := tx.FetchRecords(database.NODES_TABLE_NAME)
 	if err != nil {
 		return returnnodes, err
 	}
@@ -75,11 +84,14 @@ func SetRelayedNodes(yesOrno string, networkName string, addrs []string) ([]mode
 					} else {
 						node.UDPHolePunch = network.DefaultUDPHolePunch
 					}
+					node.Revision++
 					data, err := json.Marshal(&node)
 					if err != nil {
 						return returnnodes, err
 					}
-					database.Insert(node.ID, string(data), database.NODES_TABLE_NAME)
+					if err = tx.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+						return returnnodes, err
+					}
 					returnnodes = append(returnnodes, node)
 				}
 			}
@@ -104,6 +116,7 @@ func SetNodeIsRelayed(yesOrno string, id string) (models.Node, error) {
 	} else {
 		node.UDPHolePunch = network.DefaultUDPHolePunch
 	}
+	node.Revision++
 	data, err := json.Marshal(&node)
 	if err != nil {
 		return node, err
@@ -122,24 +135,30 @@ func PeerListUnRelay(id string, network string) ([]models.Node, error) {
 
 // ValidateRelay - checks if relay is valid
 func ValidateRelay(relay models.RelayRequest) error {
-	var err error
 	//isIp := functions.IsIpCIDR(gateway.RangeString)
 	empty := len(relay.RelayAddrs) == 0
 	if empty {
-		err = errors.New("IP Ranges Cannot Be Empty")
+		return errors.New("IP Ranges Cannot Be Empty")
+	}
+	node := models.Node{ID: relay.NodeID, Network: relay.NetID, IsRelay: "yes", RelayAddrs: relay.RelayAddrs}
+	if current, err := GetNodeByID(relay.NodeID); err == nil {
+		node.Name, node.Network = current.Name, current.Network
 	}
-	return err
+	return checkRangeConflicts(nodeRangeUses(&node), "")
 }
 
 // UpdateRelay - updates a relay
 func UpdateRelay(network string, oldAddrs []string, newAddrs []string) []models.Node {
 	var returnnodes []models.Node
 	time.Sleep(time.Second / 4)
-	returnnodes, err := SetRelayedNodes("no", network, oldAddrs)
-	if err != nil {
-		logger.Log(1, err.Error())
-	}
-	returnnodes, err = SetRelayedNodes("yes", network, newAddrs)
+	err := database.Transaction(func(tx *database.Tx) error {
+		var err error
+		if _, err = setRelayedNodes(tx, "no", network, oldAddrs); err != nil {
+			return err
+		}
+		returnnodes, err = setRelayedNodes(tx, "yes", network, newAddrs)
+		return err
+	})
 	if err != nil {
 		logger.Log(1, err.Error())
 	}
@@ -153,20 +172,23 @@ func DeleteRelay(network, nodeid string) ([]models.Node, models.Node, error) {
 	if err != nil {
 		return returnnodes, models.Node{}, err
 	}
-	_, err = SetRelayedNodes("no", node.Network, node.RelayAddrs)
-	if err != nil {
-		return returnnodes, node, err
-	}
-
+	relayAddrs := node.RelayAddrs
 	node.IsRelay = "no"
 	node.RelayAddrs = []string{}
 	node.SetLastModified()
 
+	node.Revision++
 	data, err := json.Marshal(&node)
 	if err != nil {
 		return returnnodes, models.Node{}, err
 	}
-	if err = database.Insert(nodeid, string(data), database.NODES_TABLE_NAME); err != nil {
+	err = database.Transaction(func(tx *database.Tx) error {
+		if _, err := setRelayedNodes(tx, "no", node.Network, relayAddrs); err != nil {
+			return err
+		}
+		return tx.Insert(nodeid, string(data), database.NODES_TABLE_NAME)
+	})
+	if err != nil {
 		return returnnodes, models.Node{}, err
 	}
 	if err = NetworkNodesUpdatePullChanges(network); err != nil {
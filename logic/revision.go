@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrRevisionConflict - returned when a record was modified since it was read
+var ErrRevisionConflict = errors.New("record was modified since it was read, reload and retry")
+
+var (
+	nodeUpdateMutex    sync.Mutex
+	networkUpdateMutex sync.Mutex
+)
+
+// checkNodeRevision - ensures the stored node is still at the revision that was read, caller must hold nodeUpdateMutex
+func checkNodeRevision(node *models.Node) error {
+	stored, err := GetNodeByID(node.ID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	if stored.Revision != node.Revision {
+		return ErrRevisionConflict
+	}
+	return nil
+}
+
+// checkNetworkRevision - ensures the stored network is still at the revision that was read, caller must hold networkUpdateMutex
+func checkNetworkRevision(network *models.Network) error {
+	stored, err := GetNetwork(network.NetID)
+	if err != nil {
+		if database.IsEmptyRecord(err) {
+			return nil
+		}
+		return err
+	}
+	if stored.Revision != network.Revision {
+		return ErrRevisionConflict
+	}
+	return nil
+}
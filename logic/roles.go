@@ -0,0 +1,163 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+// permissions granted on a network by the roles of users
+const (
+	// PERMISSION_VIEW - read the network, its nodes, DNS and ACLs
+	PERMISSION_VIEW = "view"
+	// PERMISSION_EXT_CLIENT_SELF - create ext clients and manage the ones the user owns
+	PERMISSION_EXT_CLIENT_SELF = "extclient-self"
+	// PERMISSION_EXT_CLIENT_MANAGE - manage every ext client of the network
+	PERMISSION_EXT_CLIENT_MANAGE = "extclient-manage"
+	// PERMISSION_NODE_OPERATE - update and approve nodes and manage their gateways and relays
+	PERMISSION_NODE_OPERATE = "node-operate"
+	// PERMISSION_NETWORK_MANAGE - change the network settings, ACLs, DNS and access keys and delete nodes
+	PERMISSION_NETWORK_MANAGE = "network-manage"
+	// PERMISSION_NETWORK_OWNER - delete the network, rotate its keys and give roles on it
+	PERMISSION_NETWORK_OWNER = "network-owner"
+)
+
+// rolePermissions - the permissions of each network role, each role builds on the one below it
+var rolePermissions = map[string][]string{
+	models.NETWORK_ROLE_EXT_CLIENT_SELF_SERVICE: {PERMISSION_EXT_CLIENT_SELF},
+	models.NETWORK_ROLE_VIEWER:                  {PERMISSION_VIEW},
+	models.NETWORK_ROLE_OPERATOR:                {PERMISSION_VIEW, PERMISSION_EXT_CLIENT_SELF, PERMISSION_EXT_CLIENT_MANAGE, PERMISSION_NODE_OPERATE},
+	models.NETWORK_ROLE_ADMIN:                   {PERMISSION_VIEW, PERMISSION_EXT_CLIENT_SELF, PERMISSION_EXT_CLIENT_MANAGE, PERMISSION_NODE_OPERATE, PERMISSION_NETWORK_MANAGE},
+	models.NETWORK_ROLE_OWNER:                   {PERMISSION_VIEW, PERMISSION_EXT_CLIENT_SELF, PERMISSION_EXT_CLIENT_MANAGE, PERMISSION_NODE_OPERATE, PERMISSION_NETWORK_MANAGE, PERMISSION_NETWORK_OWNER},
+}
+
+// IsValidNetworkRole - checks a role can be given on a network
+func IsValidNetworkRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasNetworkPermission - checks the role of a user on a network grants a permission, admins have every permission
+func HasNetworkPermission(user *models.ReturnUser, network string, permission string) bool {
+	if user.IsAdmin {
+		return true
+	}
+	return StringSliceContains(rolePermissions[user.NetworkRoles[network]], permission)
+}
+
+// NetworksWithPermission - the networks a user has a permission on, in name order, admins have it on every network
+func NetworksWithPermission(user *models.ReturnUser, permission string) []string {
+	var networks = []string{}
+	if user.IsAdmin {
+		allNetworks, _ := GetNetworks()
+		for _, network := range allNetworks {
+			networks = append(networks, network.NetID)
+		}
+		sort.Strings(networks)
+		return networks
+	}
+	for network := range user.NetworkRoles {
+		if HasNetworkPermission(user, network, permission) {
+			networks = append(networks, network)
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// SetUserNetworkRole - gives a user a role on a network, or removes their access to it if role is empty
+// returns the user as it was and as it is saved
+func SetUserNetworkRole(username string, network string, role string) (models.ReturnUser, models.ReturnUser, error) {
+	stored, err := getStoredUser(username)
+	if err != nil {
+		return models.ReturnUser{}, models.ReturnUser{}, err
+	}
+	before := stored.returnUser()
+	if stored.IsAdmin {
+		return before, before, fmt.Errorf("%s is an admin and already has every role", username)
+	}
+	roles := make(map[string]string, len(stored.NetworkRoles)+1)
+	for roleNetwork, networkRole := range stored.NetworkRoles {
+		roles[roleNetwork] = networkRole
+	}
+	if role == "" {
+		if _, ok := roles[network]; !ok {
+			return before, before, fmt.Errorf("%s has no role on network %s", username, network)
+		}
+		delete(roles, network)
+	} else {
+		if _, err := GetParentNetwork(network); err != nil {
+			return before, before, errors.New("network " + network + " does not exist")
+		}
+		roles[network] = role
+	}
+	if err = stored.setNetworkRoles(roles); err != nil {
+		return before, before, err
+	}
+	return before, stored.returnUser(), stored.save()
+}
+
+// storedUser - a user as stored, its network roles are kept beside the fields of models.User
+type storedUser struct {
+	models.User
+	NetworkRoles map[string]string `json:"networkroles"`
+}
+
+// getStoredUser - gets a user with its network roles
+func getStoredUser(username string) (storedUser, error) {
+	var user storedUser
+	record, err := database.FetchRecord(database.USERS_TABLE_NAME, username)
+	if err != nil {
+		return user, err
+	}
+	err = json.Unmarshal([]byte(record), &user)
+	return user, err
+}
+
+// storedUser.save - writes a user and its network roles
+func (user *storedUser) save() error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME)
+}
+
+// storedUser.returnUser - the user as returned by the API, without its password
+func (user *storedUser) returnUser() models.ReturnUser {
+	return models.ReturnUser{UserName: user.UserName, Networks: user.Networks, IsAdmin: user.IsAdmin, NetworkRoles: user.NetworkRoles}
+}
+
+// storedUser.setNetworkRoles - validates and sets the roles of a user, keeping its networks in step
+func (user *storedUser) setNetworkRoles(roles map[string]string) error {
+	for network, role := range roles {
+		if !IsValidNetworkRole(role) {
+			return fmt.Errorf("unknown role %s on network %s", role, network)
+		}
+	}
+	user.NetworkRoles = roles
+	user.Networks = make([]string, 0, len(roles))
+	for network := range roles {
+		user.Networks = append(user.Networks, network)
+	}
+	sort.Strings(user.Networks)
+	return nil
+}
+
+// storedUser.setNetworks - sets the networks of a user from a plain list, as given before roles existed,
+// keeping the roles of networks already held and making the user a network admin of new ones
+func (user *storedUser) setNetworks(networks []string) {
+	roles := make(map[string]string, len(networks))
+	for _, network := range networks {
+		if role, ok := user.NetworkRoles[network]; ok {
+			roles[network] = role
+		} else {
+			roles[network] = models.NETWORK_ROLE_ADMIN
+		}
+	}
+	user.setNetworkRoles(roles)
+}
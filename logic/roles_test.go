@@ -0,0 +1,72 @@
+package logic
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestNetworkRoles(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+
+	t.Run("Permissions", func(t *testing.T) {
+		user := models.ReturnUser{UserName: "operator", NetworkRoles: map[string]string{"net1": models.NETWORK_ROLE_OPERATOR, "net2": models.NETWORK_ROLE_VIEWER}}
+		if !HasNetworkPermission(&user, "net1", PERMISSION_NODE_OPERATE) || HasNetworkPermission(&user, "net1", PERMISSION_NETWORK_MANAGE) {
+			t.Fatal("expected an operator to operate nodes but not manage the network")
+		}
+		if HasNetworkPermission(&user, "net3", PERMISSION_VIEW) {
+			t.Fatal("expected no permission on a network without a role")
+		}
+		if networks := NetworksWithPermission(&user, PERMISSION_VIEW); len(networks) != 2 || networks[0] != "net1" || networks[1] != "net2" {
+			t.Fatalf("expected both networks to be viewable, got %v", networks)
+		}
+		admin := models.ReturnUser{UserName: "admin", IsAdmin: true}
+		if !HasNetworkPermission(&admin, "net3", PERMISSION_NETWORK_OWNER) {
+			t.Fatal("expected an admin to have every permission")
+		}
+	})
+	t.Run("SetNetworks", func(t *testing.T) {
+		user := storedUser{User: models.User{UserName: "viewer"}, NetworkRoles: map[string]string{"net1": models.NETWORK_ROLE_VIEWER}}
+		user.setNetworks([]string{"net2", "net1"})
+		if user.NetworkRoles["net1"] != models.NETWORK_ROLE_VIEWER || user.NetworkRoles["net2"] != models.NETWORK_ROLE_ADMIN {
+			t.Fatalf("expected the existing role to be kept and the new network given network admin, got %v", user.NetworkRoles)
+		}
+		if len(user.Networks) != 2 || user.Networks[0] != "net1" || user.Networks[1] != "net2" {
+			t.Fatalf("expected networks to follow the roles, got %v", user.Networks)
+		}
+		if err := user.setNetworkRoles(map[string]string{"net1": "superuser"}); err == nil {
+			t.Fatal("expected an unknown role to be refused")
+		}
+	})
+	t.Run("SetUserNetworkRole", func(t *testing.T) {
+		member := storedUser{User: models.User{UserName: "member", Password: "hash"}}
+		member.setNetworks([]string{"net1"})
+		if err := member.save(); err != nil {
+			t.Fatal(err)
+		}
+		insertUser(t, models.User{UserName: "admin", Password: "hash", IsAdmin: true})
+		if _, _, err := SetUserNetworkRole("admin", "net1", models.NETWORK_ROLE_VIEWER); err == nil {
+			t.Fatal("expected roles of admins to be refused")
+		}
+		if _, _, err := SetUserNetworkRole("member", "missing", models.NETWORK_ROLE_VIEWER); err == nil {
+			t.Fatal("expected a role on a missing network to be refused")
+		}
+		before, after, err := SetUserNetworkRole("member", "net1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(before.Networks) != 1 || len(after.Networks) != 0 || len(after.NetworkRoles) != 0 {
+			t.Fatalf("expected access to net1 to be removed, got %+v", after)
+		}
+		if stored, _ := GetUser("member"); len(stored.Networks) != 0 || stored.Password != "hash" {
+			t.Fatalf("expected the change to be saved, got %+v", stored)
+		}
+	})
+}
@@ -66,19 +66,25 @@ func IsAddressInCIDR(address, cidr string) bool {
 
 // SetNetworkNodesLastModified - sets the network nodes last modified
 func SetNetworkNodesLastModified(networkName string) error {
+	return setNetworkNodesLastModified(nil, networkName)
+}
+
+// setNetworkNodesLastModified - sets the network nodes last modified as part of a transaction
+func setNetworkNodesLastModified(tx *database.Tx, networkName string) error {
 
 	timestamp := time.Now().Unix()
 
-	network, err := GetParentNetwork(networkName)
+	network, err := getParentNetwork(tx, networkName)
 	if err != nil {
 		return err
 	}
 	network.NodesLastModified = timestamp
+	network.Revision++
 	data, err := json.Marshal(&network)
 	if err != nil {
 		return err
 	}
-	err = database.Insert(networkName, string(data), database.NETWORKS_TABLE_NAME)
+	err = tx.Insert(networkName, string(data), database.NETWORKS_TABLE_NAME)
 	if err != nil {
 		return err
 	}
@@ -1,16 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,6 +28,7 @@ import (
 	"github.com/gravitl/netmaker/functions"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/logic/events"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	"github.com/gravitl/netmaker/netclient/ncutils"
@@ -35,10 +42,34 @@ var version = "dev"
 // Start DB Connection and start API Request Handler
 func main() {
 	absoluteConfigPath := flag.String("c", "", "absolute path to configuration file")
+	restorePath := flag.String("restore", "", "absolute path to a backup archive to restore into an empty database")
+	migrateDBPath := flag.String("migrate-db", "", "absolute path to a configuration file describing a database to copy every table into")
+	force := flag.Bool("force", false, "with -migrate-db, overwrite a destination database that already holds records")
+	rotateKeyPath := flag.String("rotate-db-key", "", "absolute path to a file holding the new base64 database encryption key, or \"none\" to store secrets in plaintext, the server must be stopped")
+	applyPath := flag.String("apply", "", "absolute path to a YAML or JSON declaration of networks to apply")
+	dryRun := flag.Bool("dry-run", false, "with -apply, only print the changes the declaration needs")
+	prune := flag.Bool("prune", false, "with -apply, remove networks, and objects of the declared lists, that the declaration does not hold")
+	apiURL := flag.String("api", "", "with -apply or -rotate-db-key, URL of the API of the server, defaults to http://127.0.0.1:<api port>")
 	flag.Parse()
 
 	setupConfig(*absoluteConfigPath)
 	servercfg.SetVersion(version)
+	if *restorePath != "" {
+		restoreBackup(*restorePath)
+		return
+	}
+	if *migrateDBPath != "" {
+		migrateDatabase(*migrateDBPath, *force)
+		return
+	}
+	if *rotateKeyPath != "" {
+		rotateEncryptionKey(*rotateKeyPath, *apiURL)
+		return
+	}
+	if *applyPath != "" {
+		applyDeclaration(*applyPath, *apiURL, *dryRun, *prune)
+		return
+	}
 	fmt.Println(models.RetrieveLogo()) // print the logo
 	initialize()                       // initial db and acls; gen cert if required
 	setGarbageCollection()
@@ -72,6 +103,12 @@ func initialize() { // Client Mode Prereq Check
 		logger.FatalLog("Error connecting to database")
 	}
 	logger.Log(0, "database successfully connected")
+	if err = logic.InitCache(); err != nil {
+		logger.FatalLog("error loading cache:", err.Error())
+	}
+	if err = events.Start(); err != nil {
+		logger.FatalLog("error starting event stream:", err.Error())
+	}
 	logic.SetJWTSecret()
 
 	err = logic.TimerCheckpoint()
@@ -131,6 +168,9 @@ func initialize() { // Client Mode Prereq Check
 
 func startControllers() {
 	var waitnetwork sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go logic.ReapDeletedNodes(ctx)
 	if servercfg.IsDNSMode() {
 		err := logic.SetDNS()
 		if err != nil {
@@ -177,6 +217,158 @@ func runMessageQueue(wg *sync.WaitGroup) {
 	client.Disconnect(250)
 }
 
+// restoreBackup - restores a backup archive into the configured database and exits
+func restoreBackup(path string) {
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("Error connecting to database:", err.Error())
+	}
+	defer database.CloseDB()
+	f, err := os.Open(path)
+	if err != nil {
+		logger.FatalLog("could not open backup archive:", err.Error())
+	}
+	defer f.Close()
+	manifest, err := logic.RestoreBackup(f, functions.GetNetmakerPath())
+	if err != nil {
+		logger.FatalLog("failed to restore backup:", err.Error())
+	}
+	logger.Log(0, "restored backup of", manifest.Database, "database into", servercfg.GetDB())
+}
+
+// migrateDatabase - copies every table of the configured database into the database described by destConfigPath and exits
+func migrateDatabase(destConfigPath string, force bool) {
+	destCfg, err := config.ReadConfig(destConfigPath)
+	if err != nil {
+		logger.FatalLog("failed parsing destination config at:", destConfigPath, err.Error())
+	}
+	if destCfg.Server.Database == "" {
+		logger.FatalLog("destination config", destConfigPath, "does not set server.database")
+	}
+	srcConn := ""
+	if servercfg.GetDB() == "rqlite" {
+		srcConn = servercfg.GetSQLConn()
+	}
+	src, err := database.NewBackend(servercfg.GetDB(), srcConn, servercfg.GetSQLConf())
+	if err != nil {
+		logger.FatalLog("invalid source database:", err.Error())
+	}
+	dst, err := database.NewBackend(destCfg.Server.Database, destCfg.Server.SQLConn, destCfg.SQL)
+	if err != nil {
+		logger.FatalLog("invalid destination database:", err.Error())
+	}
+	if err = src.Init(); err != nil {
+		logger.FatalLog("Error connecting to source database:", err.Error())
+	}
+	defer src.Close()
+	if err = dst.Init(); err != nil {
+		logger.FatalLog("Error connecting to destination database:", err.Error())
+	}
+	defer dst.Close()
+	results, err := database.CopyTables(src, dst, force)
+	for _, result := range results {
+		logger.Log(0, "copied", strconv.Itoa(result.Records), "records of table", result.Table, "checksum", result.Checksum)
+	}
+	if err != nil {
+		logger.FatalLog("database migration failed:", err.Error())
+	}
+	logger.Log(0, "copied", strconv.Itoa(len(results)), "tables from", servercfg.GetDB(), "to", destCfg.Server.Database)
+}
+
+// rotateEncryptionKey - re-encrypts the secrets in the configured database with the key in keyPath and exits
+func rotateEncryptionKey(keyPath, apiURL string) {
+	var newKey []byte
+	if keyPath != "none" {
+		var err error
+		if newKey, err = database.ReadEncryptionKeyFile(keyPath); err != nil {
+			logger.FatalLog("could not read new database encryption key:", err.Error())
+		}
+	}
+	// a running server keeps the old key and could no longer read the secrets it re-encrypts
+	if serverRunning(apiURL) {
+		logger.FatalLog("the server answers on", serverAPIURL(apiURL)+", stop it before rotating the database encryption key")
+	}
+	if err := database.InitializeDatabase(); err != nil {
+		logger.FatalLog("Error connecting to database:", err.Error())
+	}
+	defer database.CloseDB()
+	count, err := database.RotateEncryptionKey(newKey)
+	if err != nil {
+		logger.FatalLog("failed to rotate database encryption key:", err.Error())
+	}
+	if newKey == nil {
+		logger.Log(0, "decrypted", strconv.Itoa(count), "records, remove the database encryption key from the server config")
+		return
+	}
+	logger.Log(0, "re-encrypted", strconv.Itoa(count), "records, set DB_ENCRYPTION_KEY_FILE to", keyPath, "before restarting the server")
+}
+
+// applyDeclaration - sends a declaration of networks to the API of the running server with the master key,
+// printing its changes, and exits, the server applies it so its caches and nodes are kept up to date
+func applyDeclaration(path, apiURL string, dryRun, prune bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.FatalLog("could not read declaration:", err.Error())
+	}
+	if _, err = logic.ParseDeclaration(data); err != nil {
+		logger.FatalLog("invalid declaration:", err.Error())
+	}
+	if servercfg.GetMasterKey() == "" {
+		logger.FatalLog("a master key must be configured to apply a declaration through the API")
+	}
+	query := url.Values{"dryrun": {strconv.FormatBool(dryRun)}, "prune": {strconv.FormatBool(prune)}}
+	request, err := http.NewRequest(http.MethodPost, serverAPIURL(apiURL)+"/api/apply?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		logger.FatalLog("invalid API URL:", err.Error())
+	}
+	request.Header.Set("Authorization", "Bearer "+servercfg.GetMasterKey())
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		logger.FatalLog("could not reach the API of the server, it must be running to apply a declaration:", err.Error())
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		logger.FatalLog("could not read the response of the server:", err.Error())
+	}
+	var result models.DeclarationResult
+	if err = json.Unmarshal(body, &result); err == nil && (response.StatusCode == http.StatusOK || result.Error != "") {
+		if output, marshalErr := json.MarshalIndent(&result, "", "  "); marshalErr == nil {
+			fmt.Println(string(output))
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		var errorResponse models.ErrorResponse
+		if result.Error == "" && json.Unmarshal(body, &errorResponse) == nil {
+			result.Error = errorResponse.Message
+		}
+		logger.FatalLog("failed to apply declaration:", response.Status, result.Error)
+	}
+	if dryRun {
+		logger.Log(0, "declaration needs", strconv.Itoa(len(result.Changes)), "changes, none were applied")
+		return
+	}
+	logger.Log(0, "applied", strconv.Itoa(result.Applied), "changes of declaration", path)
+}
+
+// serverAPIURL - the URL of the API of the server, the local one unless another is given
+func serverAPIURL(apiURL string) string {
+	if apiURL == "" {
+		apiURL = "http://127.0.0.1:" + servercfg.GetAPIPort()
+	}
+	return strings.TrimSuffix(apiURL, "/")
+}
+
+// serverRunning - checks if a server answers the liveness probe of its API
+func serverRunning(apiURL string) bool {
+	client := http.Client{Timeout: 5 * time.Second}
+	response, err := client.Get(serverAPIURL(apiURL) + "/api/health/live")
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return true
+}
+
 func setGarbageCollection() {
 	_, gcset := os.LookupEnv("GOGC")
 	if !gcset {
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	counter := NewCounterVec("test_messages_total", "Messages.", "topic")
+	histogram := NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+	counter.WithLabelValues("ping").Inc()
+	counter.WithLabelValues(`quo"te`).Add(2)
+	histogram.WithLabelValues("/api/nodes").Observe(0.05)
+	histogram.WithLabelValues("/api/nodes").Observe(0.5)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	out := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE test_messages_total counter\n",
+		`test_messages_total{topic="ping"} 1` + "\n",
+		`test_messages_total{topic="quo\"te"} 2` + "\n",
+		`test_duration_seconds_bucket{route="/api/nodes",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{route="/api/nodes",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{route="/api/nodes",le="+Inf"} 2` + "\n",
+		`test_duration_seconds_sum{route="/api/nodes"} 0.55` + "\n",
+		`test_duration_seconds_count{route="/api/nodes"} 2` + "\n",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in\n%s", expected, out)
+		}
+	}
+}
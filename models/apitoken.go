@@ -0,0 +1,31 @@
+package models
+
+// API token scopes
+const (
+	// API_TOKEN_SCOPE_READ - may only make reads that return no secrets
+	API_TOKEN_SCOPE_READ = "read"
+	// API_TOKEN_SCOPE_NODES - may make the reads of read tokens and manage nodes
+	API_TOKEN_SCOPE_NODES = "nodes"
+	// API_TOKEN_SCOPE_ADMIN - may do anything its user can on its networks
+	API_TOKEN_SCOPE_ADMIN = "admin"
+)
+
+// APIToken - a named, revocable token acting for a user with a limited scope,
+// only the hash of its secret is stored
+type APIToken struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name" validate:"required,min=1,max=64"`
+	UserName   string   `json:"username"`
+	Scope      string   `json:"scope" validate:"required,oneof=read nodes admin"`
+	Networks   []string `json:"networks"`
+	Expiration int64    `json:"expiration"`
+	Created    int64    `json:"created"`
+	LastUsed   int64    `json:"lastused"`
+	Hash       string   `json:"hash,omitempty"`
+}
+
+// CreatedAPIToken - a newly created API token, the only time its secret is returned
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
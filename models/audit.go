@@ -0,0 +1,38 @@
+package models
+
+// audit actor types
+const (
+	AUDIT_ACTOR_USER      = "user"
+	AUDIT_ACTOR_MASTERKEY = "masterkey"
+	AUDIT_ACTOR_NODE      = "node"
+	AUDIT_ACTOR_ANONYMOUS = "anonymous"
+	AUDIT_ACTOR_SERVER    = "server"
+)
+
+// audit actions
+const (
+	AUDIT_CREATE = "create"
+	AUDIT_UPDATE = "update"
+	AUDIT_DELETE = "delete"
+)
+
+// AuditEntry - record of a change made through the API or the message queue
+type AuditEntry struct {
+	ID         string        `json:"id" bson:"id"`
+	Timestamp  int64         `json:"timestamp" bson:"timestamp"`
+	ActorType  string        `json:"actortype" bson:"actortype"`
+	Actor      string        `json:"actor" bson:"actor"`
+	Source     string        `json:"source" bson:"source"`
+	Action     string        `json:"action" bson:"action"`
+	TargetType string        `json:"targettype" bson:"targettype"`
+	Target     string        `json:"target" bson:"target"`
+	Network    string        `json:"network,omitempty" bson:"network,omitempty"`
+	Changes    []AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+
+// AuditChange - value of a field before and after a change, secrets are redacted
+type AuditChange struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After  interface{} `json:"after,omitempty" bson:"after,omitempty"`
+}
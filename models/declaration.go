@@ -0,0 +1,107 @@
+package models
+
+// kinds of objects managed by a declaration
+const (
+	DECLARED_NETWORK   = "network"
+	DECLARED_ACCESSKEY = "accesskey"
+	DECLARED_DNS       = "dns"
+	DECLARED_ACL       = "acl"
+	DECLARED_INGRESS   = "ingress"
+	DECLARED_EGRESS    = "egress"
+	DECLARED_RELAY     = "relay"
+	DECLARED_EXTCLIENT = "extclient"
+)
+
+// Declaration - the desired state of the server's networks, written as YAML or JSON
+type Declaration struct {
+	Networks []DeclaredNetwork `json:"networks" bson:"networks"`
+}
+
+// DeclaredNetwork - a network and the objects on it, settings left out keep their current value
+// a list left out is not managed, with pruning a list that is given, even empty, removes what it does not hold
+// the nodes matching any of IngressSelectors are ingress gateways along with those listed in Ingress
+type DeclaredNetwork struct {
+	Network
+	DNS              []DNSEntry          `json:"dns" bson:"dns"`
+	ACLs             []DeclaredACL       `json:"acls" bson:"acls"`
+	Ingress          []string            `json:"ingress" bson:"ingress"`
+	IngressSelectors []string            `json:"ingressselectors,omitempty" bson:"ingressselectors,omitempty"`
+	Egress           []DeclaredEgress    `json:"egress" bson:"egress"`
+	Relays           []DeclaredRelay     `json:"relays" bson:"relays"`
+	ExtClients       []DeclaredExtClient `json:"extclients" bson:"extclients"`
+}
+
+// DeclaredEgress - an egress gateway on the node NodeID, or on every node matching the label selector Selector
+type DeclaredEgress struct {
+	EgressGatewayRequest
+	Selector string `json:"selector,omitempty" bson:"selector,omitempty"`
+}
+
+// DeclaredRelay - a relay on the node NodeID, or on every node matching the label selector Selector,
+// relaying RelayAddrs and the addresses of the nodes matching RelayedSelector
+type DeclaredRelay struct {
+	RelayRequest
+	Selector        string `json:"selector,omitempty" bson:"selector,omitempty"`
+	RelayedSelector string `json:"relayedselector,omitempty" bson:"relayedselector,omitempty"`
+}
+
+// DeclaredACL - whether two nodes of a network may reach each other, or every node matching a label selector
+// and every node matching another, the two sides are the nodes then the selectors, later rules override earlier ones
+type DeclaredACL struct {
+	Nodes     []string `json:"nodes" bson:"nodes"`
+	Selectors []string `json:"selectors,omitempty" bson:"selectors,omitempty"`
+	Allowed   bool     `json:"allowed" bson:"allowed"`
+}
+
+// DeclaredExtClient - an ext client of an ingress gateway, enabled defaults to the network's default ACL
+type DeclaredExtClient struct {
+	ClientID         string `json:"clientid" bson:"clientid"`
+	IngressGatewayID string `json:"ingressgatewayid" bson:"ingressgatewayid"`
+	Enabled          *bool  `json:"enabled,omitempty" bson:"enabled,omitempty"`
+}
+
+// DeclarationChange - a change needed to bring the server to a declaration, secrets are redacted
+type DeclarationChange struct {
+	Action  string        `json:"action" bson:"action"`
+	Kind    string        `json:"kind" bson:"kind"`
+	Network string        `json:"network" bson:"network"`
+	Name    string        `json:"name" bson:"name"`
+	Changes []AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+
+// DeclarationResult - the changes of a declaration, and how many of them were applied
+type DeclarationResult struct {
+	DryRun  bool                `json:"dryrun" bson:"dryrun"`
+	Prune   bool                `json:"prune" bson:"prune"`
+	Changes []DeclarationChange `json:"changes" bson:"changes"`
+	Applied int                 `json:"applied" bson:"applied"`
+	Error   string              `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// NetworkExport - a network as a declaration, without the secrets of its access keys
+type NetworkExport struct {
+	Version  string          `json:"version" bson:"version"`
+	Exported int64           `json:"exported" bson:"exported"`
+	Network  DeclaredNetwork `json:"network" bson:"network"`
+}
+
+// NetworkImport - an export to create a new network from, address ranges left out keep the exported ones
+type NetworkImport struct {
+	Export        NetworkExport `json:"export" bson:"export"`
+	AddressRange  string        `json:"addressrange" bson:"addressrange"`
+	AddressRange6 string        `json:"addressrange6" bson:"addressrange6"`
+}
+
+// NotCarried - something of an export that an import could not carry over, and why
+type NotCarried struct {
+	Kind   string `json:"kind" bson:"kind"`
+	Name   string `json:"name" bson:"name"`
+	Reason string `json:"reason" bson:"reason"`
+}
+
+// NetworkImportResult - the network created by an import, the changes made and what was left behind
+type NetworkImportResult struct {
+	Network    Network             `json:"network" bson:"network"`
+	Changes    []DeclarationChange `json:"changes" bson:"changes"`
+	NotCarried []NotCarried        `json:"notcarried" bson:"notcarried"`
+}
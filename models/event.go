@@ -0,0 +1,10 @@
+package models
+
+// Event - change streamed to clients watching the server, Data is the changed record
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Network   string      `json:"network,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"math/big"
+	"strings"
+)
+
+// kinds of objects holding an address in an IPAM report
+const (
+	IPAM_NODE        = "node"
+	IPAM_EXTCLIENT   = "extclient"
+	IPAM_RESERVATION = "reservation"
+)
+
+// IPReservation - an address of a network kept out of automatic allocation, or a range of them when EndAddress is set
+// an address reserved for a hostname, MAC address or node ID is handed to the node or ext client it names
+type IPReservation struct {
+	Network     string `json:"network" bson:"network"`
+	Address     string `json:"address" bson:"address" validate:"required,ip"`
+	EndAddress  string `json:"endaddress,omitempty" bson:"endaddress,omitempty" validate:"omitempty,ip"`
+	Hostname    string `json:"hostname,omitempty" bson:"hostname,omitempty"`
+	MacAddress  string `json:"macaddress,omitempty" bson:"macaddress,omitempty" validate:"omitempty,mac"`
+	NodeID      string `json:"nodeid,omitempty" bson:"nodeid,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Created     int64  `json:"created" bson:"created"`
+}
+
+// IPReservation.HasOwner - checks if a reservation is held for a hostname, MAC address or node ID
+func (reservation *IPReservation) HasOwner() bool {
+	return reservation.Hostname != "" || reservation.MacAddress != "" || reservation.NodeID != ""
+}
+
+// IPReservation.IsFor - checks if a reservation is held for a node or ext client with a name, MAC address and ID
+func (reservation *IPReservation) IsFor(name, macaddress, id string) bool {
+	return (reservation.Hostname != "" && reservation.Hostname == name) ||
+		(reservation.MacAddress != "" && strings.EqualFold(reservation.MacAddress, macaddress)) ||
+		(reservation.NodeID != "" && reservation.NodeID == id)
+}
+
+// IPAMReport - how the address ranges of a network are used, and what holds each address in use
+type IPAMReport struct {
+	Network   string        `json:"network" bson:"network"`
+	Ranges    []IPAMRange   `json:"ranges" bson:"ranges"`
+	Addresses []IPAMAddress `json:"addresses" bson:"addresses"`
+}
+
+// IPAMRange - the allocated, reserved and free addresses of a range, out of the addresses it can hand out
+type IPAMRange struct {
+	Range     string   `json:"range" bson:"range"`
+	Total     *big.Int `json:"total" bson:"total"`
+	Allocated int      `json:"allocated" bson:"allocated"`
+	Reserved  *big.Int `json:"reserved" bson:"reserved"`
+	Free      *big.Int `json:"free" bson:"free"`
+}
+
+// IPAMAddress - an address, or a range of reserved ones, and what holds it
+type IPAMAddress struct {
+	Address  string `json:"address" bson:"address"`
+	Kind     string `json:"kind" bson:"kind"`
+	ID       string `json:"id" bson:"id"`
+	Name     string `json:"name" bson:"name"`
+	Reserved bool   `json:"reserved" bson:"reserved"`
+}
+
+// kinds of range uses checked for overlaps
+const (
+	RANGE_NETWORK = "network"
+	RANGE_LOCAL   = "localrange"
+	RANGE_EGRESS  = "egress"
+	RANGE_RELAY   = "relay"
+)
+
+// AddressRangeUse - a range of addresses routed by a network, or by a node of it when NodeID is set
+type AddressRangeUse struct {
+	Range    string `json:"range" bson:"range"`
+	Kind     string `json:"kind" bson:"kind"`
+	Network  string `json:"network" bson:"network"`
+	NodeID   string `json:"nodeid,omitempty" bson:"nodeid,omitempty"`
+	NodeName string `json:"nodename,omitempty" bson:"nodename,omitempty"`
+}
+
+// AddressConflict - two uses of overlapping ranges that route the same addresses two ways
+type AddressConflict struct {
+	Use  AddressRangeUse `json:"use" bson:"use"`
+	With AddressRangeUse `json:"with" bson:"with"`
+}
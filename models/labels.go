@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// operators of a label requirement
+const (
+	LABEL_EQUALS     = "="
+	LABEL_NOT_EQUALS = "!="
+	LABEL_EXISTS     = "exists"
+	LABEL_NOT_EXISTS = "!exists"
+)
+
+var labelKeyRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+var labelValueRegex = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+
+// Labels - free-form key/value metadata grouping nodes, such as role=db or site=office-a
+type Labels map[string]string
+
+// LabelRequirement - a condition on one label of a selector
+type LabelRequirement struct {
+	Key   string `json:"key" bson:"key"`
+	Op    string `json:"op" bson:"op"`
+	Value string `json:"value,omitempty" bson:"value,omitempty"`
+}
+
+// LabelSelector - requirements that must all hold for labels to match, an empty selector matches everything
+type LabelSelector []LabelRequirement
+
+// ParseLabels - reads labels written as key=value pairs separated by commas
+func ParseLabels(value string) (Labels, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var labels = Labels{}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return nil, fmt.Errorf("label %s is not a key=value pair", pair)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return labels, labels.Validate()
+}
+
+// Labels.Validate - checks keys are 1 to 63 letters, digits, '.', '_', '-' or '/' starting and ending with a letter or digit,
+// and values are empty or alike without '/'
+func (labels Labels) Validate() error {
+	for key, value := range labels {
+		if !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if !labelValueRegex.MatchString(value) {
+			return fmt.Errorf("invalid value %q of label %s", value, key)
+		}
+	}
+	return nil
+}
+
+// Labels.String - labels as key=value pairs separated by commas, ordered by key
+func (labels Labels) String() string {
+	var pairs = make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseLabelSelector - reads a selector written as key=value, key!=value, key or !key requirements separated by commas
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var requirements LabelSelector
+	if strings.TrimSpace(selector) == "" {
+		return requirements, nil
+	}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		var requirement LabelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			requirement = LabelRequirement{Key: strings.TrimSpace(key), Op: LABEL_NOT_EQUALS, Value: strings.TrimSpace(value)}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			requirement = LabelRequirement{Key: strings.TrimSpace(key), Op: LABEL_EQUALS, Value: strings.TrimSpace(value)}
+		case strings.HasPrefix(term, "!"):
+			requirement = LabelRequirement{Key: strings.TrimSpace(term[1:]), Op: LABEL_NOT_EXISTS}
+		default:
+			requirement = LabelRequirement{Key: term, Op: LABEL_EXISTS}
+		}
+		if !labelKeyRegex.MatchString(requirement.Key) || !labelValueRegex.MatchString(requirement.Value) {
+			return nil, fmt.Errorf("invalid label selector %q", term)
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// LabelSelector.Matches - checks labels meet every requirement of a selector
+func (selector LabelSelector) Matches(labels Labels) bool {
+	for _, requirement := range selector {
+		value, ok := labels[requirement.Key]
+		switch requirement.Op {
+		case LABEL_EQUALS:
+			if !ok || value != requirement.Value {
+				return false
+			}
+		case LABEL_NOT_EQUALS:
+			if ok && value == requirement.Value {
+				return false
+			}
+		case LABEL_EXISTS:
+			if !ok {
+				return false
+			}
+		case LABEL_NOT_EXISTS:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
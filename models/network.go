@@ -12,6 +12,7 @@ type Network struct {
 	NetID               string      `json:"netid" bson:"netid" validate:"required,min=1,max=12,netid_valid"`
 	NodesLastModified   int64       `json:"nodeslastmodified" bson:"nodeslastmodified"`
 	NetworkLastModified int64       `json:"networklastmodified" bson:"networklastmodified"`
+	Revision            uint64      `json:"revision" bson:"revision"`
 	DefaultInterface    string      `json:"defaultinterface" bson:"defaultinterface" validate:"min=1,max=15"`
 	DefaultListenPort   int32       `json:"defaultlistenport,omitempty" bson:"defaultlistenport,omitempty" validate:"omitempty,min=1024,max=65535"`
 	NodeLimit           int32       `json:"nodelimit" bson:"nodelimit"`
@@ -29,6 +30,8 @@ type Network struct {
 	DefaultExtClientDNS string      `json:"defaultextclientdns" bson:"defaultextclientdns"`
 	DefaultMTU          int32       `json:"defaultmtu" bson:"defaultmtu"`
 	DefaultACL          string      `json:"defaultacl" bson:"defaultacl" yaml:"defaultacl" validate:"checkyesorno"`
+	SecondaryRanges     []string    `json:"secondaryranges,omitempty" bson:"secondaryranges,omitempty" validate:"omitempty,dive,cidrv4"`
+	SecondaryRanges6    []string    `json:"secondaryranges6,omitempty" bson:"secondaryranges6,omitempty" validate:"omitempty,dive,cidrv6"`
 }
 
 // SaveData - sensitive fields of a network that should be kept the same
@@ -93,3 +96,21 @@ func (network *Network) SetDefaults() {
 		network.DefaultACL = "yes"
 	}
 }
+
+// Network.AddressRanges - the IPv4 ranges of a network, primary first, in the order addresses are handed out
+func (network *Network) AddressRanges() []string {
+	return withPrimaryRange(network.AddressRange, network.SecondaryRanges)
+}
+
+// Network.AddressRanges6 - the IPv6 ranges of a network, primary first, in the order addresses are handed out
+func (network *Network) AddressRanges6() []string {
+	return withPrimaryRange(network.AddressRange6, network.SecondaryRanges6)
+}
+
+func withPrimaryRange(primary string, secondary []string) []string {
+	var ranges []string
+	if primary != "" {
+		ranges = append(ranges, primary)
+	}
+	return append(ranges, secondary...)
+}
@@ -40,6 +40,7 @@ type Node struct {
 	Address6            string      `json:"address6" bson:"address6" yaml:"address6" validate:"omitempty,ipv6"`
 	LocalAddress        string      `json:"localaddress" bson:"localaddress" yaml:"localaddress" validate:"omitempty,ip"`
 	Name                string      `json:"name" bson:"name" yaml:"name" validate:"omitempty,max=62,in_charset"`
+	Labels              Labels      `json:"labels,omitempty" bson:"labels,omitempty" yaml:"labels,omitempty"`
 	NetworkSettings     Network     `json:"networksettings" bson:"networksettings" yaml:"networksettings" validate:"-"`
 	ListenPort          int32       `json:"listenport" bson:"listenport" yaml:"listenport" validate:"omitempty,numeric,min=1024,max=65535"`
 	LocalListenPort     int32       `json:"locallistenport" bson:"locallistenport" yaml:"locallistenport" validate:"numeric,min=0,max=65535"`
@@ -53,6 +54,7 @@ type Node struct {
 	AccessKey           string      `json:"accesskey" bson:"accesskey" yaml:"accesskey"`
 	Interface           string      `json:"interface" bson:"interface" yaml:"interface"`
 	LastModified        int64       `json:"lastmodified" bson:"lastmodified" yaml:"lastmodified"`
+	Revision            uint64      `json:"revision" bson:"revision" yaml:"revision"`
 	ExpirationDateTime  int64       `json:"expdatetime" bson:"expdatetime" yaml:"expdatetime"`
 	LastPeerUpdate      int64       `json:"lastpeerupdate" bson:"lastpeerupdate" yaml:"lastpeerupdate"`
 	LastCheckIn         int64       `json:"lastcheckin" bson:"lastcheckin" yaml:"lastcheckin"`
@@ -374,6 +376,9 @@ func (newNode *Node) Fill(currentNode *Node) {
 	if newNode.RelayAddrs == nil {
 		newNode.RelayAddrs = currentNode.RelayAddrs
 	}
+	if newNode.Labels == nil {
+		newNode.Labels = currentNode.Labels
+	}
 	if newNode.IsRelay == "" {
 		newNode.IsRelay = currentNode.IsRelay
 	}
@@ -0,0 +1,20 @@
+package models
+
+// roles a user can be given on a network
+const (
+	// NETWORK_ROLE_OWNER - manages the network and who has access to it, and may delete it
+	NETWORK_ROLE_OWNER = "owner"
+	// NETWORK_ROLE_ADMIN - manages the network, its nodes, ACLs, DNS, access keys and ext clients
+	NETWORK_ROLE_ADMIN = "network-admin"
+	// NETWORK_ROLE_OPERATOR - operates nodes, gateways and ext clients but cannot change network settings
+	NETWORK_ROLE_OPERATOR = "operator"
+	// NETWORK_ROLE_VIEWER - may only read the network and its nodes
+	NETWORK_ROLE_VIEWER = "viewer"
+	// NETWORK_ROLE_EXT_CLIENT_SELF_SERVICE - may only create and manage their own ext clients
+	NETWORK_ROLE_EXT_CLIENT_SELF_SERVICE = "ext-client-self-service"
+)
+
+// UserNetworkRole - the role given to a user on a network
+type UserNetworkRole struct {
+	Role string `json:"role"`
+}
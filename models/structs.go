@@ -25,9 +25,10 @@ type User struct {
 
 // ReturnUser - return user struct
 type ReturnUser struct {
-	UserName string   `json:"username" bson:"username" validate:"min=3,max=40,regexp=^(([a-zA-Z,\-,\.]*)|([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,4})){3,40}$"`
-	Networks []string `json:"networks" bson:"networks"`
-	IsAdmin  bool     `json:"isadmin" bson:"isadmin"`
+	UserName     string            `json:"username" bson:"username" validate:"min=3,max=40,regexp=^(([a-zA-Z,\-,\.]*)|([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,4})){3,40}$"`
+	Networks     []string          `json:"networks" bson:"networks"`
+	IsAdmin      bool              `json:"isadmin" bson:"isadmin"`
+	NetworkRoles map[string]string `json:"networkroles" bson:"networkroles"`
 }
 
 // UserAuthParams - user auth params struct
@@ -193,3 +194,24 @@ type NodeGet struct {
 	Node  Node                 `json:"node" bson:"node" yaml:"node"`
 	Peers []wgtypes.PeerConfig `json:"peers" bson:"peers" yaml:"peers"`
 }
+
+// LoginLockout - failed login attempts of a user, node or IP address
+type LoginLockout struct {
+	Kind        string `json:"kind"`
+	Subject     string `json:"subject"`
+	Failures    int64  `json:"failures"`
+	LastFailure int64  `json:"lastfailure"`
+	RetryAfter  int64  `json:"retryafter"`
+	Locked      bool   `json:"locked"`
+}
+
+// HealthStatus - result of a liveness or readiness probe
+type HealthStatus struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// ComponentHealth - result of checking a single dependency of the server, failures are detailed in the server log
+type ComponentHealth struct {
+	Status string `json:"status"`
+}
@@ -2,15 +2,20 @@ package mq
 
 import (
 	"encoding/json"
+	"errors"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/logic/events"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
 )
 
+// maxUpdateRetries - times a node update is retried after losing to a concurrent write
+const maxUpdateRetries = 3
+
 // DefaultHandler default message queue handler - only called when GetDebug == true
 func DefaultHandler(client mqtt.Client, msg mqtt.Message) {
 	logger.Log(0, "MQTT Message: Topic: ", string(msg.Topic()), " Message: ", string(msg.Payload()))
@@ -18,11 +23,11 @@ func DefaultHandler(client mqtt.Client, msg mqtt.Message) {
 
 // Ping message Handler -- handles ping topic from client nodes
 func Ping(client mqtt.Client, msg mqtt.Message) {
-	go func() {
+	handle("ping", func() error {
 		id, err := getID(msg.Topic())
 		if err != nil {
 			logger.Log(0, "error getting node.ID sent on ping topic ")
-			return
+			return err
 		}
 		node, err := logic.GetNodeByID(id)
 		if err != nil {
@@ -30,104 +35,166 @@ func Ping(client mqtt.Client, msg mqtt.Message) {
 			record, err := database.FetchRecord(database.NODES_TABLE_NAME, id)
 			if err != nil {
 				logger.Log(0, "error reading database ", err.Error())
-				return
+				return err
 			}
 			logger.Log(0, "record from database")
 			logger.Log(0, record)
-			return
+			return errors.New("node " + id + " could not be loaded")
 		}
 		version, decryptErr := decryptMsg(&node, msg.Payload())
 		if decryptErr != nil {
 			logger.Log(0, "error decrypting when updating node ", node.ID, decryptErr.Error())
-			return
+			return decryptErr
 		}
-		node.SetLastCheckIn()
-		node.Version = string(version)
-		if err := logic.UpdateNode(&node, &node); err != nil {
+		// check ins are not audited, they would record an entry per node every interval
+		checkedIn, err := updateNodeMerged(node, func(currentNode *models.Node) models.Node {
+			newNode := *currentNode
+			newNode.SetLastCheckIn()
+			newNode.Version = string(version)
+			return newNode
+		})
+		if err != nil {
 			logger.Log(0, "error updating node", node.Name, node.ID, " on checkin", err.Error())
-			return
+			return err
 		}
+		events.Publish(events.NODE_CHECKIN, checkedIn.Network, map[string]interface{}{
+			"id":          checkedIn.ID,
+			"network":     checkedIn.Network,
+			"lastcheckin": checkedIn.LastCheckIn,
+			"version":     checkedIn.Version,
+		})
 
 		logger.Log(3, "ping processed for node", node.Name, node.ID)
 		// --TODO --set client version once feature is implemented.
 		//node.SetClientVersion(msg.Payload())
-	}()
+		return nil
+	})
 }
 
 // UpdateNode  message Handler -- handles updates from client nodes
 func UpdateNode(client mqtt.Client, msg mqtt.Message) {
-	go func() {
+	handle("update", func() error {
 		id, err := getID(msg.Topic())
 		if err != nil {
 			logger.Log(1, "error getting node.ID sent on ", msg.Topic(), err.Error())
-			return
+			return err
 		}
 		currentNode, err := logic.GetNodeByID(id)
 		if err != nil {
 			logger.Log(1, "error getting node ", id, err.Error())
-			return
+			return err
 		}
 		decrypted, decryptErr := decryptMsg(&currentNode, msg.Payload())
 		if decryptErr != nil {
 			logger.Log(1, "failed to decrypt message for node ", id, decryptErr.Error())
-			return
+			return decryptErr
 		}
 		var newNode models.Node
 		if err := json.Unmarshal(decrypted, &newNode); err != nil {
 			logger.Log(1, "error unmarshaling payload ", err.Error())
-			return
+			return err
 		}
-		if err := logic.UpdateNode(&currentNode, &newNode); err != nil {
+		var before models.Node
+		after, err := updateNodeMerged(currentNode, func(storedNode *models.Node) models.Node {
+			before = *storedNode
+			return mergeReportedNode(storedNode, &newNode)
+		})
+		if err != nil {
 			logger.Log(1, "error saving node", err.Error())
-			return
+			return err
 		}
+		logic.Audit(models.AuditEntry{
+			ActorType:  models.AUDIT_ACTOR_NODE,
+			Actor:      id,
+			Source:     "mq",
+			Action:     models.AUDIT_UPDATE,
+			TargetType: "node",
+			Target:     id,
+			Network:    after.Network,
+		}, &before, &after)
 		logger.Log(1, "updated node", id, newNode.Name)
-	}()
+		return nil
+	})
 }
 
 // ClientPeerUpdate  message handler -- handles updating peers after signal from client nodes
 func ClientPeerUpdate(client mqtt.Client, msg mqtt.Message) {
-	go func() {
+	handle("signal", func() error {
 		id, err := getID(msg.Topic())
 		if err != nil {
 			logger.Log(1, "error getting node.ID sent on ", msg.Topic(), err.Error())
-			return
+			return err
 		}
 		currentNode, err := logic.GetNodeByID(id)
 		if err != nil {
 			logger.Log(1, "error getting node ", id, err.Error())
-			return
+			return err
 		}
 		decrypted, decryptErr := decryptMsg(&currentNode, msg.Payload())
 		if decryptErr != nil {
 			logger.Log(1, "failed to decrypt message during client peer update for node ", id, decryptErr.Error())
-			return
+			return decryptErr
 		}
 		switch decrypted[0] {
 		case ncutils.ACK:
 			currentServerNode, err := logic.GetNetworkServerLocal(currentNode.Network)
 			if err != nil {
-				return
+				return err
 			}
 			if err := logic.ServerUpdate(&currentServerNode, false); err != nil {
 				logger.Log(1, "server node:", currentServerNode.ID, "failed update")
-				return
+				return err
 			}
 		case ncutils.DONE:
 			currentServerNode, err := logic.GetNetworkServerLocal(currentNode.Network)
 			if err != nil {
-				return
+				return err
 			}
 			if err := logic.ServerUpdate(&currentServerNode, false); err != nil {
 				logger.Log(1, "server node:", currentServerNode.ID, "failed update")
-				return
+				return err
 			}
 			if err := PublishPeerUpdate(&currentNode); err != nil {
 				logger.Log(1, "error publishing peer update ", err.Error())
-				return
+				return err
 			}
 		}
 
 		logger.Log(1, "sent peer updates after signal received from", id, currentNode.Name)
-	}()
+		return nil
+	})
+}
+
+// mergeReportedNode - the stored node with the fields a node reports about itself copied from its update,
+// fields left empty in the update keep their stored values as they would in Fill, other fields are changed through the API and are kept as stored so concurrent writes are not undone
+func mergeReportedNode(storedNode, reported *models.Node) models.Node {
+	newNode := *reported
+	newNode.Fill(storedNode)
+	merged := *storedNode
+	merged.PublicKey = newNode.PublicKey
+	merged.Endpoint = newNode.Endpoint
+	merged.LocalAddress = newNode.LocalAddress
+	merged.LocalRange = newNode.LocalRange
+	merged.ListenPort = newNode.ListenPort
+	merged.LocalListenPort = newNode.LocalListenPort
+	return merged
+}
+
+// updateNodeMerged - updates a node with the result of merge, reloading the node and merging again
+// whenever a concurrent write changed it first, returns the node as saved
+func updateNodeMerged(node models.Node, merge func(currentNode *models.Node) models.Node) (models.Node, error) {
+	var err error
+	for attempt := 0; attempt <= maxUpdateRetries; attempt++ {
+		if attempt > 0 {
+			logger.Log(2, "node", node.ID, "was modified concurrently, retrying update")
+			if node, err = logic.GetNodeByID(node.ID); err != nil {
+				return models.Node{}, err
+			}
+		}
+		newNode := merge(&node)
+		if err = logic.UpdateNode(&node, &newNode); !errors.Is(err, logic.ErrRevisionConflict) {
+			return newNode, err
+		}
+	}
+	return models.Node{}, err
 }
@@ -0,0 +1,60 @@
+package mq
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestUpdateNodeMerged(t *testing.T) {
+	os.Setenv("DATABASE", "memory")
+	defer os.Unsetenv("DATABASE")
+	if err := database.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB()
+	if _, err := logic.CreateNetwork(models.Network{NetID: "mqnet", AddressRange: "10.90.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	node := models.Node{ID: "mqnode", PublicKey: "DM5qhLAE20PG9BbfBCger+Ac9D2NDOwCtY1rbYDLf34=", Name: "mqnode", Endpoint: "10.0.0.1", Address: "10.90.0.2", MacAddress: "01:02:03:04:05:06", Password: "password", Network: "mqnet", OS: "linux"}
+	logic.SetNodeDefaults(&node)
+	data, _ := json.Marshal(&node)
+	if err := database.Insert(node.ID, string(data), database.NODES_TABLE_NAME); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := logic.GetNodeByID(node.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an admin renames the node after the update was loaded but before it is saved
+	current, _ := logic.GetNodeByID(node.ID)
+	renamed := current
+	renamed.Name = "renamed"
+	renamed.PersistentKeepalive = 30
+	if err := logic.UpdateNode(&current, &renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	reported := loaded
+	reported.Endpoint = "10.0.0.2"
+	saved, err := updateNodeMerged(loaded, func(storedNode *models.Node) models.Node {
+		return mergeReportedNode(storedNode, &reported)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := logic.GetNodeByID(node.ID)
+	for _, got := range []models.Node{saved, stored} {
+		if got.Endpoint != "10.0.0.2" {
+			t.Fatalf("expected the reported endpoint to be saved, got %s", got.Endpoint)
+		}
+		if got.Name != "renamed" || got.PersistentKeepalive != 30 {
+			t.Fatalf("expected the concurrent write to be kept, got %s %d", got.Name, got.PersistentKeepalive)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package mq
+
+import (
+	"github.com/gravitl/netmaker/metrics"
+)
+
+var (
+	messagesHandled = metrics.NewCounterVec(
+		"netmaker_mq_messages_total",
+		"MQ messages handled by topic and result.",
+		"topic", "result",
+	)
+	peerUpdateDuration = metrics.NewHistogramVec(
+		"netmaker_peer_update_publish_duration_seconds",
+		"Time taken to publish peer updates to every node of a network.",
+		metrics.DefaultBuckets,
+		"network",
+	)
+)
+
+// handle - runs a message handler in the background, counting the message as handled or failed under its topic
+func handle(topic string, handler func() error) {
+	go func() {
+		result := "success"
+		if err := handler(); err != nil {
+			result = "failure"
+		}
+		messagesHandled.WithLabelValues(topic, result).Inc()
+	}()
+}
@@ -2,7 +2,9 @@ package mq
 
 import (
 	"context"
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -18,6 +20,12 @@ const MQ_DISCONNECT = 250
 
 var peer_force_send = 0
 
+// subscriber - the client receiving node messages, checked by the readiness probe
+var (
+	subscriberMutex sync.Mutex
+	subscriber      mqtt.Client
+)
+
 // SetupMQTT creates a connection to broker and return client
 func SetupMQTT(publish bool) mqtt.Client {
 	opts := mqtt.NewClientOptions()
@@ -67,9 +75,27 @@ func SetupMQTT(publish bool) mqtt.Client {
 		}
 		time.Sleep(2 * time.Second)
 	}
+	if !publish {
+		subscriberMutex.Lock()
+		subscriber = client
+		subscriberMutex.Unlock()
+	}
 	return client
 }
 
+// CheckConnection - checks the client set up to receive node messages is connected to the broker
+func CheckConnection() error {
+	subscriberMutex.Lock()
+	defer subscriberMutex.Unlock()
+	if subscriber == nil {
+		return errors.New("message queue subscriber has not been set up")
+	}
+	if !subscriber.IsConnectionOpen() {
+		return errors.New("message queue subscriber is not connected to " + servercfg.GetMessageQueueEndpoint())
+	}
+	return nil
+}
+
 // Keepalive -- periodically pings all nodes to let them know server is still alive and doing well
 func Keepalive(ctx context.Context) {
 	for {
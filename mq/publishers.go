@@ -3,6 +3,7 @@ package mq
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
@@ -16,6 +17,10 @@ func PublishPeerUpdate(newNode *models.Node) error {
 	if !servercfg.IsMessageQueueBackend() {
 		return nil
 	}
+	start := time.Now()
+	defer func() {
+		peerUpdateDuration.WithLabelValues(newNode.Network).Observe(time.Since(start).Seconds())
+	}()
 	networkNodes, err := logic.GetNetworkNodes(newNode.Network)
 	if err != nil {
 		logger.Log(1, "err getting Network Nodes", err.Error())
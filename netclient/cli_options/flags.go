@@ -66,6 +66,12 @@ func GetFlags(hostname string) []cli.Flag {
 			Value:   "",
 			Usage:   "Identifiable name for machine within Netmaker network.",
 		},
+		&cli.StringFlag{
+			Name:    "labels",
+			EnvVars: []string{"NETCLIENT_LABELS"},
+			Value:   "",
+			Usage:   "Labels grouping the machine as key=value pairs separated by commas, for instance role=db,site=office-a.",
+		},
 		&cli.StringFlag{
 			Name:    "name",
 			EnvVars: []string{"NETCLIENT_NAME"},
@@ -227,6 +227,11 @@ func GetCLIConfig(c *cli.Context) (ClientConfig, string, error) {
 		cfg.Server.API = c.String("apiserver")
 	}
 	cfg.Node.Name = c.String("name")
+	labels, err := models.ParseLabels(c.String("labels"))
+	if err != nil {
+		return cfg, "", err
+	}
+	cfg.Node.Labels = labels
 	cfg.Node.Interface = c.String("interface")
 	cfg.Node.Password = c.String("password")
 	cfg.Node.MacAddress = c.String("macaddress")
@@ -189,9 +189,11 @@ func LeaveNetwork(network string, force bool) error {
 		dev, devErr := wgClient.Device(removeIface)
 		if devErr == nil {
 			local.FlushPeerRoutes(removeIface, queryAddr, dev.Peers[:])
-			_, cidr, cidrErr := net.ParseCIDR(cfg.NetworkSettings.AddressRange)
-			if cidrErr == nil {
-				local.RemoveCIDRRoute(removeIface, queryAddr, cidr)
+			for _, addressRange := range cfg.NetworkSettings.AddressRanges() {
+				_, cidr, cidrErr := net.ParseCIDR(addressRange)
+				if cidrErr == nil {
+					local.RemoveCIDRRoute(removeIface, queryAddr, cidr)
+				}
 			}
 		} else {
 			logger.Log(1, "could not flush peer routes when leaving network, ", cfg.Node.Network)
@@ -201,21 +201,25 @@ func InitWireguard(node *models.Node, privkey string, peers []wgtypes.PeerConfig
 
 	//ipv4
 	if node.Address != "" {
-		_, cidr, cidrErr := net.ParseCIDR(modcfg.NetworkSettings.AddressRange)
-		if cidrErr == nil {
-			local.SetCIDRRoute(ifacename, node.Address, cidr)
-		} else {
-			logger.Log(1, "could not set cidr route properly: ", cidrErr.Error())
+		for _, addressRange := range modcfg.NetworkSettings.AddressRanges() {
+			_, cidr, cidrErr := net.ParseCIDR(addressRange)
+			if cidrErr == nil {
+				local.SetCIDRRoute(ifacename, node.Address, cidr)
+			} else {
+				logger.Log(1, "could not set cidr route properly: ", cidrErr.Error())
+			}
 		}
 		local.SetCurrentPeerRoutes(ifacename, node.Address, peers)
 	}
 	if node.Address6 != "" {
 		//ipv6
-		_, cidr, cidrErr := net.ParseCIDR(modcfg.NetworkSettings.AddressRange6)
-		if cidrErr == nil {
-			local.SetCIDRRoute(ifacename, node.Address6, cidr)
-		} else {
-			logger.Log(1, "could not set cidr route properly: ", cidrErr.Error())
+		for _, addressRange := range modcfg.NetworkSettings.AddressRanges6() {
+			_, cidr, cidrErr := net.ParseCIDR(addressRange)
+			if cidrErr == nil {
+				local.SetCIDRRoute(ifacename, node.Address6, cidr)
+			} else {
+				logger.Log(1, "could not set cidr route properly: ", cidrErr.Error())
+			}
 		}
 
 		local.SetCurrentPeerRoutes(ifacename, node.Address6, peers)
@@ -300,14 +304,8 @@ func ApplyConf(node *models.Node, ifacename string, confPath string) error {
 	var nodeCfg config.ClientConfig
 	nodeCfg.Network = node.Network
 	nodeCfg.ReadConfig()
-	if nodeCfg.NetworkSettings.AddressRange != "" {
-		ip, cidr, err := net.ParseCIDR(nodeCfg.NetworkSettings.AddressRange)
-		if err == nil {
-			local.SetCIDRRoute(node.Interface, ip.String(), cidr)
-		}
-	}
-	if nodeCfg.NetworkSettings.AddressRange6 != "" {
-		ip, cidr, err := net.ParseCIDR(nodeCfg.NetworkSettings.AddressRange6)
+	for _, addressRange := range append(nodeCfg.NetworkSettings.AddressRanges(), nodeCfg.NetworkSettings.AddressRanges6()...) {
+		ip, cidr, err := net.ParseCIDR(addressRange)
 		if err == nil {
 			local.SetCIDRRoute(node.Interface, ip.String(), cidr)
 		}
@@ -64,6 +64,14 @@ func GetServerConfig() config.ServerConfig {
 		cfg.DisableRemoteIPCheck = "on"
 	}
 	cfg.Database = GetDB()
+	cfg.MigrationDryRun = "off"
+	if IsMigrationDryRun() {
+		cfg.MigrationDryRun = "on"
+	}
+	cfg.Caching = "off"
+	if IsCachingEnabled() {
+		cfg.Caching = "on"
+	}
 	cfg.Platform = GetPlatform()
 	cfg.Version = GetVersion()
 
@@ -121,7 +129,7 @@ func GetVersion() string {
 	return Version
 }
 
-// GetDB - gets the database type
+// GetDB - gets the database type (sqlite, postgres, rqlite or memory)
 func GetDB() string {
 	database := "sqlite"
 	if os.Getenv("DATABASE") != "" {
@@ -404,6 +412,59 @@ func DisableRemoteIPCheck() bool {
 	return disabled
 }
 
+// IsMigrationDryRun - report pending database migrations without applying them
+func IsMigrationDryRun() bool {
+	dryrun := false
+	if os.Getenv("MIGRATION_DRY_RUN") != "" {
+		if os.Getenv("MIGRATION_DRY_RUN") == "on" {
+			dryrun = true
+		}
+	} else if config.Config.Server.MigrationDryRun != "" {
+		if config.Config.Server.MigrationDryRun == "on" {
+			dryrun = true
+		}
+	}
+	return dryrun
+}
+
+// IsCachingEnabled - should the server keep nodes, networks, ext clients and ACLs cached in memory
+// disable when several servers share a database, as writes from other servers are not seen by the cache
+func IsCachingEnabled() bool {
+	caching := true
+	if os.Getenv("CACHING_ENABLED") != "" {
+		if os.Getenv("CACHING_ENABLED") == "off" {
+			caching = false
+		}
+	} else if config.Config.Server.Caching != "" {
+		if config.Config.Server.Caching == "off" {
+			caching = false
+		}
+	}
+	return caching
+}
+
+// GetDBEncryptionKey - gets the base64 encoded key used to encrypt secrets stored in the database
+func GetDBEncryptionKey() string {
+	key := ""
+	if os.Getenv("DB_ENCRYPTION_KEY") != "" {
+		key = os.Getenv("DB_ENCRYPTION_KEY")
+	} else if config.Config.Server.DBEncryptionKey != "" {
+		key = config.Config.Server.DBEncryptionKey
+	}
+	return key
+}
+
+// GetDBEncryptionKeyFile - gets the path of a file holding the database encryption key, takes precedence over the key itself
+func GetDBEncryptionKeyFile() string {
+	keyFile := ""
+	if os.Getenv("DB_ENCRYPTION_KEY_FILE") != "" {
+		keyFile = os.Getenv("DB_ENCRYPTION_KEY_FILE")
+	} else if config.Config.Server.DBEncryptionKeyFile != "" {
+		keyFile = config.Config.Server.DBEncryptionKeyFile
+	}
+	return keyFile
+}
+
 // GetPublicIP - gets public ip
 func GetPublicIP() (string, error) {
 
@@ -512,6 +573,83 @@ func GetServerCheckinInterval() int64 {
 	return t
 }
 
+// GetDeletedNodeRetention - gets the hours deleted nodes are kept for restoring before being purged
+func GetDeletedNodeRetention() int64 {
+	var hours = int64(168)
+	var envhours, _ = strconv.Atoi(os.Getenv("DELETED_NODE_RETENTION"))
+	if envhours > 0 {
+		hours = int64(envhours)
+	} else if config.Config.Server.DeletedNodeRetention > 0 {
+		hours = config.Config.Server.DeletedNodeRetention
+	}
+	return hours
+}
+
+// GetLoginMaxAttempts - gets the failed logins allowed for a user or node before it is locked out
+func GetLoginMaxAttempts() int64 {
+	var attempts = int64(5)
+	var envattempts, _ = strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS"))
+	if envattempts > 0 {
+		attempts = int64(envattempts)
+	} else if config.Config.Server.LoginMaxAttempts > 0 {
+		attempts = config.Config.Server.LoginMaxAttempts
+	}
+	return attempts
+}
+
+// GetLoginMaxIPAttempts - gets the failed logins allowed from an IP address before it is locked out
+func GetLoginMaxIPAttempts() int64 {
+	var attempts = int64(20)
+	var envattempts, _ = strconv.Atoi(os.Getenv("LOGIN_MAX_IP_ATTEMPTS"))
+	if envattempts > 0 {
+		attempts = int64(envattempts)
+	} else if config.Config.Server.LoginMaxIPAttempts > 0 {
+		attempts = config.Config.Server.LoginMaxIPAttempts
+	}
+	return attempts
+}
+
+// GetLoginBackoff - gets the seconds to wait after a first failed login, doubling with each further failure
+func GetLoginBackoff() int64 {
+	var seconds = int64(1)
+	var envseconds, _ = strconv.Atoi(os.Getenv("LOGIN_BACKOFF"))
+	if envseconds > 0 {
+		seconds = int64(envseconds)
+	} else if config.Config.Server.LoginBackoff > 0 {
+		seconds = config.Config.Server.LoginBackoff
+	}
+	return seconds
+}
+
+// GetLoginLockout - gets the seconds a user, node or IP address is locked out for after too many failed logins
+func GetLoginLockout() int64 {
+	var seconds = int64(900)
+	var envseconds, _ = strconv.Atoi(os.Getenv("LOGIN_LOCKOUT"))
+	if envseconds > 0 {
+		seconds = int64(envseconds)
+	} else if config.Config.Server.LoginLockout > 0 {
+		seconds = config.Config.Server.LoginLockout
+	}
+	return seconds
+}
+
+// GetTrustedProxies - gets the addresses and ranges of the reverse proxies whose X-Forwarded-For header is trusted
+func GetTrustedProxies() []string {
+	var proxies []string
+	var value = ""
+	if os.Getenv("TRUSTED_PROXIES") != "" {
+		value = os.Getenv("TRUSTED_PROXIES")
+	} else if config.Config.Server.TrustedProxies != "" {
+		value = config.Config.Server.TrustedProxies
+	}
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 // GetAuthProviderInfo = gets the oauth provider info
 func GetAuthProviderInfo() []string {
 	var authProvider = ""